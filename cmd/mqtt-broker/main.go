@@ -0,0 +1,113 @@
+//Command mqtt-broker runs the reference mqtt 5 broker of package broker.
+//
+//Usage:
+//
+//	mqtt-broker [-addr localhost:1883] [-ws localhost:8083] [-acl acl.txt] [-sessions sessions.log]
+//		[-queue-max 1000] [-queue-bytes 0] [-queue-drop newest|oldest|qos0]
+//
+//With -ws the broker additionally accepts mqtt over WebSocket on the path /mqtt.
+//With -acl publish and subscribe permissions are enforced according to the rule file, see package acl.
+//With -sessions sessions with a session expiry interval are saved in the log file and survive a restart.
+//The -queue flags limit the messages queued per session and decide which are dropped once a limit is reached, see package queue.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/squ94wk/mqtt-common/pkg/acl"
+	"github.com/squ94wk/mqtt-common/pkg/broker"
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/queue"
+	"github.com/squ94wk/mqtt-common/pkg/store"
+	"github.com/squ94wk/mqtt-common/pkg/websocket"
+)
+
+//options are the command line flags.
+type options struct {
+	addr         string
+	wsAddr       string
+	aclPath      string
+	sessionsPath string
+	queue        queue.Options
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.addr, "addr", "localhost:1883", "TCP address to listen on")
+	flag.StringVar(&opts.wsAddr, "ws", "", "TCP address to accept mqtt over WebSocket on, disabled if empty")
+	flag.StringVar(&opts.aclPath, "acl", "", "rule file to enforce publish and subscribe permissions, everything is allowed if empty")
+	flag.StringVar(&opts.sessionsPath, "sessions", "", "log file to save sessions in, sessions end with the broker if empty")
+	flag.IntVar(&opts.queue.MaxCount, "queue-max", queue.DefaultMaxCount, "maximum number of messages queued per session")
+	flag.IntVar(&opts.queue.MaxBytes, "queue-bytes", 0, "maximum size in bytes of the messages queued per session, unlimited if 0")
+	queueDrop := flag.String("queue-drop", queue.DropNewest.String(), "messages to drop from a full queue: newest, oldest or qos0")
+	flag.Parse()
+
+	policy, err := queue.ParseDropPolicy(*queueDrop)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.queue.Policy = policy
+	if err := run(opts); err != nil {
+		log.Fatal(err)
+	}
+}
+
+//run serves mqtt until an interrupt signal is received or a listener fails.
+//The broker is closed and the sessions are saved before it returns.
+func run(opts options) error {
+	server := broker.NewServer(clock.System)
+	server.SetQueueOptions(opts.queue)
+	if opts.aclPath != "" {
+		list, err := acl.Load(opts.aclPath)
+		if err != nil {
+			return err
+		}
+		server.AddHooks(acl.Hooks{List: list})
+	}
+	if opts.sessionsPath != "" {
+		sessions, err := store.OpenFileStore(opts.sessionsPath)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := sessions.Close(); err != nil {
+				log.Printf("failed to close session store: %v", err)
+			}
+		}()
+		if err := server.UseSessionStore(sessions); err != nil {
+			return err
+		}
+	}
+
+	// errs receives the errors of the listeners
+	errs := make(chan error, 2)
+	if opts.wsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/mqtt", websocket.Handler(server.ServeConn))
+		go func() {
+			log.Printf("accepting mqtt over WebSocket on ws://%s/mqtt", opts.wsAddr)
+			errs <- http.ListenAndServe(opts.wsAddr, mux)
+		}()
+	}
+	go func() {
+		log.Printf("accepting mqtt on %s", opts.addr)
+		if err := server.ListenAndServe(opts.addr); err != broker.ErrServerClosed {
+			errs <- err
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	var err error
+	select {
+	case <-signals:
+	case err = <-errs:
+	}
+	// the sessions are saved once all connections are closed
+	server.Close()
+	return err
+}
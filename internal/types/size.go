@@ -20,13 +20,13 @@ const (
 //VarIntSize returns the length taken by a encoded variable length integer.
 func VarIntSize(i uint32) uint32 {
 	switch {
-	case i > 1<<22:
-		return 4
-	case i > 1<<15:
-		return 3
-	case i > 1<<8:
+	case i < 1<<7:
+		return 1
+	case i < 1<<14:
 		return 2
+	case i < 1<<21:
+		return 3
 	default:
-		return 1
+		return 4
 	}
 }
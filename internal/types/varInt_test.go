@@ -36,6 +36,9 @@ func TestWriteVarInt(t *testing.T) {
 			if gotWriter := writer.Bytes(); !bytes.Equal(gotWriter, tt.wantWriter) {
 				t.Errorf("WriteVarIntTo() = %v, want %v", gotWriter, tt.wantWriter)
 			}
+			if gotSize := VarIntSize(tt.args.value); gotSize != uint32(len(tt.wantWriter)) {
+				t.Errorf("VarIntSize() = %d, want %d", gotSize, len(tt.wantWriter))
+			}
 		})
 	}
 }
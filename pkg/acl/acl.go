@@ -0,0 +1,161 @@
+package acl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//Placeholders substituted in the topic filters of rules.
+const (
+	ClientIDPlaceholder = "%c"
+	UsernamePlaceholder = "%u"
+)
+
+//ErrSharedRule is returned for rules with a shared subscription as topic filter.
+var ErrSharedRule = errors.New("rules must not use shared subscriptions")
+
+//Action is a set of operations a rule applies to.
+type Action int
+
+//Actions of rules.
+const (
+	Publish Action = 1 << iota
+	Subscribe
+
+	PublishSubscribe = Publish | Subscribe
+)
+
+//String returns the name used for the action in rule files.
+func (a Action) String() string {
+	switch a {
+	case Publish:
+		return "publish"
+	case Subscribe:
+		return "subscribe"
+	case PublishSubscribe:
+		return "pubsub"
+	default:
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+}
+
+//Rule allows or denies actions on the topics matched by Filter.
+//Filter may contain the placeholders %c and %u.
+type Rule struct {
+	Allow   bool
+	Actions Action
+	Filter  string
+}
+
+//Client identifies the client permissions are evaluated for.
+type Client struct {
+	ClientID string
+	Username string
+}
+
+//List is an ordered list of rules.
+//It is immutable and therefore safe for concurrent use.
+type List struct {
+	rules []rule
+}
+
+//rule is a validated Rule with the levels of its filter.
+type rule struct {
+	Rule
+	levels       []string
+	placeholders bool
+}
+
+//NewList is the constructor of the List type.
+//An error is returned if the filter of a rule is invalid.
+func NewList(rules ...Rule) (*List, error) {
+	l := &List{rules: make([]rule, 0, len(rules))}
+	for _, r := range rules {
+		// placeholders are validated as if they were substituted by a valid level
+		validated := strings.NewReplacer(ClientIDPlaceholder, "x", UsernamePlaceholder, "x").Replace(r.Filter)
+		filter, err := topic.ParseFilter(validated)
+		if err != nil {
+			return nil, err
+		}
+		if filter.IsShared() {
+			return nil, fmt.Errorf("rule '%s': %w", r.Filter, ErrSharedRule)
+		}
+		l.rules = append(l.rules, rule{
+			Rule:         r,
+			levels:       strings.Split(r.Filter, topic.Separator),
+			placeholders: strings.Contains(r.Filter, ClientIDPlaceholder) || strings.Contains(r.Filter, UsernamePlaceholder),
+		})
+	}
+	return l, nil
+}
+
+//CanPublish reports whether client may publish to t.
+func (l *List) CanPublish(client Client, t topic.Topic) bool {
+	for _, r := range l.rules {
+		if r.Actions&Publish == 0 {
+			continue
+		}
+		levels, ok := r.substitute(client)
+		if !ok {
+			continue
+		}
+		if (topic.Filter{Levels: levels}).Matches(t) {
+			return r.Allow
+		}
+	}
+	return false
+}
+
+//CanSubscribe reports whether client may subscribe to f.
+//An allowing rule only applies if its filter matches every topic f matches,
+//a denying rule already applies if its filter matches any of them.
+//Shared subscriptions are evaluated by the filter following the share name.
+func (l *List) CanSubscribe(client Client, f topic.Filter) bool {
+	for _, r := range l.rules {
+		if r.Actions&Subscribe == 0 {
+			continue
+		}
+		levels, ok := r.substitute(client)
+		if !ok {
+			continue
+		}
+		filter := topic.Filter{Levels: levels}
+		if r.Allow && filter.Covers(f) {
+			return true
+		}
+		if !r.Allow && filter.Overlaps(f) {
+			return false
+		}
+	}
+	return false
+}
+
+//substitute returns the levels of the filter of r with the placeholders substituted for client.
+//It reports false if the rule can't apply to client, because a substituted value is empty or isn't a valid topic level.
+func (r rule) substitute(client Client) ([]string, bool) {
+	if !r.placeholders {
+		return r.levels, true
+	}
+	// all placeholders are substituted in a single pass, a value containing a placeholder isn't substituted again
+	replacer := strings.NewReplacer(ClientIDPlaceholder, client.ClientID, UsernamePlaceholder, client.Username)
+	levels := make([]string, len(r.levels))
+	for i, level := range r.levels {
+		if strings.Contains(level, ClientIDPlaceholder) && !validValue(client.ClientID) {
+			return nil, false
+		}
+		if strings.Contains(level, UsernamePlaceholder) && !validValue(client.Username) {
+			return nil, false
+		}
+		levels[i] = replacer.Replace(level)
+	}
+	return levels, true
+}
+
+//validValue reports whether v can be substituted for a placeholder without widening the rule,
+//i.e. it is a non empty topic level without wildcards.
+func validValue(v string) bool {
+	return v != "" && !strings.ContainsAny(v, topic.Separator+topic.SingleLevelWildcard+topic.MultiLevelWildcard+"\x00")
+}
@@ -0,0 +1,122 @@
+package acl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+func mustList(t *testing.T, rules ...Rule) *List {
+	t.Helper()
+	l, err := NewList(rules...)
+	if err != nil {
+		t.Fatalf("NewList() error = %v", err)
+	}
+	return l
+}
+
+func tenantList(t *testing.T) *List {
+	return mustList(t,
+		Rule{Allow: true, Actions: Publish, Filter: "clients/%c/status"},
+		Rule{Allow: false, Actions: PublishSubscribe, Filter: "tenants/%u/secret/#"},
+		Rule{Allow: true, Actions: PublishSubscribe, Filter: "tenants/%u/#"},
+		Rule{Allow: true, Actions: Subscribe, Filter: "public/+/news"},
+		Rule{Allow: true, Actions: Subscribe, Filter: "$SYS/broker/#"},
+	)
+}
+
+func TestCanPublish(t *testing.T) {
+	alice := Client{ClientID: "c1", Username: "alice"}
+	tests := []struct {
+		name   string
+		client Client
+		topic  string
+		want   bool
+	}{
+		{name: "own status", client: alice, topic: "clients/c1/status", want: true},
+		{name: "status of other client", client: alice, topic: "clients/c2/status"},
+		{name: "own subtree", client: alice, topic: "tenants/alice/a/b", want: true},
+		{name: "own subtree parent", client: alice, topic: "tenants/alice", want: true},
+		{name: "other subtree", client: alice, topic: "tenants/bob/a"},
+		{name: "denied before allowed", client: alice, topic: "tenants/alice/secret/a"},
+		{name: "subscribe only", client: alice, topic: "public/a/news"},
+		{name: "no rule", client: alice, topic: "other"},
+		{name: "no username", client: Client{ClientID: "c1"}, topic: "tenants//a"},
+		{name: "wildcard username", client: Client{ClientID: "c1", Username: "+"}, topic: "tenants/x/a"},
+		{name: "separator in client identifier", client: Client{ClientID: "c1/status/x"}, topic: "clients/c1/status/x/status"},
+		{name: "placeholder in client identifier", client: Client{ClientID: "%u", Username: "bob"}, topic: "clients/%u/status", want: true},
+		{name: "placeholder in client identifier not substituted", client: Client{ClientID: "%u", Username: "bob"}, topic: "clients/bob/status"},
+	}
+
+	l := tenantList(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.CanPublish(tt.client, mustTopic(t, tt.topic)); got != tt.want {
+				t.Errorf("CanPublish() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanSubscribe(t *testing.T) {
+	alice := Client{ClientID: "c1", Username: "alice"}
+	tests := []struct {
+		name   string
+		client Client
+		filter string
+		want   bool
+	}{
+		{name: "own subtree", client: alice, filter: "tenants/alice/a/#", want: true},
+		{name: "own subtree including denied subtree", client: alice, filter: "tenants/alice/#"},
+		{name: "single level in own subtree", client: alice, filter: "tenants/alice/a/+", want: true},
+		{name: "broader than own subtree", client: alice, filter: "tenants/+/#"},
+		{name: "all topics", client: alice, filter: "#"},
+		{name: "overlapping denied subtree", client: alice, filter: "tenants/alice/+/a"},
+		{name: "denied subtree", client: alice, filter: "tenants/alice/secret"},
+		{name: "allowed single level", client: alice, filter: "public/a/news", want: true},
+		{name: "allowed single level wildcard", client: alice, filter: "public/+/news", want: true},
+		{name: "broader than single level", client: alice, filter: "public/#"},
+		{name: "deeper than single level", client: alice, filter: "public/a/b/news"},
+		{name: "publish only", client: alice, filter: "clients/c1/status"},
+		{name: "shared", client: alice, filter: "$share/g/tenants/alice/a", want: true},
+		{name: "dollar topic", client: alice, filter: "$SYS/broker/load", want: true},
+		{name: "no username", client: Client{ClientID: "c1"}, filter: "tenants//a"},
+	}
+
+	l := tenantList(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.CanSubscribe(tt.client, mustFilter(t, tt.filter)); got != tt.want {
+				t.Errorf("CanSubscribe() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewListInvalid(t *testing.T) {
+	if _, err := NewList(Rule{Allow: true, Actions: Publish, Filter: "a/#/b"}); !errors.Is(err, topic.ErrMultiLevelWildcardNotLast) {
+		t.Errorf("NewList() error = %v, want %v", err, topic.ErrMultiLevelWildcardNotLast)
+	}
+	if _, err := NewList(Rule{Allow: true, Actions: Subscribe, Filter: "$share/g/a"}); !errors.Is(err, ErrSharedRule) {
+		t.Errorf("NewList() error = %v, want %v", err, ErrSharedRule)
+	}
+}
+
+func mustTopic(t *testing.T, input string) topic.Topic {
+	t.Helper()
+	tpc, err := topic.ParseTopic(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tpc
+}
+
+func mustFilter(t *testing.T, input string) topic.Filter {
+	t.Helper()
+	filter, err := topic.ParseFilter(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filter
+}
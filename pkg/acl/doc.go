@@ -0,0 +1,9 @@
+package acl
+
+/*
+Package acl evaluates per client publish and subscribe permissions expressed as topic filters.
+Rules may contain the placeholders %c and %u for the client identifier and the user name of the client,
+e.g. to confine every tenant to its own subtree.
+The rules are evaluated in order, the first applicable one decides; everything else is denied.
+Rules can be loaded from a simple text file, Hooks plugs a List into a broker.Server.
+*/
@@ -0,0 +1,49 @@
+package acl
+
+import (
+	"github.com/squ94wk/mqtt-common/pkg/broker"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//Hooks enforces a List in a broker.Server.
+//Denied subscriptions are rejected with SubackNotAuthorized, denied messages with PubackNotAuthorized
+//and will messages the client may not publish are dropped.
+type Hooks struct {
+	broker.NoopHooks
+	List *List
+}
+
+//OnSubscribe implements broker.Hooks.
+func (h Hooks) OnSubscribe(client broker.ClientInfo, subscribe packet.Subscribe) []packet.SubackReason {
+	reasons := make([]packet.SubackReason, len(subscribe.Filters))
+	for i, sub := range subscribe.Filters {
+		reasons[i] = packet.SubackReason(sub.MaxQoS)
+		filter, err := topic.ParseFilter(sub.Filter)
+		if err != nil {
+			// the broker rejects invalid filters itself
+			continue
+		}
+		if !h.List.CanSubscribe(clientOf(client), filter) {
+			reasons[i] = packet.SubackNotAuthorized
+		}
+	}
+	return reasons
+}
+
+//OnPublish implements broker.Hooks.
+func (h Hooks) OnPublish(client broker.ClientInfo, publish packet.Publish) (packet.Publish, packet.PubackReason) {
+	if !h.List.CanPublish(clientOf(client), publish.Topic) {
+		return publish, packet.PubackNotAuthorized
+	}
+	return publish, packet.PubackSuccess
+}
+
+//OnWillPublish implements broker.Hooks.
+func (h Hooks) OnWillPublish(client broker.ClientInfo, will packet.Publish) (packet.Publish, bool) {
+	return will, h.List.CanPublish(clientOf(client), will.Topic)
+}
+
+func clientOf(info broker.ClientInfo) Client {
+	return Client{ClientID: info.ClientID, Username: info.Username}
+}
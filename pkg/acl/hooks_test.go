@@ -0,0 +1,35 @@
+package acl
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/broker"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+func TestHooks(t *testing.T) {
+	hooks := Hooks{List: tenantList(t)}
+	alice := broker.ClientInfo{ClientID: "c1", Username: "alice"}
+
+	reasons := hooks.OnSubscribe(alice, packet.Subscribe{Filters: []packet.SubscriptionFilter{
+		{Filter: "tenants/alice/a/#", MaxQoS: packet.Qos1},
+		{Filter: "tenants/#", MaxQoS: packet.Qos1},
+	}})
+	if diff := deep.Equal(reasons, []packet.SubackReason{packet.SubackQoS1Granted, packet.SubackNotAuthorized}); diff != nil {
+		t.Error(diff)
+	}
+
+	publish := packet.Publish{Topic: mustTopic(t, "tenants/bob/a")}
+	if _, reason := hooks.OnPublish(alice, publish); reason != packet.PubackNotAuthorized {
+		t.Errorf("OnPublish() reason = %v, want %v", reason, packet.PubackNotAuthorized)
+	}
+	publish.Topic = mustTopic(t, "tenants/alice/a")
+	if _, reason := hooks.OnPublish(alice, publish); reason != packet.PubackSuccess {
+		t.Errorf("OnPublish() reason = %v, want %v", reason, packet.PubackSuccess)
+	}
+
+	if _, ok := hooks.OnWillPublish(alice, packet.Publish{Topic: mustTopic(t, "clients/c2/status")}); ok {
+		t.Error("OnWillPublish() = true for the status of another client")
+	}
+}
@@ -0,0 +1,108 @@
+package acl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+//Reasons a line of a rule file can be rejected for.
+//They are wrapped by ParseError and can be compared with errors.Is.
+var (
+	ErrFieldCount    = errors.New("rules must consist of permission, action and topic filter")
+	ErrPermission    = errors.New("permission must be 'allow' or 'deny'")
+	ErrUnknownAction = errors.New("action must be 'publish', 'subscribe' or 'pubsub'")
+)
+
+//ParseError is returned if a line of a rule file is invalid.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+//Error implements the error interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("acl: line %d: %v", e.Line, e.Err)
+}
+
+//Unwrap returns the reason the line was rejected for.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+//Parse reads a List from r.
+//Every line holds a rule of the form '{allow|deny} {publish|subscribe|pubsub} {topic filter}'.
+//Empty lines and lines starting with '#' are ignored.
+//
+//	# every client may publish its own status
+//	allow publish clients/%c/status
+//	# tenants are confined to their own subtree
+//	allow pubsub tenants/%u/#
+//	deny subscribe #
+func Parse(r io.Reader) (*List, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseRule(line)
+		if err != nil {
+			return nil, &ParseError{Line: n, Err: err}
+		}
+		// the filter is validated per line to report the line number
+		if _, err := NewList(rule); err != nil {
+			return nil, &ParseError{Line: n, Err: err}
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewList(rules...)
+}
+
+//Load reads a List from the file at path, see Parse for its format.
+func Load(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+func parseRule(line string) (Rule, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return Rule{}, ErrFieldCount
+	}
+
+	var rule Rule
+	switch fields[0] {
+	case "allow":
+		rule.Allow = true
+	case "deny":
+	default:
+		return Rule{}, ErrPermission
+	}
+
+	switch fields[1] {
+	case Publish.String():
+		rule.Actions = Publish
+	case Subscribe.String():
+		rule.Actions = Subscribe
+	case PublishSubscribe.String():
+		rule.Actions = PublishSubscribe
+	default:
+		return Rule{}, ErrUnknownAction
+	}
+
+	rule.Filter = fields[2]
+	return rule, nil
+}
@@ -0,0 +1,86 @@
+package acl
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+func TestParse(t *testing.T) {
+	input := `
+# comment
+allow publish clients/%c/status
+  deny   pubsub tenants/%u/secret/#
+allow subscribe public/#
+`
+	l, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	var got []Rule
+	for _, r := range l.rules {
+		got = append(got, r.Rule)
+	}
+	want := []Rule{
+		{Allow: true, Actions: Publish, Filter: "clients/%c/status"},
+		{Allow: false, Actions: PublishSubscribe, Filter: "tenants/%u/secret/#"},
+		{Allow: true, Actions: Subscribe, Filter: "public/#"},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+		want  error
+	}{
+		{name: "missing filter", input: "allow publish", line: 1, want: ErrFieldCount},
+		{name: "too many fields", input: "# ok\n\nallow publish a b", line: 3, want: ErrFieldCount},
+		{name: "permission", input: "permit publish a", line: 1, want: ErrPermission},
+		{name: "action", input: "allow read a", line: 1, want: ErrUnknownAction},
+		{name: "filter", input: "allow publish a\nallow publish a+", line: 2, want: topic.ErrWildcardNotWholeLevel},
+		{name: "shared", input: "deny subscribe $share/g/#", line: 1, want: ErrSharedRule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Line != tt.line || !errors.Is(err, tt.want) {
+				t.Errorf("Parse() error = %v, want %v in line %d", err, tt.want, tt.line)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "acl")
+	if err := ioutil.WriteFile(path, []byte("allow pubsub #\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !l.CanPublish(Client{ClientID: "c"}, mustTopic(t, "a")) {
+		t.Error("CanPublish() = false")
+	}
+	if _, err := Load(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("Load() error = %v, want not exist", err)
+	}
+}
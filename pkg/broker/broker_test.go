@@ -0,0 +1,583 @@
+package broker
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/queue"
+	"github.com/squ94wk/mqtt-common/pkg/store"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//startServer runs a Server with a fake clock and hooks on localhost.
+//Closing the Server closes all connections of the test.
+func startServer(t *testing.T, hooks ...Hooks) (*Server, *clock.Fake, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	clk := clock.NewFake(time.Unix(0, 0))
+	s := NewServer(clk)
+	s.AddHooks(hooks...)
+	go s.Serve(l)
+	return s, clk, l.Addr().String()
+}
+
+type testClient struct {
+	t    *testing.T
+	net  net.Conn
+	conn *packet.Conn
+}
+
+func dial(t *testing.T, addr string, connect packet.Connect) (*testClient, *packet.Connack) {
+	t.Helper()
+	netConn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	c := &testClient{t: t, net: netConn, conn: packet.NewConn(netConn)}
+
+	if connect.Props == nil {
+		connect.Props = packet.NewProperties()
+	}
+	if connect.Payload.WillTopic != "" && connect.Payload.WillProps == nil {
+		connect.Payload.WillProps = packet.NewProperties()
+	}
+	c.send(&connect)
+	connack, ok := c.expect().(*packet.Connack)
+	if !ok {
+		t.Fatal("expected connack")
+	}
+	return c, connack
+}
+
+func (c *testClient) send(pkt packet.Packet) {
+	c.t.Helper()
+	if err := c.conn.WritePacket(pkt); err != nil {
+		c.t.Fatalf("WritePacket() error = %v", err)
+	}
+}
+
+func (c *testClient) expect() packet.Packet {
+	c.t.Helper()
+	if err := c.conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		c.t.Fatal(err)
+	}
+	pkt, err := c.conn.ReadPacket()
+	if err != nil {
+		c.t.Fatalf("ReadPacket() error = %v", err)
+	}
+	return pkt
+}
+
+func (c *testClient) expectPublish(payload string) *packet.Publish {
+	c.t.Helper()
+	pkt := c.expect()
+	publish, ok := pkt.(*packet.Publish)
+	if !ok {
+		c.t.Fatalf("received %T, want publish", pkt)
+	}
+	if string(publish.Payload) != payload {
+		c.t.Fatalf("received publish with payload %q, want %q", publish.Payload, payload)
+	}
+	return publish
+}
+
+func (c *testClient) subscribe(props packet.Properties, filters ...packet.SubscriptionFilter) *packet.Suback {
+	c.t.Helper()
+	if props == nil {
+		props = packet.NewProperties()
+	}
+	c.send(&packet.Subscribe{PacketID: 1, Props: props, Filters: filters})
+	suback, ok := c.expect().(*packet.Suback)
+	if !ok {
+		c.t.Fatal("expected suback")
+	}
+	return suback
+}
+
+func (c *testClient) publish(qos byte, retain bool, name, payload string) {
+	c.t.Helper()
+	t, err := topic.ParseTopic(name)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	publish := &packet.Publish{Qos: qos, Retain: retain, Topic: t, Props: packet.NewProperties(), Payload: []byte(payload)}
+	if qos > packet.Qos0 {
+		publish.PacketID = 1
+	}
+	c.send(publish)
+
+	switch qos {
+	case packet.Qos1:
+		if _, ok := c.expect().(*packet.Puback); !ok {
+			c.t.Fatal("expected puback")
+		}
+	case packet.Qos2:
+		if _, ok := c.expect().(*packet.Pubrec); !ok {
+			c.t.Fatal("expected pubrec")
+		}
+		c.send(&packet.Pubrel{PacketID: 1, Props: packet.NewProperties()})
+		if _, ok := c.expect().(*packet.Pubcomp); !ok {
+			c.t.Fatal("expected pubcomp")
+		}
+	}
+}
+
+func sessionExpiry(seconds uint32) packet.Properties {
+	return packet.NewProperties(packet.NewProperty(packet.SessionExpiryInterval, packet.Int32PropPayload(seconds)))
+}
+
+func TestConnectAssignsClientID(t *testing.T) {
+	s, _, addr := startServer(t)
+	defer s.Close()
+	_, connack := dial(t, addr, packet.Connect{CleanStart: true})
+
+	if connack.ConnectReason != packet.ConnectSuccess || connack.SessionPresent {
+		t.Errorf("connack = %+v", connack)
+	}
+	if ids := connack.Props[packet.AssignedClientIdentifier]; len(ids) != 1 {
+		t.Errorf("connack without assigned client identifier: %v", connack.Props)
+	}
+}
+
+func TestResponseInformation(t *testing.T) {
+	s, _, addr := startServer(t)
+	defer s.Close()
+	props := packet.NewProperties(packet.NewProperty(packet.RequestResponseInformation, packet.BytePropPayload(1)))
+	_, connack := dial(t, addr, packet.Connect{CleanStart: true, Props: props, Payload: packet.ConnectPayload{ClientID: "c"}})
+
+	if info, _ := connack.Props.StringProp(packet.ResponseInformation); info != "response/c" {
+		t.Errorf("response information = %q, want %q", info, "response/c")
+	}
+}
+
+func TestPublishQos1(t *testing.T) {
+	s, _, addr := startServer(t)
+	defer s.Close()
+	sub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "sub"}})
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+
+	props := packet.NewProperties(packet.NewProperty(packet.SubscriptionIdentifier, packet.VarIntPropPayload(7)))
+	suback := sub.subscribe(props, packet.SubscriptionFilter{Filter: "a/+/c", MaxQoS: packet.Qos1})
+	if len(suback.Reasons) != 1 || suback.Reasons[0] != packet.SubackQoS1Granted {
+		t.Fatalf("suback reasons = %v", suback.Reasons)
+	}
+
+	pub.publish(packet.Qos2, false, "a/b/c", "hello")
+	publish := sub.expectPublish("hello")
+	if publish.Qos != packet.Qos1 || publish.PacketID == 0 {
+		t.Errorf("publish = %+v, want QoS 1 with packet identifier", publish)
+	}
+	if ids := publish.Props.VarInt(packet.SubscriptionIdentifier); len(ids) != 1 || ids[0] != 7 {
+		t.Errorf("subscription identifiers = %v, want [7]", ids)
+	}
+	sub.send(&packet.Puback{PacketID: publish.PacketID, Props: packet.NewProperties()})
+
+	pub.publish(packet.Qos0, false, "a/b/d", "not matching")
+	pub.publish(packet.Qos0, false, "a/x/c", "second")
+	sub.expectPublish("second")
+}
+
+func TestPublishQos2(t *testing.T) {
+	s, _, addr := startServer(t)
+	defer s.Close()
+	sub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "sub"}})
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+
+	sub.subscribe(nil, packet.SubscriptionFilter{Filter: "#", MaxQoS: packet.Qos2})
+	pub.publish(packet.Qos2, false, "a", "exactly once")
+
+	publish := sub.expectPublish("exactly once")
+	if publish.Qos != packet.Qos2 {
+		t.Fatalf("publish QoS = %d, want 2", publish.Qos)
+	}
+	sub.send(&packet.Pubrec{PacketID: publish.PacketID, Props: packet.NewProperties()})
+	if pubrel, ok := sub.expect().(*packet.Pubrel); !ok || pubrel.PacketID != publish.PacketID {
+		t.Fatal("expected pubrel")
+	}
+	sub.send(&packet.Pubcomp{PacketID: publish.PacketID, Props: packet.NewProperties()})
+}
+
+func TestRetained(t *testing.T) {
+	s, _, addr := startServer(t)
+	defer s.Close()
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+	pub.publish(packet.Qos1, true, "status/a", "online")
+
+	sub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "sub"}})
+	sub.subscribe(nil, packet.SubscriptionFilter{Filter: "status/#", MaxQoS: packet.Qos0})
+	if publish := sub.expectPublish("online"); !publish.Retain {
+		t.Error("retained message sent without retain flag")
+	}
+
+	pub.publish(packet.Qos0, true, "status/a", "offline")
+	if publish := sub.expectPublish("offline"); publish.Retain {
+		t.Error("forwarded message kept retain flag without retain as published")
+	}
+}
+
+func TestRetainedExpiry(t *testing.T) {
+	s, clk, addr := startServer(t)
+	defer s.Close()
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+	tpc, _ := topic.ParseTopic("status/a")
+	pub.send(&packet.Publish{
+		Qos:      packet.Qos1,
+		Retain:   true,
+		PacketID: 1,
+		Topic:    tpc,
+		Props:    packet.NewProperties(packet.NewProperty(packet.MessageExpiryInterval, packet.Int32PropPayload(10))),
+		Payload:  []byte("online"),
+	})
+	if _, ok := pub.expect().(*packet.Puback); !ok {
+		t.Fatal("expected puback")
+	}
+
+	clk.Advance(4 * time.Second)
+	sub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "sub"}})
+	sub.subscribe(nil, packet.SubscriptionFilter{Filter: "status/a", MaxQoS: packet.Qos0})
+	publish := sub.expectPublish("online")
+	if interval, _ := publish.Props.Int32(packet.MessageExpiryInterval); interval != 6 {
+		t.Errorf("message expiry interval = %d, want 6", interval)
+	}
+
+	clk.Advance(6 * time.Second)
+	late, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "late"}})
+	late.subscribe(nil, packet.SubscriptionFilter{Filter: "status/a", MaxQoS: packet.Qos0})
+	// the expired retained message isn't sent, the next one received is a new message
+	pub.publish(packet.Qos0, false, "status/a", "new")
+	late.expectPublish("new")
+}
+
+func TestSharedSubscription(t *testing.T) {
+	s, _, addr := startServer(t)
+	defer s.Close()
+	first, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "first"}})
+	second, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "second"}})
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+
+	first.subscribe(nil, packet.SubscriptionFilter{Filter: "$share/g/jobs"})
+	second.subscribe(nil, packet.SubscriptionFilter{Filter: "$share/g/jobs"})
+	pub.publish(packet.Qos0, false, "jobs", "1")
+	pub.publish(packet.Qos0, false, "jobs", "2")
+
+	// round robin delivers one message to each member of the group
+	got := map[string]bool{}
+	for _, c := range []*testClient{first, second} {
+		publish, ok := c.expect().(*packet.Publish)
+		if !ok {
+			t.Fatal("expected publish")
+		}
+		got[string(publish.Payload)] = true
+	}
+	if !got["1"] || !got["2"] {
+		t.Errorf("received %v, want both messages once", got)
+	}
+}
+
+func TestWill(t *testing.T) {
+	s, _, addr := startServer(t)
+	defer s.Close()
+	sub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "sub"}})
+	sub.subscribe(nil, packet.SubscriptionFilter{Filter: "will/+"})
+
+	will := packet.ConnectPayload{WillTopic: "will/a", WillPayload: []byte("gone")}
+	will.ClientID = "a"
+	normal, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: will})
+	normal.send(&packet.Disconnect{Reason: packet.DisconnectNormalDisconnection})
+
+	will.ClientID = "b"
+	will.WillTopic = "will/b"
+	lost, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: will})
+	lost.net.Close()
+
+	// the will of the normal disconnect is discarded
+	if publish := sub.expectPublish("gone"); publish.Topic.String() != "will/b" {
+		t.Errorf("will published to %s, want will/b", publish.Topic)
+	}
+}
+
+func TestWillDelay(t *testing.T) {
+	s, clk, addr := startServer(t)
+	defer s.Close()
+	sub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "sub"}})
+	sub.subscribe(nil, packet.SubscriptionFilter{Filter: "will"})
+
+	connect := packet.Connect{
+		Props: sessionExpiry(60),
+		Payload: packet.ConnectPayload{
+			ClientID:    "a",
+			WillTopic:   "will",
+			WillPayload: []byte("gone"),
+			WillProps:   packet.NewProperties(packet.NewProperty(packet.WillDelayInterval, packet.Int32PropPayload(30))),
+		},
+	}
+	a, _ := dial(t, addr, connect)
+	a.send(&packet.Disconnect{Reason: packet.DisconnectDisconnectWithWillMessage})
+	waitOffline(t, s, "a")
+
+	// reconnecting in time cancels the will
+	clk.Advance(29 * time.Second)
+	a, _ = dial(t, addr, connect)
+	a.send(&packet.Disconnect{Reason: packet.DisconnectDisconnectWithWillMessage})
+	waitOffline(t, s, "a")
+
+	clk.Advance(30 * time.Second)
+	sub.expectPublish("gone")
+}
+
+func TestWillDelayLongerThanSessionExpiry(t *testing.T) {
+	s, clk, addr := startServer(t)
+	defer s.Close()
+	sub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "sub"}})
+	sub.subscribe(nil, packet.SubscriptionFilter{Filter: "will"})
+
+	connect := packet.Connect{
+		Props: sessionExpiry(10),
+		Payload: packet.ConnectPayload{
+			ClientID:    "a",
+			WillTopic:   "will",
+			WillPayload: []byte("gone"),
+			WillProps:   packet.NewProperties(packet.NewProperty(packet.WillDelayInterval, packet.Int32PropPayload(60))),
+		},
+	}
+	a, _ := dial(t, addr, connect)
+	a.net.Close()
+	waitOffline(t, s, "a")
+
+	// 3.1.3.2.2 the will is published when the session ends before the will delay interval passed
+	clk.Advance(10 * time.Second)
+	sub.expectPublish("gone")
+	if _, connack := dial(t, addr, connect); connack.SessionPresent {
+		t.Error("session present after it expired")
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	s, clk, addr := startServer(t)
+	defer s.Close()
+	connect := packet.Connect{Props: sessionExpiry(60), Payload: packet.ConnectPayload{ClientID: "a"}}
+	a, connack := dial(t, addr, connect)
+	if connack.SessionPresent {
+		t.Fatal("session present for new client")
+	}
+	a.subscribe(nil, packet.SubscriptionFilter{Filter: "t", MaxQoS: packet.Qos1})
+	a.send(&packet.Disconnect{})
+	waitOffline(t, s, "a")
+
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+	pub.publish(packet.Qos0, false, "t", "dropped while offline")
+	pub.publish(packet.Qos1, false, "t", "queued")
+
+	a, connack = dial(t, addr, connect)
+	if !connack.SessionPresent {
+		t.Fatal("session not present after reconnect")
+	}
+	publish := a.expectPublish("queued")
+	a.send(&packet.Puback{PacketID: publish.PacketID, Props: packet.NewProperties()})
+	a.send(&packet.Disconnect{})
+	waitOffline(t, s, "a")
+
+	clk.Advance(time.Minute)
+	if _, connack = dial(t, addr, connect); connack.SessionPresent {
+		t.Error("session present after it expired")
+	}
+}
+
+func TestMessageExpiry(t *testing.T) {
+	s, clk, addr := startServer(t)
+	defer s.Close()
+	connect := packet.Connect{Props: sessionExpiry(60), Payload: packet.ConnectPayload{ClientID: "a"}}
+	a, _ := dial(t, addr, connect)
+	a.subscribe(nil, packet.SubscriptionFilter{Filter: "t", MaxQoS: packet.Qos1})
+	a.send(&packet.Disconnect{})
+	waitOffline(t, s, "a")
+
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+	tpc, _ := topic.ParseTopic("t")
+	for i, msg := range []struct {
+		payload  string
+		interval uint32
+	}{{"expired", 10}, {"remaining", 30}} {
+		pub.send(&packet.Publish{
+			Qos:      packet.Qos1,
+			PacketID: uint16(i + 1),
+			Topic:    tpc,
+			Props:    packet.NewProperties(packet.NewProperty(packet.MessageExpiryInterval, packet.Int32PropPayload(msg.interval))),
+			Payload:  []byte(msg.payload),
+		})
+		if _, ok := pub.expect().(*packet.Puback); !ok {
+			t.Fatal("expected puback")
+		}
+	}
+
+	clk.Advance(20 * time.Second)
+	a, _ = dial(t, addr, connect)
+	publish := a.expectPublish("remaining")
+	if interval, _ := publish.Props.Int32(packet.MessageExpiryInterval); interval != 10 {
+		t.Errorf("message expiry interval = %d, want 10", interval)
+	}
+}
+
+func TestOfflineQueueLimit(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := NewServer(clock.NewFake(time.Unix(0, 0)))
+	s.SetQueueOptions(queue.Options{MaxCount: 2, Policy: queue.DropOldest})
+	go s.Serve(l)
+	defer s.Close()
+	addr := l.Addr().String()
+
+	connect := packet.Connect{Props: sessionExpiry(60), Payload: packet.ConnectPayload{ClientID: "a"}}
+	a, _ := dial(t, addr, connect)
+	a.subscribe(nil, packet.SubscriptionFilter{Filter: "t", MaxQoS: packet.Qos1})
+	a.send(&packet.Disconnect{})
+	waitOffline(t, s, "a")
+
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+	for _, payload := range []string{"1", "2", "3"} {
+		pub.publish(packet.Qos1, false, "t", payload)
+	}
+
+	a, _ = dial(t, addr, connect)
+	for _, payload := range []string{"2", "3"} {
+		publish := a.expectPublish(payload)
+		a.send(&packet.Puback{PacketID: publish.PacketID, Props: packet.NewProperties()})
+	}
+}
+
+func TestResendWithinReceiveMaximum(t *testing.T) {
+	s, _, addr := startServer(t)
+	defer s.Close()
+	connect := packet.Connect{Props: sessionExpiry(60), Payload: packet.ConnectPayload{ClientID: "a"}}
+	a, _ := dial(t, addr, connect)
+	a.subscribe(nil, packet.SubscriptionFilter{Filter: "t", MaxQoS: packet.Qos1})
+
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+	pub.publish(packet.Qos1, false, "t", "1")
+	pub.publish(packet.Qos1, false, "t", "2")
+	a.expectPublish("1")
+	a.expectPublish("2")
+	a.net.Close()
+	waitOffline(t, s, "a")
+
+	connect.Props.Add(packet.NewProperty(packet.ReceiveMaximum, packet.Int16PropPayload(1)))
+	a, _ = dial(t, addr, connect)
+	first := a.expectPublish("1")
+	// the second message is resent only once the first one is acknowledged
+	if err := a.conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if pkt, err := a.conn.ReadPacket(); err == nil {
+		t.Fatalf("received %v beyond the receive maximum", pkt)
+	}
+	a.send(&packet.Puback{PacketID: first.PacketID, Props: packet.NewProperties()})
+	a.expectPublish("2")
+}
+
+func TestSessionTakeover(t *testing.T) {
+	s, _, addr := startServer(t)
+	defer s.Close()
+	connect := packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "a"}}
+	old, _ := dial(t, addr, connect)
+	dial(t, addr, connect)
+
+	disconnect, ok := old.expect().(*packet.Disconnect)
+	if !ok || disconnect.Reason != packet.DisconnectSessionTakenOver {
+		t.Errorf("old connection received %v, want disconnect with session taken over", disconnect)
+	}
+}
+
+func TestProtocolViolation(t *testing.T) {
+	s, _, addr := startServer(t)
+	defer s.Close()
+	c, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "a"}})
+	c.send(&packet.Connect{Props: packet.NewProperties()})
+
+	disconnect, ok := c.expect().(*packet.Disconnect)
+	if !ok || disconnect.Reason != packet.DisconnectProtocolError {
+		t.Errorf("received %v, want disconnect with protocol error", disconnect)
+	}
+}
+
+//serveWithStore runs a Server with sessions restored from sessionStore and a fake clock set to now on localhost.
+func serveWithStore(t *testing.T, sessionStore store.SessionStore, now time.Time) (*Server, string) {
+	t.Helper()
+	s := NewServer(clock.NewFake(now))
+	if err := s.UseSessionStore(sessionStore); err != nil {
+		t.Fatalf("UseSessionStore() error = %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.Serve(l)
+	return s, l.Addr().String()
+}
+
+func TestSessionStore(t *testing.T) {
+	sessionStore := store.NewMemoryStore()
+	s, addr := serveWithStore(t, sessionStore, time.Unix(0, 0))
+	connect := packet.Connect{Props: sessionExpiry(60), Payload: packet.ConnectPayload{ClientID: "a"}}
+	a, _ := dial(t, addr, connect)
+	props := packet.NewProperties(packet.NewProperty(packet.SubscriptionIdentifier, packet.VarIntPropPayload(5)))
+	a.subscribe(props, packet.SubscriptionFilter{Filter: "t", MaxQoS: packet.Qos1})
+
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+	pub.publish(packet.Qos1, false, "t", "in flight")
+	inFlight := a.expectPublish("in flight")
+	a.net.Close()
+	waitOffline(t, s, "a")
+	pub.publish(packet.Qos1, false, "t", "queued")
+	s.Close()
+
+	// the session expires 60 seconds after the client disconnected
+	s, addr = serveWithStore(t, sessionStore, time.Unix(30, 0))
+	a, connack := dial(t, addr, connect)
+	if !connack.SessionPresent {
+		t.Fatal("session not present after restart")
+	}
+	if resent := a.expectPublish("in flight"); !resent.Dup || resent.PacketID != inFlight.PacketID {
+		t.Errorf("resent publish = %+v, want DUP with packet identifier %d", resent, inFlight.PacketID)
+	}
+	queued := a.expectPublish("queued")
+	if ids := queued.Props.VarInt(packet.SubscriptionIdentifier); len(ids) != 1 || ids[0] != 5 {
+		t.Errorf("subscription identifiers = %v, want [5]", ids)
+	}
+	a.send(&packet.Disconnect{})
+	waitOffline(t, s, "a")
+	s.Close()
+
+	s, addr = serveWithStore(t, sessionStore, time.Unix(91, 0))
+	defer s.Close()
+	if sessions, _ := sessionStore.Load(); len(sessions) != 0 {
+		t.Errorf("expired sessions still stored: %v", sessions)
+	}
+	if _, connack := dial(t, addr, connect); connack.SessionPresent {
+		t.Error("session present after it expired")
+	}
+}
+
+//waitOffline waits until the server noticed the network connection of clientID was closed.
+func waitOffline(t *testing.T, s *Server, clientID string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		sess, ok := s.sessions[clientID]
+		online := ok && sess.conn != nil
+		s.mu.Unlock()
+		if !online {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("client %s is still online", clientID)
+}
@@ -0,0 +1,512 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/expiry"
+	"github.com/squ94wk/mqtt-common/pkg/keepalive"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/qos"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+const (
+	//connectTimeout bounds the time until the connect control packet has to be received.
+	connectTimeout = 10 * time.Second
+	//receiveMaximum is the number of QoS 1 and QoS 2 messages the server processes concurrently per client.
+	receiveMaximum = 1024
+	//topicAliasMaximum is the highest topic alias the server accepts from clients.
+	topicAliasMaximum = 64
+	//responseTopicPrefix followed by the client identifier is sent as response information if the client requests it.
+	responseTopicPrefix = "response/"
+)
+
+//errDisconnected ends the read loop after the client sent a disconnect control packet.
+var errDisconnected = errors.New("client disconnected")
+
+//conn serves a single network connection.
+type conn struct {
+	server *Server
+	net    net.Conn
+	pkts   *packet.Conn
+	state  *packet.Validator
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// set while handling the connect control packet
+	info         ClientInfo
+	session      *session
+	aliases      *packet.InboundAliases
+	sendQuota    *qos.SendQuota
+	receiveQuota *qos.ReceiveQuota
+	// resend are the control packets in flight of a resumed session, sent by the writer before anything else
+	resend []packet.Packet
+
+	mu        sync.Mutex
+	keepAlive *keepalive.Server
+	connected bool
+
+	// disconnect is the disconnect control packet sent by the client
+	disconnect *packet.Disconnect
+
+	// protected by the mutex of the Server
+	will *expiry.Will
+
+	closeOnce sync.Once
+}
+
+func newConn(server *Server, netConn net.Conn) *conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &conn{
+		server:       server,
+		net:          netConn,
+		pkts:         packet.NewConn(netConn),
+		state:        packet.NewValidator(packet.RoleServer),
+		ctx:          ctx,
+		cancel:       cancel,
+		aliases:      packet.NewInboundAliases(topicAliasMaximum),
+		receiveQuota: qos.NewReceiveQuota(receiveMaximum),
+	}
+}
+
+func (c *conn) serve() {
+	defer c.server.detach(c)
+
+	if err := c.net.SetReadDeadline(time.Now().Add(connectTimeout)); err != nil {
+		c.close(0)
+		return
+	}
+	pkt, err := c.read()
+	if err != nil {
+		// 3.1.4 the network connection is closed without connack
+		c.close(0)
+		return
+	}
+	if err := c.net.SetReadDeadline(time.Time{}); err != nil {
+		c.close(0)
+		return
+	}
+	if err := c.handleConnect(pkt.(*packet.Connect)); err != nil {
+		c.close(0)
+		return
+	}
+	go c.writeLoop()
+
+	err = c.readLoop()
+	c.server.hooks.OnDisconnect(c.info, c.disconnect, err)
+}
+
+//readLoop handles received control packets until the network connection is closed.
+//It returns the reason, nil if the client sent a disconnect control packet.
+func (c *conn) readLoop() error {
+	for {
+		pkt, err := c.read()
+		if err != nil {
+			c.close(disconnectReason(err))
+			return err
+		}
+		c.received()
+
+		if err := c.handle(pkt); err != nil {
+			if err == errDisconnected {
+				c.close(0)
+				return nil
+			}
+			c.close(disconnectReason(err))
+			return err
+		}
+	}
+}
+
+//disconnectReason returns the reason code of the disconnect control packet sent for err, 0 if none can be sent.
+func disconnectReason(err error) packet.DisconnectReason {
+	var disconnectErr *packet.DisconnectError
+	if errors.As(err, &disconnectErr) {
+		return disconnectErr.Reason
+	}
+	var netErr net.Error
+	if err == io.EOF || errors.As(err, &netErr) {
+		return 0
+	}
+	return packet.DisconnectMalformedPacket
+}
+
+func (c *conn) read() (packet.Packet, error) {
+	pkt, err := c.pkts.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.state.Receive(pkt); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
+
+func (c *conn) write(pkt packet.Packet) error {
+	if err := c.state.Send(pkt); err != nil {
+		return err
+	}
+	return c.pkts.WritePacket(pkt)
+}
+
+//close closes the network connection.
+//A disconnect control packet with reason is sent unless reason is 0 or no successful connack was sent (4.13).
+func (c *conn) close(reason packet.DisconnectReason) {
+	c.closeOnce.Do(func() {
+		c.cancel()
+
+		c.mu.Lock()
+		if c.keepAlive != nil {
+			c.keepAlive.Stop()
+		}
+		connected := c.connected
+		c.mu.Unlock()
+
+		if reason != 0 && connected {
+			if err := c.pkts.Close(reason); err != nil {
+				log.Printf("broker: failed to close connection: %v", err)
+			}
+			return
+		}
+		c.net.Close()
+	})
+}
+
+//received postpones the keep alive timeout.
+func (c *conn) received() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.keepAlive.Received()
+}
+
+//takeWill returns the will message and clears it; the mutex of the Server must be held.
+func (c *conn) takeWill() *expiry.Will {
+	will := c.will
+	c.will = nil
+	return will
+}
+
+func (c *conn) handleConnect(connect *packet.Connect) error {
+	connack := packet.Connack{ConnectReason: packet.ConnectSuccess, Props: packet.NewProperties()}
+	refuse := func(reason packet.ConnectReason, err error) error {
+		connack.ConnectReason = reason
+		if writeErr := c.write(connack); writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+
+	clientMax, err := qos.ReceiveMaximum(connect.Props)
+	if err != nil {
+		return refuse(packet.ConnectProtocolError, err)
+	}
+	c.sendQuota = qos.NewSendQuota(clientMax)
+
+	if c.will, err = expiry.NewWill(*connect); err != nil {
+		return refuse(packet.ConnectTopicNameInvalid, err)
+	}
+
+	clientID := connect.Payload.ClientID
+	assigned := clientID == ""
+	if assigned {
+		clientID = newClientID()
+	}
+	c.info = ClientInfo{ClientID: clientID, Username: connect.Payload.Username, RemoteAddr: c.net.RemoteAddr()}
+	if reason := c.server.hooks.OnAuth(c.info, *connect); reason >= 0x80 {
+		return refuse(reason, fmt.Errorf("authentication of client '%s' failed", clientID))
+	}
+	if reason := c.server.hooks.OnConnect(c.info, *connect); reason >= 0x80 {
+		return refuse(reason, fmt.Errorf("connection of client '%s' refused", clientID))
+	}
+	if assigned {
+		connack.Props.Add(packet.NewProperty(packet.AssignedClientIdentifier, packet.StringPropPayload(clientID)))
+	}
+	if requested, _ := connect.Props.Byte(packet.RequestResponseInformation); requested == 1 {
+		// 3.2.2.3.15 the basis of response topics for the client
+		connack.Props.Add(packet.NewProperty(packet.ResponseInformation, packet.StringPropPayload(responseTopicPrefix+clientID)))
+	}
+	sessionExpiry, _ := connect.Props.Int32(packet.SessionExpiryInterval)
+
+	sess, present := c.server.attach(c, clientID, connect.CleanStart, sessionExpiry)
+	c.session = sess
+	connack.SessionPresent = present
+	connack.Props.Add(packet.NewProperty(packet.ReceiveMaximum, packet.Int16PropPayload(receiveMaximum)))
+	connack.Props.Add(packet.NewProperty(packet.TopicAliasMaximum, packet.Int16PropPayload(topicAliasMaximum)))
+	if err := c.write(connack); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.connected = true
+	c.keepAlive = keepalive.NewServer(c.server.clk, time.Duration(connect.KeepAlive)*time.Second, func(err error) {
+		c.close(disconnectReason(err))
+	})
+	c.mu.Unlock()
+
+	// 4.4 messages in flight are resent when a session is resumed
+	if present {
+		c.resend = sess.sender.Resend()
+	}
+	return nil
+}
+
+//writeLoop sends the messages queued for the session until the network connection is closed.
+func (c *conn) writeLoop() {
+	sess := c.session
+	// 4.9 resent publish control packets count against the receive maximum of the client as well
+	for _, pkt := range c.resend {
+		if _, ok := pkt.(*packet.Publish); ok {
+			if err := c.sendQuota.Acquire(c.ctx); err != nil {
+				return
+			}
+		}
+		if err := c.write(pkt); err != nil {
+			return
+		}
+	}
+	c.resend = nil
+
+	for {
+		select {
+		case <-sess.notify:
+		case <-c.ctx.Done():
+			return
+		}
+
+		for c.ctx.Err() == nil {
+			// 3.3.2.3.3 expired messages are dropped, the others are sent with the remaining message expiry interval
+			now := c.server.clk.Now()
+			msg, ok := sess.pop(now)
+			if !ok {
+				break
+			}
+			publish, _ := msg.Outbound(now)
+			if publish.Qos > packet.Qos0 {
+				if err := c.sendQuota.Acquire(c.ctx); err != nil {
+					c.requeue(msg)
+					return
+				}
+			}
+			sent, err := sess.sender.Send(c.ctx, publish)
+			if err != nil {
+				c.requeue(msg)
+				return
+			}
+			if err := c.write(&sent); err != nil {
+				// messages in flight are resent once the session is resumed
+				return
+			}
+		}
+	}
+}
+
+//requeue returns msg that couldn't be sent to the front of the queue of the session.
+func (c *conn) requeue(msg expiry.Message) {
+	if err := c.session.pushFront(msg); err != nil {
+		log.Printf("broker: %v", err)
+	}
+}
+
+func (c *conn) handle(pkt packet.Packet) error {
+	sess := c.session
+	switch p := pkt.(type) {
+	case *packet.Publish:
+		return c.handlePublish(p)
+
+	case *packet.Pubrel:
+		pubcomp := sess.receiver.HandlePubrel(*p)
+		c.receiveQuota.HandleAck(pubcomp)
+		return c.write(pubcomp)
+
+	case *packet.Puback:
+		if _, err := sess.sender.HandlePuback(*p); err != nil && err != qos.ErrUnknownPacketID {
+			return err
+		}
+		c.sendQuota.HandleAck(p)
+		return nil
+
+	case *packet.Pubrec:
+		pubrel, err := sess.sender.HandlePubrec(*p)
+		if err != nil {
+			return err
+		}
+		c.sendQuota.HandleAck(p)
+		if pubrel == nil {
+			return nil
+		}
+		return c.write(pubrel)
+
+	case *packet.Pubcomp:
+		if _, err := sess.sender.HandlePubcomp(*p); err != nil && err != qos.ErrUnknownPacketID {
+			return err
+		}
+		c.sendQuota.HandleAck(p)
+		return nil
+
+	case *packet.Subscribe:
+		return c.handleSubscribe(p)
+
+	case *packet.Unsubscribe:
+		return c.handleUnsubscribe(p)
+
+	case *packet.Pingreq:
+		return c.write(&packet.Pingresp{})
+
+	case *packet.Disconnect:
+		return c.handleDisconnect(p)
+	}
+	return &packet.DisconnectError{
+		Reason: packet.DisconnectProtocolError,
+		Err:    fmt.Errorf("unexpected control packet %T", pkt),
+	}
+}
+
+func (c *conn) handlePublish(publish *packet.Publish) error {
+	if err := c.aliases.Resolve(publish); err != nil {
+		return err
+	}
+	if err := c.receiveQuota.HandlePublish(*publish); err != nil {
+		return err
+	}
+	// 3.3.4 a client must not send subscription identifiers
+	if _, ok := publish.Props[packet.SubscriptionIdentifier]; ok {
+		return &packet.DisconnectError{
+			Reason: packet.DisconnectProtocolError,
+			Err:    fmt.Errorf("publish contains a subscription identifier"),
+		}
+	}
+
+	// 4.3.3 a duplicate QoS 2 message is acknowledged again, but the hooks already decided about it
+	forward, reason := *publish, packet.PubackSuccess
+	if !c.session.receiver.Duplicate(*publish) {
+		forward, reason = c.server.hooks.OnPublish(c.info, *publish)
+	}
+	ack, deliver := c.session.receiver.HandlePublish(*publish, reason)
+	if deliver && reason < 0x80 {
+		forward.Dup = false
+		forward.PacketID = 0
+		c.server.publish(forward, c.session)
+	}
+	if ack == nil {
+		return nil
+	}
+	c.receiveQuota.HandleAck(ack)
+	return c.write(ack)
+}
+
+func (c *conn) handleSubscribe(subscribe *packet.Subscribe) error {
+	var id uint32
+	if ids := subscribe.Props.VarInt(packet.SubscriptionIdentifier); len(ids) > 0 {
+		id = ids[0]
+		if id == 0 {
+			return &packet.DisconnectError{
+				Reason: packet.DisconnectProtocolError,
+				Err:    fmt.Errorf("subscription identifier 0"),
+			}
+		}
+	}
+
+	suback := &packet.Suback{
+		PacketID: subscribe.PacketID,
+		Props:    packet.NewProperties(),
+		Reasons:  make([]packet.SubackReason, len(subscribe.Filters)),
+	}
+	granted := c.server.hooks.OnSubscribe(c.info, *subscribe)
+	var retained []packet.Publish
+	for i, sub := range subscribe.Filters {
+		if sub.MaxQoS > packet.Qos2 || sub.RetainHandling > packet.RetainHandlingNever {
+			return &packet.DisconnectError{
+				Reason: packet.DisconnectMalformedPacket,
+				Err:    fmt.Errorf("invalid subscription options for filter '%s'", sub.Filter),
+			}
+		}
+		filter, err := topic.ParseFilter(sub.Filter)
+		if err != nil {
+			suback.Reasons[i] = packet.SubackTopicFilterInvalid
+			continue
+		}
+
+		if granted[i] >= 0x80 {
+			suback.Reasons[i] = granted[i]
+			continue
+		}
+		sub.MaxQoS = byte(granted[i])
+
+		existed := c.server.subscribe(c.session, filter, subscription{SubscriptionFilter: sub, id: id})
+		suback.Reasons[i] = granted[i]
+
+		msgs, err := c.server.retained.Subscribe(sub, existed)
+		if err != nil {
+			log.Printf("broker: %v", err)
+		}
+		for _, msg := range msgs {
+			if id != 0 {
+				msg.Props = withSubscriptionIDs(msg.Props, []uint32{id})
+			}
+			retained = append(retained, msg)
+		}
+	}
+
+	if err := c.write(suback); err != nil {
+		return err
+	}
+	// 3.8.4 retained messages are sent after the suback
+	now := c.server.clk.Now()
+	for _, msg := range retained {
+		if err := c.session.enqueue(expiry.Message{Publish: msg, Received: now}, now); err != nil {
+			log.Printf("broker: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c *conn) handleUnsubscribe(unsubscribe *packet.Unsubscribe) error {
+	unsuback := &packet.Unsuback{
+		PacketID: unsubscribe.PacketID,
+		Props:    packet.NewProperties(),
+		Reasons:  make([]packet.UnsubackReason, len(unsubscribe.Filters)),
+	}
+	for i, raw := range unsubscribe.Filters {
+		filter, err := topic.ParseFilter(raw)
+		if err != nil {
+			unsuback.Reasons[i] = packet.UnsubackTopicFilterInvalid
+			continue
+		}
+		if !c.server.unsubscribe(c.session, filter, raw) {
+			unsuback.Reasons[i] = packet.UnsubackNoSubscriptionExisted
+		}
+	}
+	return c.write(unsuback)
+}
+
+func (c *conn) handleDisconnect(disconnect *packet.Disconnect) error {
+	c.disconnect = disconnect
+	s := c.server
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sessionExpiry, ok := disconnect.Props.Int32(packet.SessionExpiryInterval); ok {
+		// 3.14.2.2.2 a session expiry interval of 0 in connect can't be changed
+		if c.session.expiry == 0 && sessionExpiry != 0 {
+			return &packet.DisconnectError{
+				Reason: packet.DisconnectProtocolError,
+				Err:    fmt.Errorf("session expiry interval set on disconnect after it was 0 on connect"),
+			}
+		}
+		c.session.expiry = sessionExpiry
+	}
+	// 3.1.2.5 the will is discarded on a disconnect with reason code 0
+	if disconnect.Reason == packet.DisconnectNormalDisconnection {
+		c.will = nil
+	}
+	return errDisconnected
+}
@@ -0,0 +1,11 @@
+package broker
+
+/*
+Package broker implements a reference mqtt 5 server on top of the packages of this module.
+It handles connect and connack, QoS 0, 1 and 2 deliveries, retained messages,
+wildcard and shared subscriptions, will messages, session and message expiry.
+Messages for a session wait in a bounded queue of package queue until they are sent, e.g. while its client is offline.
+Hooks added to the Server extend it, e.g. to authenticate clients or to authorize subscriptions and messages.
+By default all state is kept in memory, which makes it suitable as an in-process broker for integration tests;
+sessions survive a restart if they are saved in a session store of package store.
+*/
@@ -0,0 +1,138 @@
+package broker
+
+import (
+	"net"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//ClientInfo identifies the client a hook is called for.
+type ClientInfo struct {
+	ClientID   string
+	Username   string
+	RemoteAddr net.Addr
+}
+
+//Hooks are the extension points of the Server, e.g. for authentication, authorization and auditing modules.
+//Embed NoopHooks to implement only some of them.
+//Hooks are called concurrently for different clients and must not call back into the Server.
+type Hooks interface {
+	//OnAuth authenticates the client with the credentials of connect (3.1.3.5, 3.1.3.6).
+	//A reason code indicating an error refuses the connection, e.g. ConnectBadUserNameOrPassword.
+	OnAuth(client ClientInfo, connect packet.Connect) packet.ConnectReason
+	//OnConnect is called once the client is authenticated, before its session is attached.
+	//A reason code indicating an error refuses the connection.
+	OnConnect(client ClientInfo, connect packet.Connect) packet.ConnectReason
+	//OnSubscribe returns a reason code per filter of subscribe, nil to grant all of them as requested.
+	//A granted QoS lower than the requested one downgrades the subscription, a reason code indicating an error rejects the filter.
+	OnSubscribe(client ClientInfo, subscribe packet.Subscribe) []packet.SubackReason
+	//OnPublish is called for every message received from the client, it returns the message to forward.
+	//A reason code indicating an error drops the message and is sent in the puback or pubrec control packet.
+	//It is called again for a QoS 2 message the client retransmits before it has been acknowledged.
+	OnPublish(client ClientInfo, publish packet.Publish) (packet.Publish, packet.PubackReason)
+	//OnDisconnect is called once the network connection of an accepted client is closed.
+	//Disconnect is the disconnect control packet sent by the client, nil if it sent none; err is the reason the connection was closed otherwise.
+	OnDisconnect(client ClientInfo, disconnect *packet.Disconnect, err error)
+	//OnWillPublish is called before the will message of the client is published, it returns the message to publish.
+	//The will message is dropped if it reports false.
+	OnWillPublish(client ClientInfo, will packet.Publish) (packet.Publish, bool)
+}
+
+//NoopHooks implements Hooks without any effect.
+type NoopHooks struct{}
+
+//OnAuth implements Hooks.
+func (NoopHooks) OnAuth(ClientInfo, packet.Connect) packet.ConnectReason {
+	return packet.ConnectSuccess
+}
+
+//OnConnect implements Hooks.
+func (NoopHooks) OnConnect(ClientInfo, packet.Connect) packet.ConnectReason {
+	return packet.ConnectSuccess
+}
+
+//OnSubscribe implements Hooks.
+func (NoopHooks) OnSubscribe(ClientInfo, packet.Subscribe) []packet.SubackReason {
+	return nil
+}
+
+//OnPublish implements Hooks.
+func (NoopHooks) OnPublish(_ ClientInfo, publish packet.Publish) (packet.Publish, packet.PubackReason) {
+	return publish, packet.PubackSuccess
+}
+
+//OnDisconnect implements Hooks.
+func (NoopHooks) OnDisconnect(ClientInfo, *packet.Disconnect, error) {}
+
+//OnWillPublish implements Hooks.
+func (NoopHooks) OnWillPublish(_ ClientInfo, will packet.Publish) (packet.Publish, bool) {
+	return will, true
+}
+
+//hookChain calls several Hooks in the order they were added.
+//The first reason code indicating an error wins, messages are passed from one hook to the next.
+type hookChain []Hooks
+
+func (c hookChain) OnAuth(client ClientInfo, connect packet.Connect) packet.ConnectReason {
+	for _, h := range c {
+		if reason := h.OnAuth(client, connect); reason >= 0x80 {
+			return reason
+		}
+	}
+	return packet.ConnectSuccess
+}
+
+func (c hookChain) OnConnect(client ClientInfo, connect packet.Connect) packet.ConnectReason {
+	for _, h := range c {
+		if reason := h.OnConnect(client, connect); reason >= 0x80 {
+			return reason
+		}
+	}
+	return packet.ConnectSuccess
+}
+
+func (c hookChain) OnSubscribe(client ClientInfo, subscribe packet.Subscribe) []packet.SubackReason {
+	reasons := make([]packet.SubackReason, len(subscribe.Filters))
+	for i, sub := range subscribe.Filters {
+		reasons[i] = packet.SubackReason(sub.MaxQoS)
+	}
+	for _, h := range c {
+		granted := h.OnSubscribe(client, subscribe)
+		for i := range reasons {
+			if i >= len(granted) || reasons[i] >= 0x80 {
+				continue
+			}
+			if granted[i] >= 0x80 || granted[i] < reasons[i] {
+				reasons[i] = granted[i]
+			}
+		}
+	}
+	return reasons
+}
+
+func (c hookChain) OnPublish(client ClientInfo, publish packet.Publish) (packet.Publish, packet.PubackReason) {
+	reason := packet.PubackSuccess
+	for _, h := range c {
+		publish, reason = h.OnPublish(client, publish)
+		if reason >= 0x80 {
+			return publish, reason
+		}
+	}
+	return publish, reason
+}
+
+func (c hookChain) OnDisconnect(client ClientInfo, disconnect *packet.Disconnect, err error) {
+	for _, h := range c {
+		h.OnDisconnect(client, disconnect, err)
+	}
+}
+
+func (c hookChain) OnWillPublish(client ClientInfo, will packet.Publish) (packet.Publish, bool) {
+	for _, h := range c {
+		var ok bool
+		if will, ok = h.OnWillPublish(client, will); !ok {
+			return will, false
+		}
+	}
+	return will, true
+}
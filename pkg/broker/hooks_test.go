@@ -0,0 +1,188 @@
+package broker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//testHooks rejects the password "wrong", limits subscriptions to "limited/#" to QoS 0 and rejects the ones to "denied",
+//drops messages to "denied", uppercases the payload of messages to "upper" and suppresses wills to "will/suppressed".
+type testHooks struct {
+	NoopHooks
+	mu           sync.Mutex
+	disconnected []string
+	published    int
+}
+
+func (h *testHooks) OnAuth(_ ClientInfo, connect packet.Connect) packet.ConnectReason {
+	if string(connect.Payload.Password) == "wrong" {
+		return packet.ConnectBadUserNameOrPassword
+	}
+	return packet.ConnectSuccess
+}
+
+func (h *testHooks) OnSubscribe(_ ClientInfo, subscribe packet.Subscribe) []packet.SubackReason {
+	reasons := make([]packet.SubackReason, len(subscribe.Filters))
+	for i, sub := range subscribe.Filters {
+		switch sub.Filter {
+		case "limited/#":
+			reasons[i] = packet.SubackReason(packet.Qos0)
+		case "denied":
+			reasons[i] = packet.SubackNotAuthorized
+		default:
+			reasons[i] = packet.SubackReason(sub.MaxQoS)
+		}
+	}
+	return reasons
+}
+
+func (h *testHooks) OnPublish(_ ClientInfo, publish packet.Publish) (packet.Publish, packet.PubackReason) {
+	h.mu.Lock()
+	h.published++
+	h.mu.Unlock()
+
+	switch publish.Topic.String() {
+	case "denied":
+		return publish, packet.PubackNotAuthorized
+	case "upper":
+		publish.Payload = []byte("UPPER")
+	}
+	return publish, packet.PubackSuccess
+}
+
+func (h *testHooks) OnDisconnect(client ClientInfo, disconnect *packet.Disconnect, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.disconnected = append(h.disconnected, client.ClientID)
+}
+
+func (h *testHooks) OnWillPublish(_ ClientInfo, will packet.Publish) (packet.Publish, bool) {
+	return will, will.Topic.String() != "will/suppressed"
+}
+
+func (h *testHooks) disconnectedClients() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.disconnected...)
+}
+
+func startServerWithHooks(t *testing.T) (*Server, *testHooks, string) {
+	t.Helper()
+	hooks := &testHooks{}
+	s, _, addr := startServer(t, hooks)
+	return s, hooks, addr
+}
+
+func TestHooksAuth(t *testing.T) {
+	s, _, addr := startServerWithHooks(t)
+	defer s.Close()
+
+	connect := packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "a", Username: "a", Password: []byte("wrong")}}
+	if _, connack := dial(t, addr, connect); connack.ConnectReason != packet.ConnectBadUserNameOrPassword {
+		t.Errorf("connect reason = %v, want %v", connack.ConnectReason, packet.ConnectBadUserNameOrPassword)
+	}
+	connect.Payload.Password = []byte("right")
+	if _, connack := dial(t, addr, connect); connack.ConnectReason != packet.ConnectSuccess {
+		t.Errorf("connect reason = %v, want %v", connack.ConnectReason, packet.ConnectSuccess)
+	}
+}
+
+func TestHooksSubscribe(t *testing.T) {
+	s, _, addr := startServerWithHooks(t)
+	defer s.Close()
+	sub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "sub"}})
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+
+	suback := sub.subscribe(nil,
+		packet.SubscriptionFilter{Filter: "limited/#", MaxQoS: packet.Qos2},
+		packet.SubscriptionFilter{Filter: "denied", MaxQoS: packet.Qos1},
+		packet.SubscriptionFilter{Filter: "open", MaxQoS: packet.Qos1},
+	)
+	want := []packet.SubackReason{packet.SubackReason(packet.Qos0), packet.SubackNotAuthorized, packet.SubackQoS1Granted}
+	if len(suback.Reasons) != len(want) {
+		t.Fatalf("suback reasons = %v, want %v", suback.Reasons, want)
+	}
+	for i := range want {
+		if suback.Reasons[i] != want[i] {
+			t.Errorf("suback reasons = %v, want %v", suback.Reasons, want)
+		}
+	}
+
+	// the downgraded subscription delivers with QoS 0
+	pub.publish(packet.Qos1, false, "limited/a", "limited")
+	if publish := sub.expectPublish("limited"); publish.Qos != packet.Qos0 {
+		t.Errorf("publish QoS = %d, want 0", publish.Qos)
+	}
+}
+
+func TestHooksPublish(t *testing.T) {
+	s, _, addr := startServerWithHooks(t)
+	defer s.Close()
+	sub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "sub"}})
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+	sub.subscribe(nil, packet.SubscriptionFilter{Filter: "#"})
+
+	denied, err := topic.ParseTopic("denied")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub.send(&packet.Publish{Qos: packet.Qos1, PacketID: 1, Topic: denied, Props: packet.NewProperties(), Payload: []byte("dropped")})
+	if puback, ok := pub.expect().(*packet.Puback); !ok || puback.Reason != packet.PubackNotAuthorized {
+		t.Fatalf("expected puback with reason %v", packet.PubackNotAuthorized)
+	}
+
+	pub.publish(packet.Qos0, false, "upper", "lower")
+	sub.expectPublish("UPPER")
+}
+
+func TestHooksPublishDuplicate(t *testing.T) {
+	s, hooks, addr := startServerWithHooks(t)
+	defer s.Close()
+	pub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "pub"}})
+
+	tpc, err := topic.ParseTopic("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish := &packet.Publish{Qos: packet.Qos2, PacketID: 1, Topic: tpc, Props: packet.NewProperties(), Payload: []byte("once")}
+	for i := 0; i < 2; i++ {
+		pub.send(publish)
+		if _, ok := pub.expect().(*packet.Pubrec); !ok {
+			t.Fatal("expected pubrec")
+		}
+		publish.Dup = true
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	if hooks.published != 1 {
+		t.Errorf("OnPublish() called %d times, want 1", hooks.published)
+	}
+}
+
+func TestHooksDisconnectAndWill(t *testing.T) {
+	s, hooks, addr := startServerWithHooks(t)
+	defer s.Close()
+	sub, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "sub"}})
+	sub.subscribe(nil, packet.SubscriptionFilter{Filter: "will/+"})
+
+	suppressed, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "a", WillTopic: "will/suppressed", WillPayload: []byte("a")}})
+	suppressed.net.Close()
+	waitOffline(t, s, "a")
+	published, _ := dial(t, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "b", WillTopic: "will/published", WillPayload: []byte("b")}})
+	published.net.Close()
+
+	sub.expectPublish("b")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(hooks.disconnectedClients()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("OnDisconnect() called for %v, want a and b", hooks.disconnectedClients())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
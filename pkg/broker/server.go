@@ -0,0 +1,463 @@
+package broker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/expiry"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/queue"
+	"github.com/squ94wk/mqtt-common/pkg/retain"
+	"github.com/squ94wk/mqtt-common/pkg/store"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//ErrServerClosed is returned by Serve after Close.
+var ErrServerClosed = errors.New("broker: server closed")
+
+//Server is an mqtt server.
+//It is safe for concurrent use.
+type Server struct {
+	clk      clock.Clock
+	subs     *topic.Tree
+	retained *retain.Store
+	shared   uint64
+	hooks    hookChain
+	sched    *expiry.Scheduler
+
+	mu           sync.Mutex
+	sessions     map[string]*session
+	sessionStore store.SessionStore
+	queueOpts    queue.Options
+	listeners    map[net.Listener]struct{}
+	conns        map[*conn]struct{}
+	closed       bool
+	wg           sync.WaitGroup
+}
+
+//NewServer is the constructor of the Server type.
+//Clk drives keep alive, will delay and session expiry.
+func NewServer(clk clock.Clock) *Server {
+	s := &Server{
+		clk:       clk,
+		subs:      topic.NewTree(),
+		retained:  retain.NewStore(retain.NewMemoryStorage(), clk),
+		sessions:  make(map[string]*session),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*conn]struct{}),
+	}
+	s.sched = expiry.NewScheduler(clk, &s.mu, s.publishWillLocked, s.expireLocked)
+	return s
+}
+
+//AddHooks adds hooks that are called after the ones added before.
+//It must not be called once the Server serves connections.
+func (s *Server) AddHooks(hooks ...Hooks) {
+	s.hooks = append(s.hooks, hooks...)
+}
+
+//SetQueueOptions configures the queues of messages waiting to be sent to the clients of sessions, e.g. while they are offline.
+//It must not be called once the Server serves connections or after UseSessionStore.
+func (s *Server) SetQueueOptions(opts queue.Options) {
+	s.queueOpts = opts
+}
+
+//UseSessionStore restores the sessions saved in sessionStore and saves the sessions with a session expiry interval in it from then on.
+//Sessions are saved when their client connects, subscribes, unsubscribes and disconnects and when the Server is closed;
+//pending will messages aren't saved.
+//It must not be called once the Server serves connections.
+func (s *Server) UseSessionStore(sessionStore store.SessionStore) error {
+	saved, err := sessionStore.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load sessions: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessionStore = sessionStore
+	now := s.clk.Now()
+	for _, state := range saved {
+		if err := s.restoreLocked(state, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//restoreLocked restores a saved session unless it expired in the meantime; s.mu must be held.
+//Sessions saved while their client was connected are restored as if it disconnected now.
+func (s *Server) restoreLocked(state store.Session, now time.Time) error {
+	sess := newSession(state.ClientID, s.queueOpts)
+	sess.expiry = state.Expiry
+	sess.disconnected = now
+	if !state.Disconnected.IsZero() {
+		sess.disconnected = state.Disconnected
+	}
+	remaining := expiry.Never
+	if sess.expiry != neverExpires {
+		remaining = expiry.SessionExpiry(sess.expiry) - now.Sub(sess.disconnected)
+		if remaining <= 0 {
+			return s.sessionStore.Delete(state.ClientID)
+		}
+	}
+
+	for _, sub := range state.Subscriptions {
+		filter, err := topic.ParseFilter(sub.Filter)
+		if err != nil {
+			return fmt.Errorf("failed to restore session '%s': %v", state.ClientID, err)
+		}
+		restored := subscription{SubscriptionFilter: sub.SubscriptionFilter, id: sub.Identifier}
+		sess.subs[sub.Filter] = restored
+		s.subs.Insert(filter, sess, restored)
+	}
+	if err := sess.sender.Restore(state.InFlight); err != nil {
+		return fmt.Errorf("failed to restore session '%s': %v", state.ClientID, err)
+	}
+	sess.receiver.Restore(state.Pending)
+	if err := sess.queue.Restore(state.Queue, now); err != nil {
+		return fmt.Errorf("failed to restore session '%s': %v", state.ClientID, err)
+	}
+
+	s.sessions[state.ClientID] = sess
+	s.sched.Disconnected(state.ClientID, remaining, nil)
+	return nil
+}
+
+//saveLocked saves the state of sess in the session store, if there is one; s.mu must be held.
+//Sessions without session expiry interval end with their network connection and are deleted instead.
+func (s *Server) saveLocked(sess *session) {
+	if s.sessionStore == nil {
+		return
+	}
+	var err error
+	if sess.expiry == 0 {
+		err = s.sessionStore.Delete(sess.clientID)
+	} else {
+		err = s.sessionStore.Save(sess.state())
+	}
+	if err != nil {
+		log.Printf("broker: failed to save session '%s': %v", sess.clientID, err)
+	}
+}
+
+//ListenAndServe listens on the TCP address addr and serves the accepted connections.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+//Serve accepts connections on l and serves each in its own goroutine.
+//It returns ErrServerClosed after Close.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	for {
+		netConn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		go s.ServeConn(netConn)
+	}
+}
+
+//ServeConn serves a single network connection, e.g. one accepted by websocket.Handler.
+//It returns once the network connection is closed.
+func (s *Server) ServeConn(netConn net.Conn) {
+	c := newConn(s, netConn)
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		netConn.Close()
+		return
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	c.serve()
+}
+
+//Close stops all listeners and closes all network connections with DisconnectServerShuttingDown.
+//Sessions end with the Server unless they are saved in a session store, see UseSessionStore.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var firstErr error
+	for l := range s.listeners {
+		if err := l.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.close(packet.DisconnectServerShuttingDown)
+	}
+	s.wg.Wait()
+
+	// messages may have been queued since the sessions were saved
+	s.mu.Lock()
+	s.sched.Stop()
+	for _, sess := range s.sessions {
+		s.saveLocked(sess)
+	}
+	s.mu.Unlock()
+	return firstErr
+}
+
+//attach attaches c to the session of its client identifier and reports whether an existing session is resumed (3.1.2.4).
+//An existing network connection of the same client is taken over (3.1.4-3).
+func (s *Server) attach(c *conn, clientID string, cleanStart bool, sessionExpiry uint32) (*session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[clientID]
+	if ok && sess.conn != nil {
+		old := sess.conn
+		// stop the writer of the old network connection before the new one takes over the queue
+		old.cancel()
+		s.detachLocked(sess, old)
+		go old.close(packet.DisconnectSessionTakenOver)
+	}
+	// the session may have ended while detaching
+	sess, ok = s.sessions[clientID]
+	if ok && cleanStart {
+		s.endLocked(sess)
+		ok = false
+	}
+	if !ok {
+		sess = newSession(clientID, s.queueOpts)
+		s.sessions[clientID] = sess
+	}
+
+	// 3.1.3.2.2 the will isn't sent if the client reconnects before the will delay interval passed
+	s.sched.Connected(clientID)
+
+	sess.conn = c
+	sess.client = c.info
+	sess.expiry = sessionExpiry
+	sess.disconnected = time.Time{}
+	sess.setOnline(true)
+	s.saveLocked(sess)
+	return sess, ok
+}
+
+//detach detaches c from its session after the network connection was closed.
+func (s *Server) detach(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.session != nil && c.session.conn == c {
+		s.detachLocked(c.session, c)
+	}
+}
+
+//detachLocked schedules the will of c and the end of sess; s.mu must be held.
+func (s *Server) detachLocked(sess *session, c *conn) {
+	sess.conn = nil
+	sess.disconnected = s.clk.Now()
+	sess.setOnline(false)
+
+	// 3.1.3.2.2 the will is sent after the will delay interval or when the session ends, whichever happens first
+	s.sched.Disconnected(sess.clientID, expiry.SessionExpiry(sess.expiry), c.takeWill())
+	if s.sessions[sess.clientID] == sess {
+		s.saveLocked(sess)
+	}
+}
+
+//expireLocked ends the session of clientID once its session expiry interval passed; s.mu must be held.
+func (s *Server) expireLocked(clientID string) {
+	if sess, ok := s.sessions[clientID]; ok && sess.conn == nil {
+		s.endLocked(sess)
+	}
+}
+
+//publishWillLocked publishes the will message of clientID unless a hook drops it; s.mu must be held.
+func (s *Server) publishWillLocked(clientID string, will packet.Publish) {
+	var client ClientInfo
+	if sess, ok := s.sessions[clientID]; ok {
+		client = sess.client
+	}
+	if will, ok := s.hooks.OnWillPublish(client, will); ok {
+		s.publish(will, nil)
+	}
+}
+
+//endLocked ends sess, publishing its pending will; s.mu must be held.
+func (s *Server) endLocked(sess *session) {
+	s.sched.End(sess.clientID)
+
+	for _, sub := range sess.subs {
+		filter, err := topic.ParseFilter(sub.Filter)
+		if err != nil {
+			continue
+		}
+		s.subs.Remove(filter, sess)
+	}
+	delete(s.sessions, sess.clientID)
+	if s.sessionStore != nil {
+		if err := s.sessionStore.Delete(sess.clientID); err != nil {
+			log.Printf("broker: failed to delete session '%s': %v", sess.clientID, err)
+		}
+	}
+}
+
+//subscribe adds a subscription of sess and reports whether it replaced an existing one.
+func (s *Server) subscribe(sess *session, filter topic.Filter, sub subscription) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, existed := sess.subs[sub.Filter]
+	sess.subs[sub.Filter] = sub
+	s.subs.Insert(filter, sess, sub)
+	s.saveLocked(sess)
+	return existed
+}
+
+//unsubscribe removes a subscription of sess and reports whether it existed.
+func (s *Server) unsubscribe(sess *session, filter topic.Filter, raw string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(sess.subs, raw)
+	existed := s.subs.Remove(filter, sess)
+	s.saveLocked(sess)
+	return existed
+}
+
+//delivery collects the subscriptions of a single session matching a message (3.3.4).
+type delivery struct {
+	qos    byte
+	retain bool
+	ids    []uint32
+}
+
+func (d *delivery) add(publish packet.Publish, sub subscription) {
+	granted := publish.Qos
+	if granted > sub.MaxQoS {
+		granted = sub.MaxQoS
+	}
+	if granted > d.qos {
+		d.qos = granted
+	}
+	if sub.RetainAsPublished {
+		d.retain = publish.Retain
+	}
+	if sub.id != 0 {
+		d.ids = append(d.ids, sub.id)
+	}
+}
+
+//publish retains publish and forwards it to all matching subscriptions.
+//From is the session of the publisher, nil for will messages.
+func (s *Server) publish(publish packet.Publish, from *session) {
+	if err := s.retained.Retain(publish); err != nil {
+		log.Printf("broker: %v", err)
+	}
+
+	deliveries := make(map[*session]*delivery)
+	shared := make(map[string][]topic.Subscription)
+	for _, match := range s.subs.Match(publish.Topic) {
+		if match.Filter.IsShared() {
+			group := match.Filter.String()
+			shared[group] = append(shared[group], match)
+			continue
+		}
+		sess := match.Subscriber.(*session)
+		sub := match.Options.(subscription)
+		// 3.8.3.1 no local
+		if sub.NoLocal && sess == from {
+			continue
+		}
+		deliveryFor(deliveries, sess).add(publish, sub)
+	}
+
+	// 4.8.2 each message is delivered to a single session of a share group,
+	// the tree returns the members in a stable order for the round robin
+	for _, group := range shared {
+		match := group[atomic.AddUint64(&s.shared, 1)%uint64(len(group))]
+		deliveryFor(deliveries, match.Subscriber.(*session)).add(publish, match.Options.(subscription))
+	}
+
+	received := s.clk.Now()
+	for sess, d := range deliveries {
+		out := publish
+		out.Qos = d.qos
+		out.Retain = d.retain
+		out.Props = withSubscriptionIDs(publish.Props, d.ids)
+		if err := sess.enqueue(expiry.Message{Publish: out, Received: received}, received); err != nil {
+			log.Printf("broker: %v", err)
+		}
+	}
+}
+
+func deliveryFor(deliveries map[*session]*delivery, sess *session) *delivery {
+	d, ok := deliveries[sess]
+	if !ok {
+		d = &delivery{}
+		deliveries[sess] = d
+	}
+	return d
+}
+
+//withSubscriptionIDs returns a copy of props with the subscription identifiers ids (3.3.2.3.8).
+func withSubscriptionIDs(props packet.Properties, ids []uint32) packet.Properties {
+	out := props.Clone()
+	delete(out, packet.SubscriptionIdentifier)
+	for _, id := range ids {
+		out.Add(packet.NewProperty(packet.SubscriptionIdentifier, packet.VarIntPropPayload(id)))
+	}
+	return out
+}
+
+//newClientID returns a client identifier for clients that connect without one (3.1.3.1).
+func newClientID() string {
+	var buf [12]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "auto-" + time.Now().Format("20060102150405.000000000")
+	}
+	return "auto-" + hex.EncodeToString(buf[:])
+}
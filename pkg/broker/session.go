@@ -0,0 +1,139 @@
+package broker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/expiry"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/qos"
+	"github.com/squ94wk/mqtt-common/pkg/queue"
+	"github.com/squ94wk/mqtt-common/pkg/store"
+)
+
+//neverExpires is the session expiry interval of sessions that don't expire (3.1.2.11.2).
+const neverExpires = 1<<32 - 1
+
+//subscription is the value stored in the subscription tree.
+type subscription struct {
+	packet.SubscriptionFilter
+	// id is the subscription identifier, 0 if there is none (3.8.2.1.2)
+	id uint32
+}
+
+//session is the state of a client that outlives its network connections (4.1).
+//The fields up to mu are protected by the mutex of the Server.
+type session struct {
+	clientID string
+	subs     map[string]subscription
+	expiry   uint32
+	conn     *conn
+	// client is the client of the last network connection, e.g. for the hooks called for the will
+	client ClientInfo
+	// disconnected is the time the last network connection was closed, zero while one is attached
+	disconnected time.Time
+
+	ids      *packet.IDAllocator
+	sender   *qos.Sender
+	receiver *qos.Receiver
+
+	mu     sync.Mutex
+	online bool
+	queue  *queue.Queue
+	notify chan struct{}
+}
+
+func newSession(clientID string, queueOpts queue.Options) *session {
+	ids := packet.NewIDAllocator()
+	return &session{
+		clientID: clientID,
+		subs:     make(map[string]subscription),
+		ids:      ids,
+		sender:   qos.NewSender(ids),
+		receiver: qos.NewReceiver(),
+		queue:    queue.New(queueOpts),
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+//state returns the state of the session to be saved; the mutex of the Server must be held.
+func (s *session) state() store.Session {
+	state := store.Session{
+		ClientID:     s.clientID,
+		Expiry:       s.expiry,
+		Disconnected: s.disconnected,
+		InFlight:     s.sender.InFlight(),
+		Pending:      s.receiver.Pending(),
+	}
+	for _, sub := range s.subs {
+		state.Subscriptions = append(state.Subscriptions, store.Subscription{SubscriptionFilter: sub.SubscriptionFilter, Identifier: sub.id})
+	}
+	sort.Slice(state.Subscriptions, func(i, j int) bool {
+		return state.Subscriptions[i].Filter < state.Subscriptions[j].Filter
+	})
+
+	s.mu.Lock()
+	state.Queue = s.queue.Messages()
+	s.mu.Unlock()
+	return state
+}
+
+//setOnline marks whether a network connection is attached to the session.
+func (s *session) setOnline(online bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.online = online
+	if online && s.queue.Len() > 0 {
+		s.signal()
+	}
+}
+
+//enqueue queues msg to be sent to the client.
+//QoS 0 messages are dropped while the client is offline (4.1).
+//If the queue is full at now, messages are dropped according to its drop policy.
+func (s *session) enqueue(msg expiry.Message, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.online && msg.Publish.Qos == packet.Qos0 {
+		return nil
+	}
+	queued, err := s.queue.Push(msg, now)
+	if err != nil {
+		return fmt.Errorf("failed to queue message for '%s': %v", s.clientID, err)
+	}
+	if queued && s.online {
+		s.signal()
+	}
+	return nil
+}
+
+//signal wakes up the writer of the network connection; s.mu must be held.
+func (s *session) signal() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+//pop removes the next queued message that hasn't expired at now.
+func (s *session) pop(now time.Time) (expiry.Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.queue.Pop(now)
+}
+
+//pushFront returns a message that couldn't be sent to the front of the queue.
+func (s *session) pushFront(msg expiry.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.queue.PushFront(msg); err != nil {
+		return fmt.Errorf("failed to queue message for '%s': %v", s.clientID, err)
+	}
+	return nil
+}
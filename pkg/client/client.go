@@ -0,0 +1,615 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/keepalive"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/qos"
+)
+
+//Errors returned by the Client.
+var (
+	ErrConnectionLost = errors.New("client: connection lost")
+	ErrClosed         = errors.New("client: client is closed")
+)
+
+//receiveMaximum is the number of QoS 1 and QoS 2 messages the client processes concurrently.
+const receiveMaximum = 256
+
+//ConnectError is returned if the server refused the connection.
+type ConnectError struct {
+	Connack packet.Connack
+}
+
+//Error implements the error interface.
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("client: connection refused with reason code %d", e.Connack.ConnectReason)
+}
+
+//Handler handles an application message received for a subscription.
+//Handlers are called one at a time in the order the messages were received;
+//QoS 1 and QoS 2 messages are acknowledged once all handlers returned.
+//A handler must not wait for the completion of other requests of the Client.
+type Handler func(publish packet.Publish)
+
+//Subscription is a subscription filter with the handler for the messages matching it.
+type Subscription struct {
+	packet.SubscriptionFilter
+	//Identifier is the subscription identifier sent in the subscribe control packet, none if 0 (3.8.2.1.2).
+	//The server sends it with every message matching the subscription, e.g. to route it with package router.
+	Identifier uint32
+	Handler    Handler
+}
+
+//ErrMixedSubscriptionIDs is returned if subscriptions with different identifiers are subscribed to at once.
+//A subscribe control packet carries a single subscription identifier for all its filters (3.8.2.1.2).
+var ErrMixedSubscriptionIDs = errors.New("client: subscriptions with different subscription identifiers")
+
+//Options configures a Client.
+type Options struct {
+	//Clock drives the keep alive, clock.System if nil.
+	Clock clock.Clock
+	//DefaultHandler handles messages that match no subscription of the Client, e.g. of a resumed session.
+	DefaultHandler Handler
+}
+
+//Client is an mqtt client on a single network connection.
+//It is safe for concurrent use.
+type Client struct {
+	opts     Options
+	net      net.Conn
+	pkts     *packet.Conn
+	state    *packet.Validator
+	connack  packet.Connack
+	incoming chan incoming
+
+	// sess is shared with the Supervisor if persistent is set
+	sess       *session
+	persistent bool
+
+	sendQuota    *qos.SendQuota
+	receiveQuota *qos.ReceiveQuota
+	keepAlive    *keepalive.Client
+
+	mu       sync.Mutex
+	requests map[uint16]*Future
+	err      error
+	done     chan struct{}
+}
+
+//incoming is a received publish on its way to the handlers.
+type incoming struct {
+	publish packet.Publish
+	ack     packet.Packet
+	deliver bool
+}
+
+//Dial connects to the server at the TCP address addr and sends connect.
+func Dial(ctx context.Context, addr string, connect packet.Connect, opts Options) (*Client, error) {
+	netConn, err := dialTCP(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return Connect(ctx, netConn, connect, opts)
+}
+
+func dialTCP(ctx context.Context, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("client: failed to dial: %v", err)
+	}
+	return netConn, nil
+}
+
+//Connect sends connect on the network connection netConn, e.g. one returned by websocket.Dial, and waits for the connack.
+//A *ConnectError is returned if the server refused the connection.
+//The network connection is closed if Connect fails.
+func Connect(ctx context.Context, netConn net.Conn, connect packet.Connect, opts Options) (*Client, error) {
+	c, err := handshake(ctx, netConn, connect, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.start(newSession(), false)
+	return c, nil
+}
+
+//handshake returns a Client once the server accepted connect; it has to be started.
+func handshake(ctx context.Context, netConn net.Conn, connect packet.Connect, opts Options) (*Client, error) {
+	if opts.Clock == nil {
+		opts.Clock = clock.System
+	}
+	if connect.Props == nil {
+		connect.Props = packet.NewProperties()
+	}
+	connect.Props = connect.Props.Clone()
+	if _, ok := connect.Props.Int16(packet.ReceiveMaximum); !ok {
+		connect.Props.Add(packet.NewProperty(packet.ReceiveMaximum, packet.Int16PropPayload(receiveMaximum)))
+	}
+	ourMax, err := qos.ReceiveMaximum(connect.Props)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	c := &Client{
+		opts:         opts,
+		net:          netConn,
+		pkts:         packet.NewConn(netConn),
+		state:        packet.NewValidator(packet.RoleClient),
+		incoming:     make(chan incoming, ourMax),
+		receiveQuota: qos.NewReceiveQuota(ourMax),
+		requests:     make(map[uint16]*Future),
+		done:         make(chan struct{}),
+	}
+	if err := c.handshake(ctx, connect); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) handshake(ctx context.Context, connect packet.Connect) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := c.net.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	if err := c.write(&connect); err != nil {
+		return fmt.Errorf("client: failed to send connect: %v", err)
+	}
+	pkt, err := c.read()
+	if err != nil {
+		return fmt.Errorf("client: failed to receive connack: %v", err)
+	}
+	connack, ok := pkt.(*packet.Connack)
+	if !ok {
+		return fmt.Errorf("client: received %T instead of connack", pkt)
+	}
+	if connack.ConnectReason >= 0x80 {
+		return &ConnectError{Connack: *connack}
+	}
+	c.connack = *connack
+
+	serverMax, err := qos.ReceiveMaximum(connack.Props)
+	if err != nil {
+		return err
+	}
+	c.sendQuota = qos.NewSendQuota(serverMax)
+
+	c.keepAlive = keepalive.NewClient(c.opts.Clock, keepalive.Effective(connect, *connack), func() {
+		if err := c.write(&packet.Pingreq{}); err != nil {
+			c.fail(err)
+		}
+	}, func(err error) {
+		c.fail(err)
+	})
+
+	return c.net.SetDeadline(time.Time{})
+}
+
+//start starts processing control packets received for sess.
+//If persistent is set, the messages in flight of sess are kept if the network connection is lost.
+func (c *Client) start(sess *session, persistent bool) {
+	c.sess = sess
+	c.persistent = persistent
+	go c.readLoop()
+	go c.dispatchLoop()
+}
+
+//Connack returns the connack control packet the server accepted the connection with.
+func (c *Client) Connack() packet.Connack {
+	return c.connack
+}
+
+//Done is closed once the network connection is closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+//Err returns the reason the network connection was closed, nil while it is open.
+//A *packet.DisconnectError is returned if the server sent a disconnect control packet.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+//Publish sends publish and returns a Future that completes once the delivery is complete (4.3).
+//For QoS 1 and QoS 2 messages Publish blocks while the receive maximum of the server is exhausted (4.9).
+func (c *Client) Publish(ctx context.Context, publish packet.Publish) (*Future, error) {
+	future := newFuture()
+	if err := c.publish(ctx, publish, future); err != nil {
+		return nil, err
+	}
+	return future, nil
+}
+
+//publish sends publish and completes future once the delivery is complete.
+//An error is returned if publish hasn't been sent; if the network connection of a persistent session is lost afterwards, the message is kept in flight.
+func (c *Client) publish(ctx context.Context, publish packet.Publish, future *Future) error {
+	if err := c.Err(); err != nil {
+		return connectionLost(err)
+	}
+	if publish.Props == nil {
+		publish.Props = packet.NewProperties()
+	}
+	if publish.Qos == packet.Qos0 {
+		publish.PacketID = 0
+		if err := c.write(&publish); err != nil {
+			c.fail(err)
+			return connectionLost(err)
+		}
+		future.complete(nil, nil)
+		return nil
+	}
+
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+	if err := c.sendQuota.Acquire(ctx); err != nil {
+		return c.interrupted(err)
+	}
+	sent, err := c.sess.sender.Send(ctx, publish)
+	if err != nil {
+		c.sendQuota.Release()
+		return c.interrupted(err)
+	}
+	if err := c.sess.track(sent.PacketID, future); err != nil {
+		c.sess.sender.Cancel(sent.PacketID)
+		c.sendQuota.Release()
+		return err
+	}
+	if err := c.write(&sent); err != nil {
+		c.fail(err)
+		if !c.persistent {
+			return connectionLost(err)
+		}
+	}
+	return nil
+}
+
+//Subscribe subscribes to the filters of subs and registers their handlers.
+//The handlers of filters the server refused are removed again; the suback tells which.
+//All subs must have the same subscription identifier.
+func (c *Client) Subscribe(ctx context.Context, subs ...Subscription) (*packet.Suback, error) {
+	subscribe, err := newSubscribe(subs)
+	if err != nil {
+		return nil, err
+	}
+	c.sess.mu.Lock()
+	for _, sub := range subs {
+		c.sess.subs[sub.Filter] = sub
+	}
+	c.sess.mu.Unlock()
+
+	ack, err := c.request(ctx, subscribe, func(id uint16) { subscribe.PacketID = id })
+	suback, ok := ack.(*packet.Suback)
+	if err == nil && !ok {
+		err = fmt.Errorf("client: received %T instead of suback", ack)
+	}
+
+	c.sess.mu.Lock()
+	defer c.sess.mu.Unlock()
+	for i, sub := range subs {
+		if err != nil || i < len(suback.Reasons) && suback.Reasons[i] >= 0x80 {
+			delete(c.sess.subs, sub.Filter)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return suback, nil
+}
+
+//newSubscribe returns the subscribe control packet for subs without packet identifier.
+func newSubscribe(subs []Subscription) (*packet.Subscribe, error) {
+	subscribe := &packet.Subscribe{Props: packet.NewProperties()}
+	for _, sub := range subs {
+		if sub.Identifier != subs[0].Identifier {
+			return nil, ErrMixedSubscriptionIDs
+		}
+		subscribe.Filters = append(subscribe.Filters, sub.SubscriptionFilter)
+	}
+	if len(subs) > 0 && subs[0].Identifier != 0 {
+		subscribe.Props.Add(packet.NewProperty(packet.SubscriptionIdentifier, packet.VarIntPropPayload(subs[0].Identifier)))
+	}
+	return subscribe, nil
+}
+
+//Unsubscribe unsubscribes from filters and removes their handlers.
+func (c *Client) Unsubscribe(ctx context.Context, filters ...string) (*packet.Unsuback, error) {
+	unsubscribe := &packet.Unsubscribe{Props: packet.NewProperties(), Filters: filters}
+	ack, err := c.request(ctx, unsubscribe, func(id uint16) { unsubscribe.PacketID = id })
+	if err != nil {
+		return nil, err
+	}
+	unsuback, ok := ack.(*packet.Unsuback)
+	if !ok {
+		return nil, fmt.Errorf("client: received %T instead of unsuback", ack)
+	}
+
+	c.sess.mu.Lock()
+	defer c.sess.mu.Unlock()
+	for _, filter := range filters {
+		delete(c.sess.subs, filter)
+	}
+	return unsuback, nil
+}
+
+//request sends a control packet with a new packet identifier and waits for its acknowledgement.
+func (c *Client) request(ctx context.Context, pkt packet.Packet, setID func(id uint16)) (packet.Packet, error) {
+	waitCtx, cancel := c.context(ctx)
+	defer cancel()
+	id, err := c.sess.ids.AcquireWait(waitCtx)
+	if err != nil {
+		return nil, c.interrupted(err)
+	}
+	setID(id)
+
+	future := newFuture()
+	if err := c.register(id, future); err != nil {
+		c.sess.ids.Release(packet.Outgoing, id)
+		return nil, err
+	}
+	if err := c.write(pkt); err != nil {
+		c.fail(err)
+	}
+	return future.Wait(ctx)
+}
+
+//Disconnect sends a disconnect control packet with reason and closes the network connection.
+//Requests that haven't completed fail with ErrClosed.
+func (c *Client) Disconnect(reason packet.DisconnectReason) error {
+	err := c.write(&packet.Disconnect{Reason: reason, Props: packet.NewProperties()})
+	c.fail(ErrClosed)
+	return err
+}
+
+//context returns a context that is done once ctx is or the network connection is closed.
+func (c *Client) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-c.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+//interrupted returns the error for a request interrupted by err.
+func (c *Client) interrupted(err error) error {
+	if closeErr := c.Err(); closeErr != nil {
+		return connectionLost(closeErr)
+	}
+	return err
+}
+
+func connectionLost(err error) error {
+	if err == ErrClosed || errors.Is(err, ErrConnectionLost) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrConnectionLost, err)
+}
+
+func (c *Client) register(id uint16, future *Future) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return connectionLost(c.err)
+	}
+	c.requests[id] = future
+	return nil
+}
+
+//resolve completes the request with packet identifier id and releases the identifier.
+func (c *Client) resolve(id uint16, ack packet.Packet) {
+	c.mu.Lock()
+	future, ok := c.requests[id]
+	delete(c.requests, id)
+	c.mu.Unlock()
+
+	if ok {
+		c.sess.ids.Release(packet.Outgoing, id)
+		future.complete(ack, nil)
+	}
+}
+
+func (c *Client) read() (packet.Packet, error) {
+	pkt, err := c.pkts.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.state.Receive(pkt); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
+
+func (c *Client) write(pkt packet.Packet) error {
+	if err := c.state.Send(pkt); err != nil {
+		return err
+	}
+	if err := c.pkts.WritePacket(pkt); err != nil {
+		return err
+	}
+	if c.keepAlive != nil {
+		c.keepAlive.Sent()
+	}
+	return nil
+}
+
+//fail closes the network connection and fails all requests that haven't completed.
+//The messages in flight fail as well unless the session is persistent.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	requests := c.requests
+	c.requests = make(map[uint16]*Future)
+	close(c.done)
+	c.mu.Unlock()
+
+	c.keepAlive.Stop()
+	c.net.Close()
+
+	err = connectionLost(err)
+	for id, future := range requests {
+		c.sess.ids.Release(packet.Outgoing, id)
+		future.complete(nil, err)
+	}
+	if !c.persistent {
+		c.sess.close(err)
+	}
+}
+
+func (c *Client) readLoop() {
+	for {
+		pkt, err := c.read()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		c.keepAlive.Received(pkt)
+
+		if err := c.handle(pkt); err != nil {
+			var disconnectErr *packet.DisconnectError
+			if errors.As(err, &disconnectErr) && disconnectErr.Reason >= 0x80 {
+				_ = c.write(&packet.Disconnect{Reason: disconnectErr.Reason, Props: packet.NewProperties()})
+			}
+			c.fail(err)
+			return
+		}
+	}
+}
+
+func (c *Client) handle(pkt packet.Packet) error {
+	switch p := pkt.(type) {
+	case *packet.Publish:
+		if err := c.receiveQuota.HandlePublish(*p); err != nil {
+			return err
+		}
+		ack, deliver := c.sess.receiver.HandlePublish(*p, packet.PubackSuccess)
+		select {
+		case c.incoming <- incoming{publish: *p, ack: ack, deliver: deliver}:
+		case <-c.done:
+		}
+		return nil
+
+	case *packet.Pubrel:
+		pubcomp := c.sess.receiver.HandlePubrel(*p)
+		c.receiveQuota.HandleAck(pubcomp)
+		return c.write(pubcomp)
+
+	case *packet.Puback:
+		if _, err := c.sess.sender.HandlePuback(*p); err != nil {
+			return ignoreUnknown(err)
+		}
+		c.sendQuota.HandleAck(p)
+		c.sess.resolve(p.PacketID, p, reasonError(p, byte(p.Reason)))
+		return nil
+
+	case *packet.Pubrec:
+		pubrel, err := c.sess.sender.HandlePubrec(*p)
+		if err != nil {
+			return err
+		}
+		c.sendQuota.HandleAck(p)
+		if p.Reason >= 0x80 {
+			c.sess.resolve(p.PacketID, p, reasonError(p, byte(p.Reason)))
+		}
+		if pubrel == nil {
+			return nil
+		}
+		return c.write(pubrel)
+
+	case *packet.Pubcomp:
+		if _, err := c.sess.sender.HandlePubcomp(*p); err != nil {
+			return ignoreUnknown(err)
+		}
+		c.sendQuota.HandleAck(p)
+		c.sess.resolve(p.PacketID, p, reasonError(p, byte(p.Reason)))
+		return nil
+
+	case *packet.Suback:
+		c.resolve(p.PacketID, p)
+		return nil
+
+	case *packet.Unsuback:
+		c.resolve(p.PacketID, p)
+		return nil
+
+	case *packet.Pingresp:
+		return nil
+
+	case *packet.Disconnect:
+		return &packet.DisconnectError{
+			Reason: p.Reason,
+			Err:    fmt.Errorf("server sent disconnect"),
+		}
+	}
+	return &packet.DisconnectError{
+		Reason: packet.DisconnectProtocolError,
+		Err:    fmt.Errorf("unexpected control packet %T", pkt),
+	}
+}
+
+func ignoreUnknown(err error) error {
+	if err == qos.ErrUnknownPacketID {
+		return nil
+	}
+	return err
+}
+
+func reasonError(ack packet.Packet, reason byte) error {
+	if reason < 0x80 {
+		return nil
+	}
+	return &ReasonError{Ack: ack, Reason: reason}
+}
+
+//dispatchLoop calls the handlers for received messages and sends their acknowledgements afterwards.
+func (c *Client) dispatchLoop() {
+	for {
+		select {
+		case in := <-c.incoming:
+			if in.deliver {
+				c.dispatch(in.publish)
+			}
+			if in.ack == nil {
+				continue
+			}
+			c.receiveQuota.HandleAck(in.ack)
+			if err := c.write(in.ack); err != nil {
+				c.fail(err)
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Client) dispatch(publish packet.Publish) {
+	handlers := c.sess.handlers(publish.Topic.String())
+	if len(handlers) == 0 && c.opts.DefaultHandler != nil {
+		handlers = append(handlers, c.opts.DefaultHandler)
+	}
+	for _, handler := range handlers {
+		handler(publish)
+	}
+}
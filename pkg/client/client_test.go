@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/broker"
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/router"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//startServer runs the reference broker on localhost.
+func startServer(t *testing.T) (*broker.Server, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := broker.NewServer(clock.NewFake(time.Unix(0, 0)))
+	go s.Serve(l)
+	return s, l.Addr().String()
+}
+
+func testContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 2*time.Second)
+}
+
+func dial(t *testing.T, addr, clientID string, opts Options) *Client {
+	t.Helper()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	c, err := Dial(ctx, addr, packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: clientID}}, opts)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	return c
+}
+
+func newPublish(t *testing.T, qos byte, name, payload string) packet.Publish {
+	t.Helper()
+	tpc, err := topic.ParseTopic(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet.Publish{Qos: qos, Topic: tpc, Payload: []byte(payload)}
+}
+
+func receive(t *testing.T, received <-chan packet.Publish) packet.Publish {
+	t.Helper()
+	select {
+	case publish := <-received:
+		return publish
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+	}
+	return packet.Publish{}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	s, addr := startServer(t)
+	defer s.Close()
+	c := dial(t, addr, "c", Options{})
+	defer c.Disconnect(packet.DisconnectNormalDisconnection)
+
+	received := make(chan packet.Publish, 1)
+	ctx, cancel := testContext()
+	defer cancel()
+	suback, err := c.Subscribe(ctx, Subscription{
+		SubscriptionFilter: packet.SubscriptionFilter{Filter: "a/+", MaxQoS: packet.Qos2},
+		Handler:            func(publish packet.Publish) { received <- publish },
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if len(suback.Reasons) != 1 || suback.Reasons[0] != packet.SubackGrantedQoS2 {
+		t.Fatalf("suback reasons = %v", suback.Reasons)
+	}
+
+	tests := []struct {
+		name    string
+		qos     byte
+		wantAck packet.Packet
+	}{
+		{name: "qos 0", qos: packet.Qos0},
+		{name: "qos 1", qos: packet.Qos1, wantAck: &packet.Puback{}},
+		{name: "qos 2", qos: packet.Qos2, wantAck: &packet.Pubcomp{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			future, err := c.Publish(ctx, newPublish(t, tt.qos, "a/b", tt.name))
+			if err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+			ack, err := future.Wait(ctx)
+			if err != nil {
+				t.Fatalf("Wait() error = %v", err)
+			}
+			if fmt.Sprintf("%T", ack) != fmt.Sprintf("%T", tt.wantAck) {
+				t.Errorf("Wait() = %T, want %T", ack, tt.wantAck)
+			}
+
+			publish := receive(t, received)
+			if string(publish.Payload) != tt.name || publish.Qos != tt.qos {
+				t.Errorf("received publish = %+v", publish)
+			}
+		})
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	s, addr := startServer(t)
+	defer s.Close()
+
+	received := make(chan packet.Publish, 1)
+	c := dial(t, addr, "c", Options{DefaultHandler: func(publish packet.Publish) { received <- publish }})
+	defer c.Disconnect(packet.DisconnectNormalDisconnection)
+
+	ctx, cancel := testContext()
+	defer cancel()
+	if _, err := c.Subscribe(ctx, Subscription{SubscriptionFilter: packet.SubscriptionFilter{Filter: "a"}}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	unsuback, err := c.Unsubscribe(ctx, "a", "b")
+	if err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	want := []packet.UnsubackReason{packet.UnsubackSuccess, packet.UnsubackNoSubscriptionExisted}
+	if len(unsuback.Reasons) != len(want) || unsuback.Reasons[0] != want[0] || unsuback.Reasons[1] != want[1] {
+		t.Errorf("unsuback reasons = %v, want %v", unsuback.Reasons, want)
+	}
+
+	future, err := c.Publish(ctx, newPublish(t, packet.Qos1, "a", "x"))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if _, err := future.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	select {
+	case publish := <-received:
+		t.Errorf("received %+v after unsubscribing", publish)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDefaultHandler(t *testing.T) {
+	s, addr := startServer(t)
+	defer s.Close()
+
+	received := make(chan packet.Publish, 1)
+	c := dial(t, addr, "c", Options{DefaultHandler: func(publish packet.Publish) { received <- publish }})
+	defer c.Disconnect(packet.DisconnectNormalDisconnection)
+
+	ctx, cancel := testContext()
+	defer cancel()
+	// a subscription without handler is served by the default handler
+	if _, err := c.Subscribe(ctx, Subscription{SubscriptionFilter: packet.SubscriptionFilter{Filter: "#"}}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if _, err := c.Publish(ctx, newPublish(t, packet.Qos0, "x/y", "z")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if publish := receive(t, received); string(publish.Payload) != "z" {
+		t.Errorf("received publish = %+v", publish)
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	s, addr := startServer(t)
+	defer s.Close()
+	c := dial(t, addr, "c", Options{})
+
+	if err := c.Disconnect(packet.DisconnectNormalDisconnection); err != nil {
+		t.Fatalf("Disconnect() error = %v", err)
+	}
+	<-c.Done()
+	if err := c.Err(); err != ErrClosed {
+		t.Errorf("Err() = %v, want %v", err, ErrClosed)
+	}
+
+	ctx, cancel := testContext()
+	defer cancel()
+	if _, err := c.Subscribe(ctx, Subscription{SubscriptionFilter: packet.SubscriptionFilter{Filter: "a"}}); err == nil {
+		t.Error("Subscribe() after Disconnect() succeeded")
+	}
+}
+
+func TestPublishSessionClosed(t *testing.T) {
+	s, addr := startServer(t)
+	defer s.Close()
+	c := dial(t, addr, "c", Options{})
+	defer c.Disconnect(packet.DisconnectNormalDisconnection)
+	available := c.sendQuota.Available()
+
+	// the session fails while the network connection is still open, the message can't be tracked
+	c.sess.close(ErrClosed)
+	ctx, cancel := testContext()
+	defer cancel()
+	if _, err := c.Publish(ctx, newPublish(t, packet.Qos1, "a", "x")); err == nil {
+		t.Fatal("Publish() with closed session succeeded")
+	}
+	if got := c.sendQuota.Available(); got != available {
+		t.Errorf("send quota = %d after failed publish, want %d", got, available)
+	}
+	if inFlight := c.sess.sender.InFlight(); len(inFlight) != 0 {
+		t.Errorf("messages in flight after failed publish = %v", inFlight)
+	}
+}
+
+func TestSessionTakenOver(t *testing.T) {
+	s, addr := startServer(t)
+	defer s.Close()
+	c := dial(t, addr, "c", Options{})
+	other := dial(t, addr, "c", Options{})
+	defer other.Disconnect(packet.DisconnectNormalDisconnection)
+
+	select {
+	case <-c.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection not closed")
+	}
+	var disconnectErr *packet.DisconnectError
+	if !errors.As(c.Err(), &disconnectErr) || disconnectErr.Reason != packet.DisconnectSessionTakenOver {
+		t.Errorf("Err() = %v, want disconnect with reason %d", c.Err(), packet.DisconnectSessionTakenOver)
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	s, addr := startServer(t)
+	defer s.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	_, err := Dial(ctx, addr, packet.Connect{
+		Payload: packet.ConnectPayload{WillTopic: "a/+", WillProps: packet.NewProperties()},
+	}, Options{})
+	var connectErr *ConnectError
+	if !errors.As(err, &connectErr) || connectErr.Connack.ConnectReason != packet.ConnectTopicNameInvalid {
+		t.Errorf("Dial() error = %v, want connect error with reason %d", err, packet.ConnectTopicNameInvalid)
+	}
+}
+
+func TestSubscriptionIdentifier(t *testing.T) {
+	s, addr := startServer(t)
+	defer s.Close()
+	c := dial(t, addr, "c", Options{})
+	defer c.Disconnect(packet.DisconnectNormalDisconnection)
+
+	r := router.New()
+	received := make(chan packet.Publish, 1)
+	filter, err := topic.ParseFilter("a/+")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := r.Handle(filter, func(publish packet.Publish) { received <- publish })
+	if err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	ctx, cancel := testContext()
+	defer cancel()
+	if _, err := c.Subscribe(ctx,
+		Subscription{SubscriptionFilter: packet.SubscriptionFilter{Filter: "a/+"}, Identifier: id, Handler: r.Route},
+		Subscription{SubscriptionFilter: packet.SubscriptionFilter{Filter: "b"}, Identifier: id + 1},
+	); err != ErrMixedSubscriptionIDs {
+		t.Errorf("Subscribe() error = %v, want %v", err, ErrMixedSubscriptionIDs)
+	}
+	if _, err := c.Subscribe(ctx, Subscription{SubscriptionFilter: packet.SubscriptionFilter{Filter: "a/+"}, Identifier: id, Handler: r.Route}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if _, err := c.Publish(ctx, newPublish(t, packet.Qos0, "a/b", "x")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	publish := receive(t, received)
+	if ids := publish.Props.VarInt(packet.SubscriptionIdentifier); len(ids) != 1 || ids[0] != id {
+		t.Errorf("subscription identifiers = %v, want [%d]", ids, id)
+	}
+}
@@ -0,0 +1,10 @@
+package client
+
+/*
+Package client implements an mqtt 5 client on top of the packages of this module.
+A Client sends and receives control packets on a single network connection:
+Publish returns a Future that completes with the acknowledgement of the message,
+Subscribe registers a Handler per filter that is called for matching messages.
+A Supervisor reconnects whenever the network connection is lost and resumes the session,
+messages published in the meantime are buffered.
+*/
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//ReasonError is returned if the server acknowledged a request with a reason code indicating an error.
+type ReasonError struct {
+	Ack    packet.Packet
+	Reason byte
+}
+
+//Error implements the error interface.
+func (e *ReasonError) Error() string {
+	return fmt.Sprintf("request failed with reason code %d", e.Reason)
+}
+
+//Future is the result of an asynchronous request, e.g. the acknowledgement of a QoS 1 or QoS 2 message.
+type Future struct {
+	done chan struct{}
+	ack  packet.Packet
+	err  error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+//complete sets the result; it must be called exactly once.
+func (f *Future) complete(ack packet.Packet, err error) {
+	f.ack = ack
+	f.err = err
+	close(f.done)
+}
+
+//Done is closed once the result is available.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+//Wait blocks until the result is available or ctx is done.
+//It returns the acknowledgement that completed the request:
+//nil for QoS 0 messages, a puback for QoS 1 messages, a pubcomp or a pubrec with an error for QoS 2 messages.
+//If the reason code of the acknowledgement indicates an error, a *ReasonError is returned along with it.
+func (f *Future) Wait(ctx context.Context) (packet.Packet, error) {
+	select {
+	case <-f.done:
+		return f.ack, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//ErrNoResponseInformation is returned by RPC.Request if the server sent no response information.
+//It is only sent if the connect control packet has the RequestResponseInformation property set to 1 (3.1.2.11.7).
+var ErrNoResponseInformation = errors.New("client: server sent no response information")
+
+//PubSub is implemented by Client and Supervisor.
+type PubSub interface {
+	Publish(ctx context.Context, publish packet.Publish) (*Future, error)
+	Subscribe(ctx context.Context, subs ...Subscription) (*packet.Suback, error)
+}
+
+//Responder returns the response payload for a request.
+type Responder func(request packet.Publish) []byte
+
+//RPC implements request/response on top of publish and subscribe (4.10).
+//Requests carry a response topic and correlation data, responses copy the correlation data back.
+//It is safe for concurrent use.
+type RPC struct {
+	pubsub        PubSub
+	responseTopic string
+
+	// subscribeMu is held while subscribing, mu must not be, it is needed to handle responses
+	subscribeMu sync.Mutex
+	subscribed  bool
+
+	mu      sync.Mutex
+	next    uint64
+	pending map[string]chan packet.Publish
+}
+
+//NewRPC returns an RPC using pubsub.
+//The response topic of requests is derived from the response information in connack.
+func NewRPC(pubsub PubSub, connack packet.Connack) *RPC {
+	r := &RPC{
+		pubsub:  pubsub,
+		pending: make(map[string]chan packet.Publish),
+	}
+	if info, ok := connack.Props.StringProp(packet.ResponseInformation); ok && info != "" {
+		// several RPCs of a client don't receive each other's responses
+		suffix := make([]byte, 8)
+		if _, err := rand.Read(suffix); err == nil {
+			r.responseTopic = strings.TrimSuffix(info, "/") + "/" + hex.EncodeToString(suffix)
+		}
+	}
+	return r
+}
+
+//Request publishes payload to the topic name and waits for the response.
+//The response topic is subscribed to with the first request.
+func (r *RPC) Request(ctx context.Context, name string, payload []byte) (packet.Publish, error) {
+	if r.responseTopic == "" {
+		return packet.Publish{}, ErrNoResponseInformation
+	}
+	tpc, err := topic.ParseTopic(name)
+	if err != nil {
+		return packet.Publish{}, err
+	}
+	if err := r.subscribe(ctx); err != nil {
+		return packet.Publish{}, err
+	}
+
+	r.mu.Lock()
+	r.next++
+	correlation := strconv.FormatUint(r.next, 10)
+	response := make(chan packet.Publish, 1)
+	r.pending[correlation] = response
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, correlation)
+		r.mu.Unlock()
+	}()
+
+	future, err := r.pubsub.Publish(ctx, packet.Publish{
+		Qos:   packet.Qos1,
+		Topic: tpc,
+		Props: packet.NewProperties(
+			packet.NewProperty(packet.ResponseTopic, packet.StringPropPayload(r.responseTopic)),
+			packet.NewProperty(packet.CorrelationData, packet.BinaryPropPayload(correlation)),
+		),
+		Payload: payload,
+	})
+	if err != nil {
+		return packet.Publish{}, err
+	}
+	if _, err := future.Wait(ctx); err != nil {
+		return packet.Publish{}, err
+	}
+
+	select {
+	case publish := <-response:
+		return publish, nil
+	case <-ctx.Done():
+		return packet.Publish{}, ctx.Err()
+	}
+}
+
+func (r *RPC) subscribe(ctx context.Context) error {
+	r.subscribeMu.Lock()
+	defer r.subscribeMu.Unlock()
+
+	if r.subscribed {
+		return nil
+	}
+	suback, err := r.pubsub.Subscribe(ctx, Subscription{
+		SubscriptionFilter: packet.SubscriptionFilter{Filter: r.responseTopic, MaxQoS: packet.Qos1, NoLocal: true},
+		Handler:            r.handleResponse,
+	})
+	if err != nil {
+		return err
+	}
+	if len(suback.Reasons) == 1 && suback.Reasons[0] >= 0x80 {
+		return &ReasonError{Ack: suback, Reason: byte(suback.Reasons[0])}
+	}
+	r.subscribed = true
+	return nil
+}
+
+func (r *RPC) handleResponse(publish packet.Publish) {
+	correlation, ok := publish.Props.Binary(packet.CorrelationData)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	response, ok := r.pending[string(correlation)]
+	r.mu.Unlock()
+	if !ok {
+		// the request was cancelled
+		return
+	}
+	select {
+	case response <- publish:
+	default:
+	}
+}
+
+//Handle subscribes to filter and answers every request published to a matching topic with the result of responder.
+//The response is published to the response topic of the request with its correlation data (4.10.1);
+//requests without response topic are ignored.
+func (r *RPC) Handle(ctx context.Context, filter string, responder Responder) (*packet.Suback, error) {
+	return r.pubsub.Subscribe(ctx, Subscription{
+		SubscriptionFilter: packet.SubscriptionFilter{Filter: filter, MaxQoS: packet.Qos1},
+		Handler: func(request packet.Publish) {
+			name, ok := request.Props.StringProp(packet.ResponseTopic)
+			if !ok {
+				return
+			}
+			responseTopic, err := topic.ParseTopic(name)
+			if err != nil {
+				log.Printf("client: request with invalid response topic %q: %v", name, err)
+				return
+			}
+
+			props := packet.NewProperties()
+			if correlation, ok := request.Props.Binary(packet.CorrelationData); ok {
+				props.Add(packet.NewProperty(packet.CorrelationData, packet.BinaryPropPayload(correlation)))
+			}
+			response := packet.Publish{
+				Qos:     packet.Qos1,
+				Topic:   responseTopic,
+				Props:   props,
+				Payload: responder(request),
+			}
+			// handlers must not block on the acknowledgements they depend on
+			go func() {
+				if _, err := r.pubsub.Publish(context.Background(), response); err != nil {
+					log.Printf("client: failed to publish response: %v", err)
+				}
+			}()
+		},
+	})
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+func dialRPC(t *testing.T, addr, clientID string) (*Client, *RPC) {
+	t.Helper()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	connect := packet.Connect{
+		CleanStart: true,
+		Props:      packet.NewProperties(packet.NewProperty(packet.RequestResponseInformation, packet.BytePropPayload(1))),
+		Payload:    packet.ConnectPayload{ClientID: clientID},
+	}
+	c, err := Dial(ctx, addr, connect, Options{})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	return c, NewRPC(c, c.Connack())
+}
+
+func TestRPC(t *testing.T) {
+	s, addr := startServer(t)
+	defer s.Close()
+	responder, responderRPC := dialRPC(t, addr, "responder")
+	defer responder.Disconnect(packet.DisconnectNormalDisconnection)
+	requester, requesterRPC := dialRPC(t, addr, "requester")
+	defer requester.Disconnect(packet.DisconnectNormalDisconnection)
+
+	ctx, cancel := testContext()
+	defer cancel()
+	if _, err := responderRPC.Handle(ctx, "service/+", func(request packet.Publish) []byte {
+		return bytes.ToUpper(request.Payload)
+	}); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	for _, payload := range []string{"a", "b"} {
+		response, err := requesterRPC.Request(ctx, "service/upper", []byte(payload))
+		if err != nil {
+			t.Fatalf("Request() error = %v", err)
+		}
+		if want := bytes.ToUpper([]byte(payload)); !bytes.Equal(response.Payload, want) {
+			t.Errorf("response payload = %q, want %q", response.Payload, want)
+		}
+		if _, ok := response.Props.Binary(packet.CorrelationData); !ok {
+			t.Error("response without correlation data")
+		}
+	}
+}
+
+func TestRPCTimeout(t *testing.T) {
+	s, addr := startServer(t)
+	defer s.Close()
+	c, rpc := dialRPC(t, addr, "requester")
+	defer c.Disconnect(packet.DisconnectNormalDisconnection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := rpc.Request(ctx, "service/none", nil); err != context.DeadlineExceeded {
+		t.Errorf("Request() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRPCNoResponseInformation(t *testing.T) {
+	s, addr := startServer(t)
+	defer s.Close()
+	c := dial(t, addr, "requester", Options{})
+	defer c.Disconnect(packet.DisconnectNormalDisconnection)
+
+	ctx, cancel := testContext()
+	defer cancel()
+	if _, err := NewRPC(c, c.Connack()).Request(ctx, "service/a", nil); err != ErrNoResponseInformation {
+		t.Errorf("Request() error = %v, want %v", err, ErrNoResponseInformation)
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/qos"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//session is the session state of the client (4.1).
+//A Client created by Connect has a session of its own, the session of a Supervisor outlives its network connections.
+type session struct {
+	ids      *packet.IDAllocator
+	sender   *qos.Sender
+	receiver *qos.Receiver
+
+	mu       sync.Mutex
+	subs     map[string]Subscription
+	inFlight map[uint16]*Future
+	err      error
+}
+
+func newSession() *session {
+	ids := packet.NewIDAllocator()
+	return &session{
+		ids:      ids,
+		sender:   qos.NewSender(ids),
+		receiver: qos.NewReceiver(),
+		subs:     make(map[string]Subscription),
+		inFlight: make(map[uint16]*Future),
+	}
+}
+
+//track registers the future of the message in flight with packet identifier id.
+func (s *session) track(id uint16, future *Future) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	s.inFlight[id] = future
+	return nil
+}
+
+//resolve completes the future of the message in flight with packet identifier id.
+func (s *session) resolve(id uint16, ack packet.Packet, err error) {
+	s.mu.Lock()
+	future, ok := s.inFlight[id]
+	delete(s.inFlight, id)
+	s.mu.Unlock()
+
+	if ok {
+		future.complete(ack, err)
+	}
+}
+
+//close fails the futures of all messages in flight with err; no messages can be tracked afterwards.
+func (s *session) close(err error) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.err = err
+	inFlight := s.inFlight
+	s.inFlight = make(map[uint16]*Future)
+	s.mu.Unlock()
+
+	for _, future := range inFlight {
+		future.complete(nil, err)
+	}
+}
+
+//subscriptions returns the subscriptions of the session.
+func (s *session) subscriptions() []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+//handlers returns the handlers of the subscriptions matching the topic name.
+func (s *session) handlers(name string) []Handler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var handlers []Handler
+	for filter, sub := range s.subs {
+		if sub.Handler != nil && topic.Match(filter, name) {
+			handlers = append(handlers, sub.Handler)
+		}
+	}
+	return handlers
+}
+
+//discard is used if the server has no session state of the client, so the session state of the client has to be discarded (3.2.2.1.2).
+//It returns a new session with the subscriptions of s and the messages in flight that the server hasn't taken ownership of with their futures,
+//so they can be published again.
+//The futures of QoS 2 messages the server acknowledged with a pubrec complete, the server has taken ownership of them (4.3.3).
+func (s *session) discard() (*session, []packet.Publish, []*Future) {
+	next := newSession()
+
+	s.mu.Lock()
+	for filter, sub := range s.subs {
+		next.subs[filter] = sub
+	}
+
+	var publishes []packet.Publish
+	var futures, owned []*Future
+	for _, msg := range s.sender.InFlight() {
+		future, ok := s.inFlight[msg.Publish.PacketID]
+		if !ok {
+			continue
+		}
+		delete(s.inFlight, msg.Publish.PacketID)
+		if msg.State == qos.AwaitingPubcomp {
+			owned = append(owned, future)
+			continue
+		}
+		publishes = append(publishes, msg.Publish)
+		futures = append(futures, future)
+	}
+	s.mu.Unlock()
+
+	for _, future := range owned {
+		future.complete(nil, nil)
+	}
+	return next, publishes, futures
+}
@@ -0,0 +1,435 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//ErrBufferFull is returned by Supervisor.Publish if the limit of buffered offline messages is reached.
+var ErrBufferFull = errors.New("client: offline buffer is full")
+
+//defaultConnectTimeout limits the time to establish a connection if SupervisorOptions.ConnectTimeout is 0.
+const defaultConnectTimeout = 10 * time.Second
+
+//Dialer opens a network connection to the server.
+type Dialer func(ctx context.Context) (net.Conn, error)
+
+//TCPDialer returns a Dialer for the TCP address addr.
+func TCPDialer(addr string) Dialer {
+	return func(ctx context.Context) (net.Conn, error) {
+		return dialTCP(ctx, addr)
+	}
+}
+
+//Backoff defines the delays between reconnect attempts.
+//The delay starts at Min and doubles with every failed attempt up to Max.
+//Jitter is the fraction of each delay that is randomized, so that clients don't reconnect in lockstep.
+type Backoff struct {
+	Min    time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+//DefaultBackoff is used if SupervisorOptions.Backoff is the zero value.
+var DefaultBackoff = Backoff{Min: time.Second, Max: 2 * time.Minute, Jitter: 0.2}
+
+//Delay returns the delay before the reconnect attempt with the zero based index attempt.
+func (b Backoff) Delay(attempt int) time.Duration {
+	max := b.Max
+	if max < b.Min {
+		max = b.Min
+	}
+	d := b.Min
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if b.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * b.Jitter * float64(d))
+	}
+	return d
+}
+
+//SupervisorOptions configures a Supervisor.
+type SupervisorOptions struct {
+	Options
+	//Backoff defines the delays between reconnect attempts, DefaultBackoff if zero.
+	Backoff Backoff
+	//ConnectTimeout limits the time to establish a connection, 10 seconds if 0.
+	ConnectTimeout time.Duration
+	//MaxBuffered limits the number of messages published while the connection is lost.
+	//They are sent once the client reconnected; if the limit is reached, Publish returns ErrBufferFull.
+	MaxBuffered int
+	//OnConnectionLost is called with the reason once the connection is lost.
+	OnConnectionLost func(err error)
+	//OnReconnected is called with the connack once the client reconnected.
+	OnReconnected func(connack packet.Connack)
+}
+
+//Supervisor is a client that reconnects if the connection is lost and resumes its session (4.4).
+//If the server kept the session, messages in flight are resent, otherwise they are published again and the subscriptions are renewed.
+//It is safe for concurrent use.
+type Supervisor struct {
+	dial    Dialer
+	connect packet.Connect
+	opts    SupervisorOptions
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex
+	sess    *session
+	client  *Client
+	ready   chan struct{}
+	buffer  []buffered
+	stopped bool
+}
+
+//buffered is a message published while the connection is lost.
+type buffered struct {
+	publish packet.Publish
+	future  *Future
+}
+
+//Supervise connects to the server with dial and keeps reconnecting whenever the connection is lost until Disconnect is called.
+//Connect is sent as given for the first connection, later connections resume the session with CleanStart unset;
+//a session expiry interval keeps the session on the server in between (3.1.2.11.2).
+//An error is returned if the first connection fails.
+func Supervise(ctx context.Context, dial Dialer, connect packet.Connect, opts SupervisorOptions) (*Supervisor, error) {
+	if opts.Backoff == (Backoff{}) {
+		opts.Backoff = DefaultBackoff
+	}
+	if opts.ConnectTimeout == 0 {
+		opts.ConnectTimeout = defaultConnectTimeout
+	}
+
+	s := &Supervisor{
+		dial:    dial,
+		connect: connect,
+		opts:    opts,
+		sess:    newSession(),
+		ready:   make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	c, err := s.connectOnce(ctx, connect)
+	if err != nil {
+		s.cancel()
+		return nil, err
+	}
+	go s.run(c)
+	return s, nil
+}
+
+//Publish sends publish like Client.Publish.
+//While the connection is lost the message is buffered and sent once the client reconnected.
+func (s *Supervisor) Publish(ctx context.Context, publish packet.Publish) (*Future, error) {
+	future := newFuture()
+	for {
+		s.mu.Lock()
+		if s.stopped {
+			s.mu.Unlock()
+			return nil, ErrClosed
+		}
+		c := s.client
+		s.mu.Unlock()
+
+		if c != nil {
+			err := c.publish(ctx, publish, future)
+			if err == nil {
+				return future, nil
+			}
+			if !errors.Is(err, ErrConnectionLost) {
+				return nil, err
+			}
+		}
+
+		s.mu.Lock()
+		if s.client != nil && s.client != c {
+			// reconnected in the meantime
+			s.mu.Unlock()
+			continue
+		}
+		if len(s.buffer) >= s.opts.MaxBuffered {
+			s.mu.Unlock()
+			return nil, ErrBufferFull
+		}
+		s.buffer = append(s.buffer, buffered{publish: publish, future: future})
+		s.mu.Unlock()
+		return future, nil
+	}
+}
+
+//Subscribe subscribes like Client.Subscribe, the subscriptions are renewed if the server didn't keep the session.
+//While the connection is lost Subscribe waits until the client reconnected or ctx is done.
+func (s *Supervisor) Subscribe(ctx context.Context, subs ...Subscription) (*packet.Suback, error) {
+	c, err := s.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.Subscribe(ctx, subs...)
+}
+
+//Unsubscribe unsubscribes like Client.Unsubscribe.
+//While the connection is lost Unsubscribe waits until the client reconnected or ctx is done.
+func (s *Supervisor) Unsubscribe(ctx context.Context, filters ...string) (*packet.Unsuback, error) {
+	c, err := s.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.Unsubscribe(ctx, filters...)
+}
+
+//Disconnect stops reconnecting and disconnects with reason if connected.
+//Messages that haven't completed fail with ErrClosed.
+func (s *Supervisor) Disconnect(reason packet.DisconnectReason) error {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	s.stopped = true
+	c, sess, buffer := s.client, s.sess, s.buffer
+	s.buffer = nil
+	s.mu.Unlock()
+
+	s.cancel()
+	var err error
+	if c != nil {
+		err = c.Disconnect(reason)
+	}
+	sess.close(ErrClosed)
+	for _, b := range buffer {
+		b.future.complete(nil, ErrClosed)
+	}
+	return err
+}
+
+//current returns the connected Client, waiting for it while the connection is lost.
+func (s *Supervisor) current(ctx context.Context) (*Client, error) {
+	for {
+		s.mu.Lock()
+		c, ready, stopped := s.client, s.ready, s.stopped
+		s.mu.Unlock()
+
+		switch {
+		case stopped:
+			return nil, ErrClosed
+		case c != nil:
+			return c, nil
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *Supervisor) run(c *Client) {
+	for {
+		select {
+		case <-c.Done():
+		case <-s.ctx.Done():
+			return
+		}
+
+		s.mu.Lock()
+		if s.stopped {
+			s.mu.Unlock()
+			return
+		}
+		s.client = nil
+		s.ready = make(chan struct{})
+		s.mu.Unlock()
+		if s.opts.OnConnectionLost != nil {
+			s.opts.OnConnectionLost(c.Err())
+		}
+
+		resume := s.connect
+		resume.CleanStart = false
+		for attempt := 0; ; attempt++ {
+			if !s.sleep(s.opts.Backoff.Delay(attempt)) {
+				return
+			}
+			var err error
+			if c, err = s.connectOnce(s.ctx, resume); err == nil {
+				break
+			}
+		}
+		if s.opts.OnReconnected != nil {
+			s.opts.OnReconnected(c.Connack())
+		}
+	}
+}
+
+//sleep waits for d, it reports false if the Supervisor was stopped before.
+func (s *Supervisor) sleep(d time.Duration) bool {
+	elapsed := make(chan struct{})
+	timer := s.clock().AfterFunc(d, func() { close(elapsed) })
+	defer timer.Stop()
+
+	select {
+	case <-elapsed:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+func (s *Supervisor) clock() clock.Clock {
+	if s.opts.Clock == nil {
+		return clock.System
+	}
+	return s.opts.Clock
+}
+
+//connectOnce establishes a connection, resumes the session and sends the buffered messages.
+func (s *Supervisor) connectOnce(ctx context.Context, connect packet.Connect) (*Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.ConnectTimeout)
+	defer cancel()
+
+	netConn, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c, err := handshake(ctx, netConn, connect, s.opts.Options)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resume(ctx, c); err != nil {
+		c.fail(err)
+		return nil, err
+	}
+
+	for {
+		s.mu.Lock()
+		if s.stopped {
+			sess := s.sess
+			s.mu.Unlock()
+			c.Disconnect(packet.DisconnectNormalDisconnection)
+			sess.close(ErrClosed)
+			return nil, ErrClosed
+		}
+		if len(s.buffer) == 0 {
+			s.client = c
+			close(s.ready)
+			s.mu.Unlock()
+			return c, nil
+		}
+		buffer := s.buffer
+		s.buffer = nil
+		s.mu.Unlock()
+
+		// publish may wait for the send quota, s.mu isn't held meanwhile;
+		// messages published in the meantime are buffered and sent in the next round
+		for i, b := range buffer {
+			if err := c.publish(ctx, b.publish, b.future); err != nil {
+				c.fail(err)
+				s.rebuffer(buffer[i:])
+				return nil, err
+			}
+		}
+	}
+}
+
+//rebuffer returns messages that couldn't be sent to the front of the buffer, they are sent first after the next reconnect.
+//If the Supervisor was stopped in the meantime, they fail with ErrClosed instead.
+func (s *Supervisor) rebuffer(remaining []buffered) {
+	s.mu.Lock()
+	stopped := s.stopped
+	if !stopped {
+		s.buffer = append(append([]buffered(nil), remaining...), s.buffer...)
+	}
+	s.mu.Unlock()
+
+	if stopped {
+		for _, b := range remaining {
+			b.future.complete(nil, ErrClosed)
+		}
+	}
+}
+
+//resume starts c on the session of the Supervisor.
+//If the server kept the session, the messages in flight are resent (4.4).
+//Otherwise the session state is discarded (3.2.2.1.2): the messages in flight are published again and the subscriptions renewed.
+func (s *Supervisor) resume(ctx context.Context, c *Client) error {
+	s.mu.Lock()
+	sess := s.sess
+	s.mu.Unlock()
+
+	if c.Connack().SessionPresent {
+		c.start(sess, true)
+		for _, pkt := range sess.sender.Resend() {
+			// 4.9 the receive maximum of the server may have changed, resent publish control packets wait for the quota
+			if _, ok := pkt.(*packet.Publish); ok {
+				if err := c.sendQuota.Acquire(ctx); err != nil {
+					return err
+				}
+			}
+			if err := c.write(pkt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	sess, publishes, futures := sess.discard()
+	s.mu.Lock()
+	s.sess = sess
+	s.mu.Unlock()
+	c.start(sess, true)
+
+	for i, publish := range publishes {
+		if err := c.publish(ctx, publish, futures[i]); err != nil {
+			var remaining []buffered
+			for j := i; j < len(publishes); j++ {
+				remaining = append(remaining, buffered{publish: publishes[j], future: futures[j]})
+			}
+			s.rebuffer(remaining)
+			return err
+		}
+	}
+	return s.resubscribe(ctx, c)
+}
+
+//resubscribe renews the subscriptions of the session; the handlers of filters the server refused are removed.
+//A subscribe control packet is sent per subscription identifier.
+func (s *Supervisor) resubscribe(ctx context.Context, c *Client) error {
+	byID := make(map[uint32][]Subscription)
+	for _, sub := range c.sess.subscriptions() {
+		byID[sub.Identifier] = append(byID[sub.Identifier], sub)
+	}
+
+	for _, subs := range byID {
+		subscribe, err := newSubscribe(subs)
+		if err != nil {
+			return err
+		}
+		ack, err := c.request(ctx, subscribe, func(id uint16) { subscribe.PacketID = id })
+		if err != nil {
+			return err
+		}
+		suback, ok := ack.(*packet.Suback)
+		if !ok {
+			continue
+		}
+
+		c.sess.mu.Lock()
+		for i, reason := range suback.Reasons {
+			if i < len(subs) && reason >= 0x80 {
+				delete(c.sess.subs, subs[i].Filter)
+			}
+		}
+		c.sess.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+package client
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//fakeServer accepts connections of the Supervisor under test, the test plays the server.
+type fakeServer struct {
+	t *testing.T
+	l *net.TCPListener
+}
+
+func listen(t *testing.T) *fakeServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	return &fakeServer{t: t, l: l.(*net.TCPListener)}
+}
+
+//serverConn is a connection of the fakeServer.
+type serverConn struct {
+	t    *testing.T
+	net  net.Conn
+	pkts *packet.Conn
+}
+
+//accept accepts the next connection and returns it with the connect control packet received on it.
+func (s *fakeServer) accept() (*serverConn, *packet.Connect) {
+	s.t.Helper()
+	if err := s.l.SetDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		s.t.Fatal(err)
+	}
+	netConn, err := s.l.Accept()
+	if err != nil {
+		s.t.Fatalf("Accept() error = %v", err)
+	}
+	c := &serverConn{t: s.t, net: netConn, pkts: packet.NewConn(netConn)}
+	connect, ok := c.expect().(*packet.Connect)
+	if !ok {
+		s.t.Fatal("expected connect")
+	}
+	return c, connect
+}
+
+func (c *serverConn) send(pkt packet.Packet) {
+	c.t.Helper()
+	if err := c.pkts.WritePacket(pkt); err != nil {
+		c.t.Fatalf("WritePacket() error = %v", err)
+	}
+}
+
+func (c *serverConn) connack(sessionPresent bool) {
+	c.t.Helper()
+	c.send(&packet.Connack{SessionPresent: sessionPresent, ConnectReason: packet.ConnectSuccess, Props: packet.NewProperties()})
+}
+
+func (c *serverConn) expect() packet.Packet {
+	c.t.Helper()
+	if err := c.pkts.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		c.t.Fatal(err)
+	}
+	pkt, err := c.pkts.ReadPacket()
+	if err != nil {
+		c.t.Fatalf("ReadPacket() error = %v", err)
+	}
+	return pkt
+}
+
+func (c *serverConn) expectPublish() *packet.Publish {
+	c.t.Helper()
+	pkt := c.expect()
+	publish, ok := pkt.(*packet.Publish)
+	if !ok {
+		c.t.Fatalf("received %T, want publish", pkt)
+	}
+	return publish
+}
+
+//supervise starts a Supervisor connected to s; the connection is returned.
+func supervise(t *testing.T, s *fakeServer, opts SupervisorOptions) (*Supervisor, *serverConn) {
+	t.Helper()
+	if opts.Backoff == (Backoff{}) {
+		opts.Backoff = Backoff{Min: time.Millisecond, Max: 10 * time.Millisecond}
+	}
+	type result struct {
+		sup *Supervisor
+		err error
+	}
+	started := make(chan result, 1)
+	go func() {
+		ctx, cancel := testContext()
+		defer cancel()
+		sup, err := Supervise(ctx, TCPDialer(s.l.Addr().String()), packet.Connect{CleanStart: true, Payload: packet.ConnectPayload{ClientID: "c"}}, opts)
+		started <- result{sup: sup, err: err}
+	}()
+
+	conn, connect := s.accept()
+	if !connect.CleanStart {
+		t.Error("first connect has CleanStart unset")
+	}
+	conn.connack(false)
+	res := <-started
+	if res.err != nil {
+		t.Fatalf("Supervise() error = %v", res.err)
+	}
+	return res.sup, conn
+}
+
+//hooks records the calls of the hooks of a Supervisor.
+type hooks struct {
+	lost        chan error
+	reconnected chan packet.Connack
+}
+
+func newHooks(opts *SupervisorOptions) hooks {
+	h := hooks{lost: make(chan error, 1), reconnected: make(chan packet.Connack, 1)}
+	opts.OnConnectionLost = func(err error) { h.lost <- err }
+	opts.OnReconnected = func(connack packet.Connack) { h.reconnected <- connack }
+	return h
+}
+
+func wait(t *testing.T, done <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestSupervisorResend(t *testing.T) {
+	s := listen(t)
+	defer s.l.Close()
+	var opts SupervisorOptions
+	h := newHooks(&opts)
+	sup, conn := supervise(t, s, opts)
+	defer sup.Disconnect(packet.DisconnectNormalDisconnection)
+
+	ctx, cancel := testContext()
+	defer cancel()
+	future, err := sup.Publish(ctx, newPublish(t, packet.Qos1, "a", "x"))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	sent := conn.expectPublish()
+	conn.net.Close()
+	select {
+	case <-h.lost:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnConnectionLost not called")
+	}
+
+	conn, connect := s.accept()
+	if connect.CleanStart {
+		t.Error("reconnect has CleanStart set")
+	}
+	conn.connack(true)
+	resent := conn.expectPublish()
+	if !resent.Dup || resent.PacketID != sent.PacketID || string(resent.Payload) != "x" {
+		t.Errorf("resent publish = %+v, want duplicate of %+v", resent, sent)
+	}
+	select {
+	case connack := <-h.reconnected:
+		if !connack.SessionPresent {
+			t.Error("OnReconnected called with SessionPresent unset")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnReconnected not called")
+	}
+
+	conn.send(&packet.Puback{PacketID: resent.PacketID, Props: packet.NewProperties()})
+	if _, err := future.Wait(ctx); err != nil {
+		t.Errorf("Wait() error = %v", err)
+	}
+}
+
+func TestSupervisorResendWithinReceiveMaximum(t *testing.T) {
+	s := listen(t)
+	defer s.l.Close()
+	var opts SupervisorOptions
+	h := newHooks(&opts)
+	sup, conn := supervise(t, s, opts)
+	defer sup.Disconnect(packet.DisconnectNormalDisconnection)
+
+	ctx, cancel := testContext()
+	defer cancel()
+	for _, payload := range []string{"1", "2"} {
+		if _, err := sup.Publish(ctx, newPublish(t, packet.Qos1, "a", payload)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		conn.expectPublish()
+	}
+	conn.net.Close()
+	<-h.lost
+
+	conn, _ = s.accept()
+	props := packet.NewProperties()
+	props.Add(packet.NewProperty(packet.ReceiveMaximum, packet.Int16PropPayload(1)))
+	conn.send(&packet.Connack{SessionPresent: true, ConnectReason: packet.ConnectSuccess, Props: props})
+	first := conn.expectPublish()
+	if string(first.Payload) != "1" {
+		t.Fatalf("resent publish = %+v, want payload 1", first)
+	}
+	// the second message is resent only once the first is acknowledged
+	if err := conn.pkts.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if pkt, err := conn.pkts.ReadPacket(); err == nil {
+		t.Fatalf("received %+v beyond the receive maximum", pkt)
+	}
+	conn.send(&packet.Puback{PacketID: first.PacketID, Props: packet.NewProperties()})
+	if second := conn.expectPublish(); string(second.Payload) != "2" {
+		t.Errorf("resent publish = %+v, want payload 2", second)
+	}
+	<-h.reconnected
+}
+
+func TestSupervisorSessionLost(t *testing.T) {
+	s := listen(t)
+	defer s.l.Close()
+	var opts SupervisorOptions
+	h := newHooks(&opts)
+	sup, conn := supervise(t, s, opts)
+	defer sup.Disconnect(packet.DisconnectNormalDisconnection)
+
+	ctx, cancel := testContext()
+	defer cancel()
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		if _, err := sup.Subscribe(ctx, Subscription{SubscriptionFilter: packet.SubscriptionFilter{Filter: "s/#", MaxQoS: packet.Qos1}}); err != nil {
+			t.Errorf("Subscribe() error = %v", err)
+		}
+	}()
+	subscribe, ok := conn.expect().(*packet.Subscribe)
+	if !ok {
+		t.Fatal("expected subscribe")
+	}
+	conn.send(&packet.Suback{PacketID: subscribe.PacketID, Props: packet.NewProperties(), Reasons: []packet.SubackReason{packet.SubackQoS1Granted}})
+	wait(t, subscribed, "suback")
+
+	future, err := sup.Publish(ctx, newPublish(t, packet.Qos1, "a", "x"))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	conn.expectPublish()
+	conn.net.Close()
+	<-h.lost
+
+	conn, _ = s.accept()
+	conn.connack(false)
+	republished := conn.expectPublish()
+	if republished.Dup || string(republished.Payload) != "x" {
+		t.Errorf("republished publish = %+v", republished)
+	}
+	subscribe, ok = conn.expect().(*packet.Subscribe)
+	if !ok || len(subscribe.Filters) != 1 || subscribe.Filters[0].Filter != "s/#" || subscribe.Filters[0].MaxQoS != packet.Qos1 {
+		t.Fatalf("resubscribe = %+v", subscribe)
+	}
+	conn.send(&packet.Suback{PacketID: subscribe.PacketID, Props: packet.NewProperties(), Reasons: []packet.SubackReason{packet.SubackQoS1Granted}})
+	conn.send(&packet.Puback{PacketID: republished.PacketID, Props: packet.NewProperties()})
+
+	if _, err := future.Wait(ctx); err != nil {
+		t.Errorf("Wait() error = %v", err)
+	}
+	<-h.reconnected
+}
+
+func TestSupervisorBuffer(t *testing.T) {
+	s := listen(t)
+	defer s.l.Close()
+	opts := SupervisorOptions{MaxBuffered: 1}
+	h := newHooks(&opts)
+	sup, conn := supervise(t, s, opts)
+	defer sup.Disconnect(packet.DisconnectNormalDisconnection)
+
+	conn.net.Close()
+	<-h.lost
+
+	ctx, cancel := testContext()
+	defer cancel()
+	future, err := sup.Publish(ctx, newPublish(t, packet.Qos0, "a", "buffered"))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if _, err := sup.Publish(ctx, newPublish(t, packet.Qos0, "a", "dropped")); err != ErrBufferFull {
+		t.Errorf("Publish() error = %v, want %v", err, ErrBufferFull)
+	}
+
+	conn, _ = s.accept()
+	conn.connack(false)
+	if publish := conn.expectPublish(); string(publish.Payload) != "buffered" {
+		t.Errorf("received publish = %+v", publish)
+	}
+	wait(t, future.Done(), "buffered message")
+	<-h.reconnected
+}
+
+func TestSupervisorDisconnect(t *testing.T) {
+	s := listen(t)
+	defer s.l.Close()
+	opts := SupervisorOptions{MaxBuffered: 1}
+	h := newHooks(&opts)
+	sup, conn := supervise(t, s, opts)
+
+	conn.net.Close()
+	<-h.lost
+	ctx, cancel := testContext()
+	defer cancel()
+	future, err := sup.Publish(ctx, newPublish(t, packet.Qos1, "a", "x"))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	if err := sup.Disconnect(packet.DisconnectNormalDisconnection); err != nil {
+		t.Errorf("Disconnect() error = %v", err)
+	}
+	if _, err := future.Wait(ctx); err != ErrClosed {
+		t.Errorf("Wait() error = %v, want %v", err, ErrClosed)
+	}
+	if _, err := sup.Publish(ctx, newPublish(t, packet.Qos0, "a", "x")); err != ErrClosed {
+		t.Errorf("Publish() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestSupervisorDisconnectWhileSendingBuffer(t *testing.T) {
+	s := listen(t)
+	defer s.l.Close()
+	opts := SupervisorOptions{MaxBuffered: 1}
+	h := newHooks(&opts)
+	sup, conn := supervise(t, s, opts)
+
+	ctx, cancel := testContext()
+	defer cancel()
+	if _, err := sup.Publish(ctx, newPublish(t, packet.Qos1, "a", "in flight")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	conn.expectPublish()
+	conn.net.Close()
+	<-h.lost
+	future, err := sup.Publish(ctx, newPublish(t, packet.Qos1, "a", "buffered"))
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	// the resent message takes the only quota, the buffered one waits for its puback
+	conn, _ = s.accept()
+	props := packet.NewProperties()
+	props.Add(packet.NewProperty(packet.ReceiveMaximum, packet.Int16PropPayload(1)))
+	conn.send(&packet.Connack{SessionPresent: true, ConnectReason: packet.ConnectSuccess, Props: props})
+	conn.expectPublish()
+
+	disconnected := make(chan struct{})
+	go func() {
+		defer close(disconnected)
+		sup.Disconnect(packet.DisconnectNormalDisconnection)
+	}()
+	wait(t, disconnected, "Disconnect")
+	if _, err := future.Wait(ctx); err != ErrClosed {
+		t.Errorf("Wait() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Min: time.Second, Max: 10 * time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: time.Second},
+		{attempt: 1, want: 2 * time.Second},
+		{attempt: 3, want: 8 * time.Second},
+		{attempt: 4, want: 10 * time.Second},
+		{attempt: 100, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := b.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+
+		jittered := Backoff{Min: b.Min, Max: b.Max, Jitter: 0.5}
+		if got := jittered.Delay(tt.attempt); got > tt.want || got < tt.want/2 {
+			t.Errorf("Delay(%d) with jitter = %v, want between %v and %v", tt.attempt, got, tt.want/2, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package clock
+
+import "time"
+
+//Clock provides the current time and timers.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+//Timer is a timer created by a Clock, see time.Timer.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+//System is the Clock backed by package time.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+//Now implements Clock.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+//AfterFunc implements Clock.
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
@@ -0,0 +1,6 @@
+package clock
+
+/*
+Package clock abstracts time so timeouts and schedules can be tested deterministically.
+System is backed by package time, Fake only advances when told to.
+*/
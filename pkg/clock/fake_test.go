@@ -0,0 +1,48 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := NewFake(start)
+
+	var fired []string
+	c.AfterFunc(2*time.Second, func() { fired = append(fired, "2s") })
+	t1 := c.AfterFunc(time.Second, func() { fired = append(fired, "1s") })
+	t3 := c.AfterFunc(3*time.Second, func() { fired = append(fired, "3s") })
+	var t4 Timer
+	t4 = c.AfterFunc(4*time.Second, func() {
+		fired = append(fired, "4s")
+		t4.Reset(time.Second)
+	})
+
+	c.Advance(500 * time.Millisecond)
+	if len(fired) != 0 {
+		t.Errorf("timers fired early: %v", fired)
+	}
+
+	if !t3.Stop() {
+		t.Error("Stop() = false for active timer")
+	}
+	c.Advance(2 * time.Second)
+	if diff := deep.Equal(fired, []string{"1s", "2s"}); diff != nil {
+		t.Error(diff)
+	}
+	if t1.Stop() {
+		t.Error("Stop() = true for fired timer")
+	}
+
+	t1.Reset(time.Second)
+	c.Advance(3 * time.Second)
+	if diff := deep.Equal(fired, []string{"1s", "2s", "1s", "4s", "4s"}); diff != nil {
+		t.Error(diff)
+	}
+	if got, want := c.Now(), start.Add(5500*time.Millisecond); !got.Equal(want) {
+		t.Errorf("Now() = %v, want %v", got, want)
+	}
+}
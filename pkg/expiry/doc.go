@@ -0,0 +1,9 @@
+package expiry
+
+/*
+Package expiry implements the time based parts of sessions, will messages and application messages.
+A Scheduler ends sessions after their session expiry interval (3.1.2.11.2)
+and publishes will messages after their will delay interval (3.1.3.2.2).
+A Message tracks the message expiry interval of an application message while it waits to be forwarded (3.3.2.3.3).
+Its clock can be replaced, e.g. by a fake clock in tests.
+*/
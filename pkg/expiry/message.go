@@ -0,0 +1,67 @@
+package expiry
+
+import (
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//Message is an application message together with the time it was received, to track its message expiry interval (3.3.2.3.3).
+type Message struct {
+	Publish  packet.Publish
+	Received time.Time
+}
+
+//interval returns the message expiry interval of m in seconds and reports whether it has one.
+func (m Message) interval() (uint32, bool) {
+	props := m.Publish.Props[packet.MessageExpiryInterval]
+	if len(props) == 0 {
+		return 0, false
+	}
+	interval, ok := props[0].Payload.(packet.Int32PropPayload)
+	return uint32(interval), ok
+}
+
+//elapsed returns the whole seconds elapsed since m was received.
+func (m Message) elapsed(now time.Time) uint32 {
+	d := now.Sub(m.Received)
+	if d <= 0 {
+		return 0
+	}
+	return uint32(d / time.Second)
+}
+
+//Expired reports whether the message expiry interval of m has passed at now.
+//Messages without message expiry interval don't expire.
+func (m Message) Expired(now time.Time) bool {
+	interval, ok := m.interval()
+	return ok && m.elapsed(now) >= interval
+}
+
+//Outbound returns the publish control packet of m as it is forwarded at now.
+//Its message expiry interval is reduced by the time m spent waiting (3.3.2.3.3), the properties of m are left unchanged.
+//It reports false if m has expired and must not be forwarded anymore.
+func (m Message) Outbound(now time.Time) (packet.Publish, bool) {
+	publish := m.Publish
+	interval, ok := m.interval()
+	if !ok {
+		return publish, true
+	}
+	elapsed := m.elapsed(now)
+	if elapsed >= interval {
+		return publish, false
+	}
+	if elapsed == 0 {
+		return publish, true
+	}
+
+	adjusted := packet.NewProperties()
+	for propID, propsForID := range publish.Props {
+		if propID != packet.MessageExpiryInterval {
+			adjusted[propID] = propsForID
+		}
+	}
+	adjusted.Add(packet.NewProperty(packet.MessageExpiryInterval, packet.Int32PropPayload(interval-elapsed)))
+	publish.Props = adjusted
+	return publish, true
+}
@@ -0,0 +1,54 @@
+package expiry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+func TestMessage(t *testing.T) {
+	received := time.Unix(1000, 0)
+	contentType := packet.NewProperty(packet.ContentType, packet.StringPropPayload("text/plain"))
+	withInterval := func(interval uint32) packet.Properties {
+		return packet.NewProperties(packet.NewProperty(packet.MessageExpiryInterval, packet.Int32PropPayload(interval)), contentType)
+	}
+
+	tests := []struct {
+		name    string
+		props   packet.Properties
+		elapsed time.Duration
+		// want are the properties of the outbound publish, nil if the message expired
+		want packet.Properties
+	}{
+		{name: "no expiry", props: packet.NewProperties(contentType), elapsed: time.Hour, want: packet.NewProperties(contentType)},
+		{name: "not elapsed", props: withInterval(10), elapsed: 0, want: withInterval(10)},
+		{name: "partially elapsed", props: withInterval(10), elapsed: 4 * time.Second, want: withInterval(6)},
+		{name: "whole seconds", props: withInterval(10), elapsed: 9*time.Second + 999*time.Millisecond, want: withInterval(1)},
+		{name: "expired", props: withInterval(10), elapsed: 10 * time.Second},
+		{name: "clock skew", props: withInterval(10), elapsed: -time.Minute, want: withInterval(10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := Message{Publish: packet.Publish{Props: tt.props, Payload: []byte("x")}, Received: received}
+			now := received.Add(tt.elapsed)
+
+			if expired := msg.Expired(now); expired != (tt.want == nil) {
+				t.Errorf("Expired() = %v, want %v", expired, tt.want == nil)
+			}
+			got, ok := msg.Outbound(now)
+			if ok != (tt.want != nil) {
+				t.Fatalf("Outbound() ok = %v, want %v", ok, tt.want != nil)
+			}
+			if ok {
+				if diff := deep.Equal(got.Props, tt.want); diff != nil {
+					t.Error(diff)
+				}
+			}
+			if diff := deep.Equal(msg.Publish.Props, tt.props); diff != nil {
+				t.Errorf("Outbound() modified the message: %v", diff)
+			}
+		})
+	}
+}
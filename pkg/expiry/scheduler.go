@@ -0,0 +1,197 @@
+package expiry
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//Never is the expiry of sessions that don't expire.
+const Never time.Duration = math.MaxInt64
+
+//neverExpires is the session expiry interval of sessions that don't expire (3.1.2.11.2).
+const neverExpires = 1<<32 - 1
+
+//SessionExpiry returns the session expiry interval in seconds as a duration, Never for sessions that don't expire.
+func SessionExpiry(seconds uint32) time.Duration {
+	if seconds == neverExpires {
+		return Never
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+//Will is a will message together with its will delay interval (3.1.3.2.2).
+type Will struct {
+	Publish packet.Publish
+	Delay   time.Duration
+}
+
+//NewWill returns the will message of connect, nil if it has none (3.1.3.2).
+//The will delay interval is removed from the properties of the will message.
+//An error of type *topic.InvalidTopicError is returned if the will topic is invalid.
+func NewWill(connect packet.Connect) (*Will, error) {
+	payload := connect.Payload
+	if payload.WillTopic == "" {
+		return nil, nil
+	}
+	willTopic, err := topic.ParseTopic(payload.WillTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	props := payload.WillProps.Clone()
+	delete(props, packet.WillDelayInterval)
+	will := &Will{Publish: packet.Publish{
+		Qos:     payload.WillQoS,
+		Retain:  payload.WillRetain,
+		Topic:   willTopic,
+		Props:   props,
+		Payload: payload.WillPayload,
+	}}
+	if delay, ok := payload.WillProps.Int32(packet.WillDelayInterval); ok {
+		will.Delay = time.Duration(delay) * time.Second
+	}
+	return will, nil
+}
+
+//Scheduler ends sessions and publishes will messages after their clients disconnected.
+//It doesn't lock itself but is guarded by the locker passed to NewScheduler:
+//it has to be held when calling the methods and is held by the Scheduler while calling the callbacks.
+//This lets the callbacks use state guarded by the same locker without racing with a client reconnecting.
+type Scheduler struct {
+	clk      clock.Clock
+	mu       sync.Locker
+	onWill   func(clientID string, will packet.Publish)
+	onExpire func(clientID string)
+	pending  map[string]*pending
+}
+
+//pending is the state of a disconnected client.
+type pending struct {
+	will        *packet.Publish
+	willTimer   clock.Timer
+	expireTimer clock.Timer
+}
+
+//NewScheduler is the constructor of the Scheduler type.
+//OnWill is called to publish a will message, onExpire to end a session.
+func NewScheduler(clk clock.Clock, mu sync.Locker, onWill func(clientID string, will packet.Publish), onExpire func(clientID string)) *Scheduler {
+	return &Scheduler{
+		clk:      clk,
+		mu:       mu,
+		onWill:   onWill,
+		onExpire: onExpire,
+		pending:  make(map[string]*pending),
+	}
+}
+
+//Disconnected schedules the end of the session of clientID after expiry and the will, if any,
+//after its will delay interval or when the session ends, whichever happens first (3.1.3.2.2).
+//With an expiry of 0 the will is published and the session is ended before Disconnected returns.
+//Anything scheduled for clientID before is cancelled.
+func (s *Scheduler) Disconnected(clientID string, expiry time.Duration, will *Will) {
+	s.cancel(clientID)
+	p := &pending{}
+
+	if will != nil {
+		delay := will.Delay
+		if expiry < delay {
+			delay = expiry
+		}
+		if delay <= 0 {
+			s.onWill(clientID, will.Publish)
+		} else {
+			publish := will.Publish
+			p.will = &publish
+			p.willTimer = s.clk.AfterFunc(delay, func() {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+
+				if s.pending[clientID] == p && p.will != nil {
+					s.publishWill(clientID, p)
+				}
+			})
+		}
+	}
+
+	switch {
+	case expiry <= 0:
+		s.onExpire(clientID)
+		return
+	case expiry != Never:
+		p.expireTimer = s.clk.AfterFunc(expiry, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if s.pending[clientID] == p {
+				s.End(clientID)
+				s.onExpire(clientID)
+			}
+		})
+	}
+	if p.will != nil || p.expireTimer != nil {
+		s.pending[clientID] = p
+	}
+}
+
+//Connected cancels everything scheduled for clientID, because its client reconnected in time (3.1.3.2.2).
+//The pending will is discarded.
+func (s *Scheduler) Connected(clientID string) {
+	s.cancel(clientID)
+}
+
+//End publishes the pending will of clientID, e.g. because the session ends for another reason than its expiry,
+//and cancels everything scheduled for it.
+func (s *Scheduler) End(clientID string) {
+	p, ok := s.pending[clientID]
+	if !ok {
+		return
+	}
+	if p.will != nil {
+		s.publishWill(clientID, p)
+	}
+	s.cancel(clientID)
+}
+
+//Pending reports whether a will or the end of the session of clientID is scheduled.
+func (s *Scheduler) Pending(clientID string) bool {
+	_, ok := s.pending[clientID]
+	return ok
+}
+
+//Stop cancels everything scheduled without publishing any wills, e.g. when the server is closed.
+func (s *Scheduler) Stop() {
+	for clientID := range s.pending {
+		s.cancel(clientID)
+	}
+}
+
+func (s *Scheduler) publishWill(clientID string, p *pending) {
+	will := *p.will
+	p.will = nil
+	if p.willTimer != nil {
+		p.willTimer.Stop()
+	}
+	if p.expireTimer == nil {
+		delete(s.pending, clientID)
+	}
+	s.onWill(clientID, will)
+}
+
+func (s *Scheduler) cancel(clientID string) {
+	p, ok := s.pending[clientID]
+	if !ok {
+		return
+	}
+	if p.willTimer != nil {
+		p.willTimer.Stop()
+	}
+	if p.expireTimer != nil {
+		p.expireTimer.Stop()
+	}
+	delete(s.pending, clientID)
+}
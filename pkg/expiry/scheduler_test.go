@@ -0,0 +1,225 @@
+package expiry
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//recorder records the callbacks of a Scheduler as events like "will a" and "expire a".
+type recorder struct {
+	mu     sync.Mutex
+	clk    *clock.Fake
+	sched  *Scheduler
+	events []string
+}
+
+func newRecorder() *recorder {
+	r := &recorder{clk: clock.NewFake(time.Unix(0, 0))}
+	r.sched = NewScheduler(r.clk, &r.mu, func(clientID string, will packet.Publish) {
+		r.events = append(r.events, "will "+clientID+" "+string(will.Payload))
+	}, func(clientID string) {
+		r.events = append(r.events, "expire "+clientID)
+	})
+	return r
+}
+
+//do calls f with the mutex of the Scheduler held.
+func (r *recorder) do(f func(s *Scheduler)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f(r.sched)
+}
+
+//advance moves the clock forward and returns the events that happened meanwhile.
+func (r *recorder) advance(d time.Duration) []string {
+	r.clk.Advance(d)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func will(delay time.Duration) *Will {
+	return &Will{Publish: packet.Publish{Payload: []byte("gone")}, Delay: delay}
+}
+
+func TestScheduler(t *testing.T) {
+	tests := []struct {
+		name   string
+		expiry time.Duration
+		will   *Will
+		// then is called after the first step, e.g. to reconnect; its events are expected with the next step
+		then func(s *Scheduler)
+		// steps are the times to advance by and the events expected after each
+		steps []time.Duration
+		want  [][]string
+	}{
+		{
+			name:   "no expiry without will",
+			expiry: 0,
+			steps:  []time.Duration{0},
+			want:   [][]string{{"expire a"}},
+		},
+		{
+			name:   "no expiry",
+			expiry: 0,
+			will:   will(time.Minute),
+			steps:  []time.Duration{0},
+			want:   [][]string{{"will a gone", "expire a"}},
+		},
+		{
+			name:   "no will delay",
+			expiry: time.Minute,
+			will:   will(0),
+			steps:  []time.Duration{0, time.Minute},
+			want:   [][]string{{"will a gone"}, {"expire a"}},
+		},
+		{
+			name:   "will delay",
+			expiry: time.Minute,
+			will:   will(10 * time.Second),
+			steps:  []time.Duration{9 * time.Second, time.Second, 50 * time.Second},
+			want:   [][]string{nil, {"will a gone"}, {"expire a"}},
+		},
+		{
+			name:   "will delay longer than expiry",
+			expiry: 10 * time.Second,
+			will:   will(time.Minute),
+			steps:  []time.Duration{9 * time.Second, time.Second, time.Minute},
+			want:   [][]string{nil, {"will a gone", "expire a"}, nil},
+		},
+		{
+			name:   "never expires",
+			expiry: Never,
+			will:   will(time.Minute),
+			steps:  []time.Duration{time.Minute, 1000 * time.Hour},
+			want:   [][]string{{"will a gone"}, nil},
+		},
+		{
+			name:   "reconnected in time",
+			expiry: time.Minute,
+			will:   will(10 * time.Second),
+			then:   func(s *Scheduler) { s.Connected("a") },
+			steps:  []time.Duration{5 * time.Second, time.Hour},
+			want:   [][]string{nil, nil},
+		},
+		{
+			name:   "reconnected after will",
+			expiry: time.Minute,
+			will:   will(10 * time.Second),
+			then:   func(s *Scheduler) { s.Connected("a") },
+			steps:  []time.Duration{10 * time.Second, time.Hour},
+			want:   [][]string{{"will a gone"}, nil},
+		},
+		{
+			name:   "ended",
+			expiry: time.Minute,
+			will:   will(10 * time.Second),
+			then:   func(s *Scheduler) { s.End("a") },
+			steps:  []time.Duration{5 * time.Second, time.Hour},
+			want:   [][]string{nil, {"will a gone"}},
+		},
+		{
+			name:   "disconnected again",
+			expiry: time.Minute,
+			will:   will(10 * time.Second),
+			then:   func(s *Scheduler) { s.Disconnected("a", 2*time.Minute, nil) },
+			steps:  []time.Duration{5 * time.Second, time.Minute, time.Minute},
+			want:   [][]string{nil, nil, {"expire a"}},
+		},
+		{
+			name:   "stopped",
+			expiry: time.Minute,
+			will:   will(10 * time.Second),
+			then:   func(s *Scheduler) { s.Stop() },
+			steps:  []time.Duration{5 * time.Second, time.Hour},
+			want:   [][]string{nil, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRecorder()
+			var got [][]string
+			r.do(func(s *Scheduler) {
+				s.Disconnected("a", tt.expiry, tt.will)
+			})
+			for i, step := range tt.steps {
+				got = append(got, r.advance(step))
+				if i == 0 && tt.then != nil {
+					r.do(tt.then)
+				}
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Error(diff)
+			}
+			var pending bool
+			r.do(func(s *Scheduler) { pending = s.Pending("a") })
+			if pending {
+				t.Error("still pending after all steps")
+			}
+		})
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	if got := SessionExpiry(60); got != time.Minute {
+		t.Errorf("SessionExpiry(60) = %v, want %v", got, time.Minute)
+	}
+	if got := SessionExpiry(1<<32 - 1); got != Never {
+		t.Errorf("SessionExpiry(max) = %v, want Never", got)
+	}
+}
+
+func TestNewWill(t *testing.T) {
+	willProps := packet.NewProperties(
+		packet.NewProperty(packet.WillDelayInterval, packet.Int32PropPayload(30)),
+		packet.NewProperty(packet.ContentType, packet.StringPropPayload("text/plain")),
+	)
+	connect := packet.Connect{Payload: packet.ConnectPayload{
+		WillTopic:   "a/b",
+		WillPayload: []byte("gone"),
+		WillQoS:     packet.Qos1,
+		WillRetain:  true,
+		WillProps:   willProps,
+	}}
+
+	got, err := NewWill(connect)
+	if err != nil {
+		t.Fatalf("NewWill() error = %v", err)
+	}
+	want := &Will{
+		Publish: packet.Publish{
+			Qos:     packet.Qos1,
+			Retain:  true,
+			Topic:   topic.Topic{Levels: []string{"a", "b"}},
+			Props:   packet.NewProperties(packet.NewProperty(packet.ContentType, packet.StringPropPayload("text/plain"))),
+			Payload: []byte("gone"),
+		},
+		Delay: 30 * time.Second,
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+	if _, ok := connect.Payload.WillProps[packet.WillDelayInterval]; !ok {
+		t.Error("NewWill() modified the properties of connect")
+	}
+
+	if will, err := NewWill(packet.Connect{}); will != nil || err != nil {
+		t.Errorf("NewWill() without will = %v, %v, want nil, nil", will, err)
+	}
+	connect.Payload.WillTopic = "a/+"
+	var topicErr *topic.InvalidTopicError
+	if _, err := NewWill(connect); !errors.As(err, &topicErr) {
+		t.Errorf("NewWill() error = %v, want *topic.InvalidTopicError", err)
+	}
+}
@@ -0,0 +1,7 @@
+package keepalive
+
+/*
+Package keepalive supervises the keep alive of a network connection (3.1.2.10).
+Server closes idle client connections, Client sends pingreq control packets when idle
+and detects missing pingresp control packets.
+*/
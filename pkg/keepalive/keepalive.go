@@ -0,0 +1,161 @@
+package keepalive
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//Effective returns the keep alive that applies to a network connection.
+//The server keep alive of connack takes precedence over the keep alive of connect (3.2.2.3.14).
+//A keep alive of 0 disables the mechanism.
+func Effective(connect packet.Connect, connack packet.Connack) time.Duration {
+	if serverKeepAlive, ok := connack.Props.Int16(packet.ServerKeepAlive); ok {
+		return time.Duration(serverKeepAlive) * time.Second
+	}
+	return time.Duration(connect.KeepAlive) * time.Second
+}
+
+//Server closes a network connection on the server side
+//if no control packet is received for one and a half times the keep alive (3.1.2.10).
+//It is safe for concurrent use.
+type Server struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	timer   clock.Timer
+}
+
+//NewServer is the constructor of the Server type.
+//Expired is called with a *packet.DisconnectError with DisconnectKeepAliveTimeout once the connection timed out.
+//A keep alive of 0 disables the supervision.
+func NewServer(clk clock.Clock, keepAlive time.Duration, expired func(err error)) *Server {
+	s := &Server{timeout: keepAlive * 3 / 2}
+	if keepAlive == 0 {
+		return s
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timer = clk.AfterFunc(s.timeout, func() {
+		expired(&packet.DisconnectError{
+			Reason: packet.DisconnectKeepAliveTimeout,
+			Err:    fmt.Errorf("no control packet received for %v", s.timeout),
+		})
+	})
+	return s
+}
+
+//Received has to be called for every control packet received from the client.
+func (s *Server) Received() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil && s.timer.Stop() {
+		s.timer.Reset(s.timeout)
+	}
+}
+
+//Stop ends the supervision, e.g. once the network connection is closed.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+//Client sends a pingreq control packet on the client side if no control packet was sent for the keep alive (3.1.2.10).
+//If no pingresp control packet is received within the keep alive after that, the connection timed out (3.12.4).
+//It is safe for concurrent use.
+type Client struct {
+	mu        sync.Mutex
+	keepAlive time.Duration
+	idle      clock.Timer
+	response  clock.Timer
+	pending   bool
+	stopped   bool
+}
+
+//NewClient is the constructor of the Client type.
+//Ping is called when a pingreq control packet has to be sent.
+//Expired is called with a *packet.DisconnectError with DisconnectKeepAliveTimeout if the server didn't respond in time.
+//A keep alive of 0 disables the supervision.
+func NewClient(clk clock.Clock, keepAlive time.Duration, ping func(), expired func(err error)) *Client {
+	c := &Client{keepAlive: keepAlive}
+	if keepAlive == 0 {
+		return c
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.response = clk.AfterFunc(keepAlive, func() {
+		expired(&packet.DisconnectError{
+			Reason: packet.DisconnectKeepAliveTimeout,
+			Err:    fmt.Errorf("no pingresp received within %v", keepAlive),
+		})
+	})
+	c.response.Stop()
+
+	c.idle = clk.AfterFunc(keepAlive, func() {
+		c.mu.Lock()
+		if c.stopped || c.pending {
+			c.mu.Unlock()
+			return
+		}
+		c.pending = true
+		c.response.Reset(c.keepAlive)
+		c.idle.Reset(c.keepAlive)
+		c.mu.Unlock()
+
+		ping()
+	})
+	return c
+}
+
+//Sent has to be called for every control packet sent to the server.
+func (c *Client) Sent() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.idle != nil && !c.stopped {
+		c.idle.Stop()
+		c.idle.Reset(c.keepAlive)
+	}
+}
+
+//Received has to be called for every control packet received from the server.
+func (c *Client) Received(pkt packet.Packet) {
+	if _, ok := pkt.(*packet.Pingresp); !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.response != nil && c.pending {
+		c.pending = false
+		c.response.Stop()
+		if !c.stopped {
+			c.idle.Stop()
+			c.idle.Reset(c.keepAlive)
+		}
+	}
+}
+
+//Stop ends the supervision, e.g. once the network connection is closed.
+func (c *Client) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopped = true
+	if c.idle != nil {
+		c.idle.Stop()
+		c.response.Stop()
+	}
+}
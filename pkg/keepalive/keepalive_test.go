@@ -0,0 +1,139 @@
+package keepalive
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+func TestEffective(t *testing.T) {
+	tests := []struct {
+		name    string
+		connect packet.Connect
+		connack packet.Connack
+		want    time.Duration
+	}{
+		{
+			name:    "client keep alive",
+			connect: packet.Connect{KeepAlive: 60},
+			connack: packet.Connack{Props: packet.NewProperties()},
+			want:    time.Minute,
+		},
+		{
+			name:    "server keep alive",
+			connect: packet.Connect{KeepAlive: 60},
+			connack: packet.Connack{Props: packet.NewProperties(packet.NewProperty(packet.ServerKeepAlive, packet.Int16PropPayload(10)))},
+			want:    10 * time.Second,
+		},
+		{
+			name:    "server keep alive disables keep alive",
+			connect: packet.Connect{KeepAlive: 60},
+			connack: packet.Connack{Props: packet.NewProperties(packet.NewProperty(packet.ServerKeepAlive, packet.Int16PropPayload(0)))},
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Effective(tt.connect, tt.connack); got != tt.want {
+				t.Errorf("Effective() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func isKeepAliveTimeout(err error) bool {
+	var disconnectErr *packet.DisconnectError
+	return errors.As(err, &disconnectErr) && disconnectErr.Reason == packet.DisconnectKeepAliveTimeout
+}
+
+func TestServer(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	var expired error
+	s := NewServer(clk, 10*time.Second, func(err error) { expired = err })
+
+	clk.Advance(14 * time.Second)
+	s.Received()
+	clk.Advance(14 * time.Second)
+	if expired != nil {
+		t.Fatalf("expired after %v: %v", clk.Now(), expired)
+	}
+
+	clk.Advance(time.Second)
+	if !isKeepAliveTimeout(expired) {
+		t.Errorf("expired with %v, want keep alive timeout", expired)
+	}
+}
+
+func TestServerStop(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	var expired error
+	s := NewServer(clk, 10*time.Second, func(err error) { expired = err })
+
+	s.Stop()
+	clk.Advance(time.Minute)
+	s.Received()
+	clk.Advance(time.Minute)
+	if expired != nil {
+		t.Errorf("expired after Stop(): %v", expired)
+	}
+}
+
+func TestServerDisabled(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	s := NewServer(clk, 0, func(err error) { t.Errorf("expired: %v", err) })
+	clk.Advance(time.Hour)
+	s.Received()
+	s.Stop()
+}
+
+func TestClient(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	pings := 0
+	var expired error
+	c := NewClient(clk, 10*time.Second, func() { pings++ }, func(err error) { expired = err })
+
+	clk.Advance(9 * time.Second)
+	c.Sent()
+	clk.Advance(9 * time.Second)
+	if pings != 0 {
+		t.Fatalf("pinged %d times while not idle", pings)
+	}
+
+	clk.Advance(time.Second)
+	if pings != 1 {
+		t.Fatalf("pinged %d times after being idle, want 1", pings)
+	}
+	c.Sent()
+	c.Received(&packet.Pingresp{})
+
+	clk.Advance(10 * time.Second)
+	if pings != 2 {
+		t.Fatalf("pinged %d times, want 2", pings)
+	}
+	c.Received(&packet.Puback{})
+	clk.Advance(9 * time.Second)
+	if expired != nil {
+		t.Fatalf("expired early: %v", expired)
+	}
+
+	clk.Advance(time.Second)
+	if !isKeepAliveTimeout(expired) {
+		t.Errorf("expired with %v, want keep alive timeout", expired)
+	}
+	if pings != 2 {
+		t.Errorf("pinged %d times while awaiting pingresp, want 2", pings)
+	}
+}
+
+func TestClientStop(t *testing.T) {
+	clk := clock.NewFake(time.Unix(0, 0))
+	c := NewClient(clk, 10*time.Second, func() { t.Error("pinged after Stop()") }, func(err error) { t.Errorf("expired: %v", err) })
+
+	c.Stop()
+	c.Sent()
+	clk.Advance(time.Minute)
+}
@@ -0,0 +1,97 @@
+package packet
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/squ94wk/mqtt-common/internal/types"
+)
+
+//writeAck writes the control packets puback, pubrec, pubrel and pubcomp which share the same structure (3.4 - 3.7).
+//The reason code and properties are omitted if possible.
+func writeAck(writer io.Writer, firstByte byte, packetID uint16, reason byte, props Properties) (int64, error) {
+	var n int64
+	// Fixed header
+	n1, err := writer.Write([]byte{firstByte})
+	n += int64(n1)
+	if err != nil {
+		return n, fmt.Errorf("failed to write fixed header: %v", err)
+	}
+
+	var remainingLength = types.UInt16Size // packetID
+	if reason != 0 || len(props) > 0 {
+		remainingLength++
+	}
+	if len(props) > 0 {
+		remainingLength += props.size()
+	}
+	n2, err := types.WriteVarIntTo(writer, remainingLength)
+	n += n2
+	if err != nil {
+		return n, fmt.Errorf("failed to write packet length: %v", err)
+	}
+
+	// Variable header
+	n3, err := types.WriteUInt16To(writer, packetID)
+	n += n3
+	if err != nil {
+		return n, fmt.Errorf("failed to write packetID: %v", err)
+	}
+
+	if remainingLength == types.UInt16Size {
+		return n, nil
+	}
+
+	n4, err := writer.Write([]byte{reason})
+	n += int64(n4)
+	if err != nil {
+		return n, fmt.Errorf("failed to write reason code: %v", err)
+	}
+
+	if len(props) == 0 {
+		return n, nil
+	}
+
+	n5, err := props.WriteTo(writer)
+	n += n5
+	if err != nil {
+		return n, fmt.Errorf("failed to write properties: %v", err)
+	}
+
+	return n, nil
+}
+
+//readAck reads the variable header of the control packets puback, pubrec, pubrel and pubcomp.
+func readAck(reader io.Reader, remainingLength uint32) (uint16, byte, Properties, error) {
+	// Packet identifier
+	packetID, err := types.ReadUInt16(reader)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to read packet ID: %v", err)
+	}
+	if packetID == 0 {
+		return 0, 0, nil, fmt.Errorf("malformed packet: invalid packet ID: %d", packetID)
+	}
+
+	//default reason is inferred if length is 2
+	if remainingLength < 3 {
+		return packetID, 0, NewProperties(), nil
+	}
+
+	// Reason code
+	var buf [1]byte
+	if _, err := io.ReadFull(reader, buf[:]); err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to read reason code: %v", err)
+	}
+
+	if remainingLength < 4 {
+		return packetID, buf[0], NewProperties(), nil
+	}
+
+	// Properties
+	props, err := readProperties(reader)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to read properties: %v", err)
+	}
+
+	return packetID, buf[0], props, nil
+}
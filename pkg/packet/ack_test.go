@@ -0,0 +1,109 @@
+package packet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/internal/help"
+)
+
+var ackTests = []struct {
+	name string
+	pkt  Packet
+	bin  []byte
+}{
+	{
+		name: "puback without reason",
+		pkt:  &Puback{PacketID: 100, Reason: PubackSuccess, Props: NewProperties()},
+		bin:  []byte{byte(PUBACK) << 4, 2, 0, 100},
+	},
+	{
+		name: "puback with reason",
+		pkt:  &Puback{PacketID: 100, Reason: PubackNoMatchingSubscribers, Props: NewProperties()},
+		bin:  []byte{byte(PUBACK) << 4, 3, 0, 100, byte(PubackNoMatchingSubscribers)},
+	},
+	{
+		name: "puback with properties",
+		pkt: &Puback{
+			PacketID: 100,
+			Reason:   PubackSuccess,
+			Props:    NewProperties(Property{PropID: ReasonString, Payload: StringPropPayload("ok")}),
+		},
+		bin: []byte{byte(PUBACK) << 4, 9, 0, 100, byte(PubackSuccess), 5, byte(ReasonString), 0, 2, 'o', 'k'},
+	},
+	{
+		name: "pubrec with reason",
+		pkt:  &Pubrec{PacketID: 1000, Reason: PubrecNotAuthorized, Props: NewProperties()},
+		bin:  []byte{byte(PUBREC) << 4, 3, 3, 232, byte(PubrecNotAuthorized)},
+	},
+	{
+		name: "pubrel without reason",
+		pkt:  &Pubrel{PacketID: 1, Reason: PubrelSuccess, Props: NewProperties()},
+		bin:  []byte{byte(PUBREL)<<4 | 2, 2, 0, 1},
+	},
+	{
+		name: "pubrel with reason",
+		pkt:  &Pubrel{PacketID: 1, Reason: PubrelPacketIdentifierNotFound, Props: NewProperties()},
+		bin:  []byte{byte(PUBREL)<<4 | 2, 3, 0, 1, byte(PubrelPacketIdentifierNotFound)},
+	},
+	{
+		name: "pubcomp with properties",
+		pkt: &Pubcomp{
+			PacketID: 2,
+			Reason:   PubcompPacketIdentifierNotFound,
+			Props:    NewProperties(Property{PropID: UserProperty, Payload: KeyValuePropPayload{"k", "v"}}),
+		},
+		bin: []byte{byte(PUBCOMP) << 4, 11, 0, 2, byte(PubcompPacketIdentifierNotFound), 7, byte(UserProperty), 0, 1, 'k', 0, 1, 'v'},
+	},
+}
+
+func TestReadAck(t *testing.T) {
+	for _, tt := range ackTests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt, err := ReadPacket(bytes.NewReader(tt.bin))
+			if err != nil {
+				t.Errorf("Read() error = %v", err)
+				return
+			}
+			if diff := deep.Equal(tt.pkt, pkt); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestWriteAck(t *testing.T) {
+	for _, tt := range ackTests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &bytes.Buffer{}
+			if _, err := tt.pkt.WriteTo(writer); err != nil {
+				t.Errorf("pkt.WriteTo() error = %v", err)
+				return
+			}
+			if diff := help.Match(help.NewByteSegment(tt.bin), writer.Bytes()); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestReadAckInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		bin  []byte
+	}{
+		{name: "puback with invalid flags => err", bin: []byte{byte(PUBACK)<<4 | 2, 2, 0, 1}},
+		{name: "pubrel with invalid flags => err", bin: []byte{byte(PUBREL) << 4, 2, 0, 1}},
+		{name: "pubcomp with packet ID 0 => err", bin: []byte{byte(PUBCOMP) << 4, 2, 0, 0}},
+		{name: "pubrec too short => err", bin: []byte{byte(PUBREC) << 4, 1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if pkt, err := ReadPacket(bytes.NewReader(tt.bin)); err == nil {
+				t.Errorf("Read() = %v, want error", pkt)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package packet
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//InboundAliases holds the topic alias mapping of incoming publish control packets of one network connection (3.3.2.3.4).
+//It is safe for concurrent use.
+type InboundAliases struct {
+	mu     sync.Mutex
+	max    uint16
+	topics map[uint16]topic.Topic
+}
+
+//OutboundAliases assigns topic aliases to outgoing publish control packets of one network connection (3.3.2.3.4).
+//Once all aliases the receiver accepts are in use, the least recently used one is reassigned.
+//It is safe for concurrent use.
+type OutboundAliases struct {
+	mu      sync.Mutex
+	max     uint16
+	aliases map[string]*list.Element
+	lru     *list.List
+}
+
+type outboundAlias struct {
+	topic string
+	alias uint16
+}
+
+//NewInboundAliases is the constructor of the InboundAliases type.
+//Max is the topic alias maximum sent in the connect or connack control packet.
+func NewInboundAliases(max uint16) *InboundAliases {
+	return &InboundAliases{
+		max:    max,
+		topics: make(map[uint16]topic.Topic),
+	}
+}
+
+//Resolve sets the topic of publish if it carries a topic alias and records new mappings.
+//The topic alias property is removed from publish, as it is only valid within the network connection.
+//A *DisconnectError is returned with DisconnectTopicAliasInvalid if the alias is 0 or exceeds the maximum,
+//and with DisconnectProtocolError if an alias without topic name has no mapping.
+func (a *InboundAliases) Resolve(publish *Publish) error {
+	alias, ok := publish.Props.Int16(TopicAlias)
+	if !ok {
+		return nil
+	}
+	if alias == 0 || alias > a.max {
+		return &DisconnectError{
+			Reason: DisconnectTopicAliasInvalid,
+			Err:    fmt.Errorf("topic alias %d is not between 1 and %d", alias, a.max),
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(publish.Topic.Levels) == 0 {
+		t, ok := a.topics[alias]
+		if !ok {
+			return &DisconnectError{
+				Reason: DisconnectProtocolError,
+				Err:    fmt.Errorf("topic alias %d has no mapping", alias),
+			}
+		}
+		publish.Topic = t
+	} else {
+		a.topics[alias] = publish.Topic
+	}
+
+	delete(publish.Props, TopicAlias)
+	return nil
+}
+
+//NewOutboundAliases is the constructor of the OutboundAliases type.
+//Max is the topic alias maximum the receiver sent in the connect or connack control packet.
+//If max is 0 no aliases are assigned.
+func NewOutboundAliases(max uint16) *OutboundAliases {
+	return &OutboundAliases{
+		max:     max,
+		aliases: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+//Assign returns publish with a topic alias.
+//If the topic already has an alias, the topic name is omitted.
+//Otherwise a new alias is sent along with the topic name.
+//The properties of publish are copied and not modified.
+func (a *OutboundAliases) Assign(publish Publish) Publish {
+	if a.max == 0 || len(publish.Topic.Levels) == 0 {
+		return publish
+	}
+	name := publish.Topic.String()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	props := publish.Props.Clone()
+	delete(props, TopicAlias)
+	publish.Props = props
+
+	if elem, ok := a.aliases[name]; ok {
+		a.lru.MoveToFront(elem)
+		publish.Topic = topic.Topic{}
+		props.Add(NewProperty(TopicAlias, Int16PropPayload(elem.Value.(*outboundAlias).alias)))
+		return publish
+	}
+
+	var alias uint16
+	if a.lru.Len() < int(a.max) {
+		alias = uint16(a.lru.Len() + 1)
+	} else {
+		oldest := a.lru.Back()
+		a.lru.Remove(oldest)
+		delete(a.aliases, oldest.Value.(*outboundAlias).topic)
+		alias = oldest.Value.(*outboundAlias).alias
+	}
+	a.aliases[name] = a.lru.PushFront(&outboundAlias{topic: name, alias: alias})
+	props.Add(NewProperty(TopicAlias, Int16PropPayload(alias)))
+	return publish
+}
@@ -0,0 +1,111 @@
+package packet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+func aliasPublish(topicName string, alias uint16) Publish {
+	var t topic.Topic
+	if topicName != "" {
+		t = topic.Topic{Levels: []string{topicName}}
+	}
+	props := NewProperties()
+	if alias != 0 {
+		props.Add(NewProperty(TopicAlias, Int16PropPayload(alias)))
+	}
+	return Publish{Topic: t, Props: props}
+}
+
+func TestInboundAliasesResolve(t *testing.T) {
+	aliases := NewInboundAliases(2)
+
+	tests := []struct {
+		name       string
+		publish    Publish
+		want       Publish
+		wantReason DisconnectReason
+		wantErr    bool
+	}{
+		{name: "no alias", publish: aliasPublish("a", 0), want: aliasPublish("a", 0)},
+		{name: "set alias 1", publish: aliasPublish("a", 1), want: aliasPublish("a", 0)},
+		{name: "use alias 1", publish: aliasPublish("", 1), want: aliasPublish("a", 0)},
+		{name: "replace alias 1", publish: aliasPublish("b", 1), want: aliasPublish("b", 0)},
+		{name: "use replaced alias 1", publish: aliasPublish("", 1), want: aliasPublish("b", 0)},
+		{name: "unknown alias 2 => err", publish: aliasPublish("", 2), wantReason: DisconnectProtocolError, wantErr: true},
+		{name: "alias exceeds maximum => err", publish: aliasPublish("c", 3), wantReason: DisconnectTopicAliasInvalid, wantErr: true},
+		{
+			name: "alias 0 => err",
+			publish: Publish{
+				Topic: topic.Topic{Levels: []string{"a"}},
+				Props: NewProperties(NewProperty(TopicAlias, Int16PropPayload(0))),
+			},
+			wantReason: DisconnectTopicAliasInvalid,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publish := tt.publish
+			err := aliases.Resolve(&publish)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				var disconnectErr *DisconnectError
+				if !errors.As(err, &disconnectErr) || disconnectErr.Reason != tt.wantReason {
+					t.Errorf("Resolve() error = %v, want reason %d", err, tt.wantReason)
+				}
+				return
+			}
+			if diff := deep.Equal(publish, tt.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestOutboundAliasesAssign(t *testing.T) {
+	aliases := NewOutboundAliases(2)
+
+	tests := []struct {
+		topic string
+		want  Publish
+	}{
+		{topic: "a", want: aliasPublish("a", 1)},
+		{topic: "a", want: aliasPublish("", 1)},
+		{topic: "b", want: aliasPublish("b", 2)},
+		{topic: "a", want: aliasPublish("", 1)},
+		// 'b' is the least recently used
+		{topic: "c", want: aliasPublish("c", 2)},
+		{topic: "a", want: aliasPublish("", 1)},
+		{topic: "b", want: aliasPublish("b", 2)},
+		{topic: "c", want: aliasPublish("c", 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			publish := aliasPublish(tt.topic, 0)
+			got := aliases.Assign(publish)
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Error(diff)
+			}
+			if diff := deep.Equal(publish, aliasPublish(tt.topic, 0)); diff != nil {
+				t.Errorf("Assign() modified its input: %v", diff)
+			}
+		})
+	}
+}
+
+func TestOutboundAliasesDisabled(t *testing.T) {
+	aliases := NewOutboundAliases(0)
+	publish := aliasPublish("a", 0)
+	if diff := deep.Equal(aliases.Assign(publish), publish); diff != nil {
+		t.Error(diff)
+	}
+}
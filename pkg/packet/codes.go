@@ -9,6 +9,21 @@ type DisconnectReason byte
 //SubackReason is an alias for all defined reason codes a suback control packet can have.
 type SubackReason byte
 
+//UnsubackReason is an alias for all defined reason codes an unsuback control packet can have.
+type UnsubackReason byte
+
+//PubackReason is an alias for all defined reason codes a puback control packet can have.
+type PubackReason byte
+
+//PubrecReason is an alias for all defined reason codes a pubrec control packet can have.
+type PubrecReason byte
+
+//PubrelReason is an alias for all defined reason codes a pubrel control packet can have.
+type PubrelReason byte
+
+//PubcompReason is an alias for all defined reason codes a pubcomp control packet can have.
+type PubcompReason byte
+
 //Names for all defined connect reason codes a connack control packet can have.
 const (
 	ConnectSuccess                     ConnectReason = 0   // The Connection is accepted.
@@ -83,3 +98,52 @@ const (
 	SubackSubscriptionIdentifiersNotSupported SubackReason = 161 // The Server does not support Subscription Identifiers; the subscription is not accepted.
 	SubackWildcardSubscriptionsNotSupported   SubackReason = 162 // The Server does not support Wildcard Subscriptions; the subscription is not accepted.
 )
+
+//Names for all defined reason codes an unsuback control packet can have.
+const (
+	UnsubackSuccess                     UnsubackReason = 0   // The subscription is deleted.
+	UnsubackNoSubscriptionExisted       UnsubackReason = 17  // No matching Topic Filter is being used by the Client.
+	UnsubackUnspecifiedError            UnsubackReason = 128 // The unsubscribe could not be completed and the Server either does not wish to reveal the reason or none of the other Reason Codes apply.
+	UnsubackImplementationSpecificError UnsubackReason = 131 // The UNSUBSCRIBE is valid but the Server does not accept it.
+	UnsubackNotAuthorized               UnsubackReason = 135 // The Client is not authorized to unsubscribe.
+	UnsubackTopicFilterInvalid          UnsubackReason = 143 // The Topic Filter is correctly formed but is not allowed for this Client.
+	UnsubackPacketIdentifierInUse       UnsubackReason = 145 // The specified Packet Identifier is already in use.
+)
+
+//Names for all defined reason codes a puback control packet can have.
+const (
+	PubackSuccess                     PubackReason = 0   // The message is accepted. Publication of the QoS 1 message proceeds.
+	PubackNoMatchingSubscribers       PubackReason = 16  // The message is accepted but there are no subscribers.
+	PubackUnspecifiedError            PubackReason = 128 // The receiver does not accept the publish but either does not want to reveal the reason, or it does not match one of the other values.
+	PubackImplementationSpecificError PubackReason = 131 // The PUBLISH is valid but the receiver is not willing to accept it.
+	PubackNotAuthorized               PubackReason = 135 // The PUBLISH is not authorized.
+	PubackTopicNameInvalid            PubackReason = 144 // The Topic Name is not malformed, but is not accepted by this Client or Server.
+	PubackPacketIdentifierInUse       PubackReason = 145 // The Packet Identifier is already in use.
+	PubackQuotaExceeded               PubackReason = 151 // An implementation or administrative imposed limit has been exceeded.
+	PubackPayloadFormatInvalid        PubackReason = 153 // The payload format does not match the specified Payload Format Indicator.
+)
+
+//Names for all defined reason codes a pubrec control packet can have.
+const (
+	PubrecSuccess                     PubrecReason = 0   // The message is accepted. Publication of the QoS 2 message proceeds.
+	PubrecNoMatchingSubscribers       PubrecReason = 16  // The message is accepted but there are no subscribers.
+	PubrecUnspecifiedError            PubrecReason = 128 // The receiver does not accept the publish but either does not want to reveal the reason, or it does not match one of the other values.
+	PubrecImplementationSpecificError PubrecReason = 131 // The PUBLISH is valid but the receiver is not willing to accept it.
+	PubrecNotAuthorized               PubrecReason = 135 // The PUBLISH is not authorized.
+	PubrecTopicNameInvalid            PubrecReason = 144 // The Topic Name is not malformed, but is not accepted by this Client or Server.
+	PubrecPacketIdentifierInUse       PubrecReason = 145 // The Packet Identifier is already in use.
+	PubrecQuotaExceeded               PubrecReason = 151 // An implementation or administrative imposed limit has been exceeded.
+	PubrecPayloadFormatInvalid        PubrecReason = 153 // The payload format does not match the one specified in the Payload Format Indicator.
+)
+
+//Names for all defined reason codes a pubrel control packet can have.
+const (
+	PubrelSuccess                  PubrelReason = 0   // Message released.
+	PubrelPacketIdentifierNotFound PubrelReason = 146 // The Packet Identifier is not known.
+)
+
+//Names for all defined reason codes a pubcomp control packet can have.
+const (
+	PubcompSuccess                  PubcompReason = 0   // Packet Identifier released. Publication of QoS 2 message is complete.
+	PubcompPacketIdentifierNotFound PubcompReason = 146 // The Packet Identifier is not known.
+)
@@ -0,0 +1,124 @@
+package packet
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+//defaultCloseTimeout bounds the time Close waits to send the disconnect control packet.
+const defaultCloseTimeout = 5 * time.Second
+
+//Conn reads and writes control packets on a network connection using buffered I/O.
+//WritePacket and Close are safe for concurrent use, ReadPacket must only be called by a single reader.
+type Conn struct {
+	conn         net.Conn
+	reader       *bufio.Reader
+	closeTimeout time.Duration
+
+	mu     sync.Mutex
+	writer *bufio.Writer
+	// encoded holds the control packet being written until it is encoded completely
+	encoded bytes.Buffer
+	closed  bool
+}
+
+//NewConn is the constructor of the Conn type.
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{
+		conn:         conn,
+		reader:       bufio.NewReader(conn),
+		closeTimeout: defaultCloseTimeout,
+		writer:       bufio.NewWriter(conn),
+	}
+}
+
+//ReadPacket reads the next control packet.
+//Errors of the network connection before the first byte of a control packet, e.g. io.EOF or a timeout, are returned unchanged.
+func (c *Conn) ReadPacket() (Packet, error) {
+	if _, err := c.reader.Peek(1); err != nil {
+		return nil, err
+	}
+	return ReadPacket(c.reader)
+}
+
+//WritePacket writes pkt and flushes it to the network connection.
+func (c *Conn) WritePacket(pkt Packet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("failed to write packet: connection is closed")
+	}
+	return c.write(pkt)
+}
+
+//write encodes pkt and flushes it to the network connection; c.mu must be held.
+//A control packet that fails to encode isn't written at all, a partial one would corrupt the stream.
+func (c *Conn) write(pkt Packet) error {
+	c.encoded.Reset()
+	if _, err := pkt.WriteTo(&c.encoded); err != nil {
+		return err
+	}
+	if _, err := c.encoded.WriteTo(c.writer); err != nil {
+		return fmt.Errorf("failed to write packet: %v", err)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write packet: %v", err)
+	}
+	return nil
+}
+
+//Close sends a disconnect control packet with reason and closes the network connection.
+//The network connection is closed even if the disconnect control packet can't be sent.
+//Calling Close more than once has no effect.
+//Close returns after the close timeout at the latest, even if a write blocks because the peer doesn't read.
+func (c *Conn) Close(reason DisconnectReason) error {
+	// the deadline is set before taking the lock, it also interrupts a write that holds the lock while being blocked
+	deadlineErr := c.conn.SetWriteDeadline(time.Now().Add(c.closeTimeout))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	var writeErr error
+	if deadlineErr == nil {
+		writeErr = c.write(Disconnect{Reason: reason})
+	}
+	if err := c.conn.Close(); err != nil {
+		return err
+	}
+	return writeErr
+}
+
+//SetDeadline sets the read and write deadlines of the network connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+//SetReadDeadline sets the deadline for ReadPacket.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+//SetWriteDeadline sets the deadline for WritePacket.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+//LocalAddr returns the local address of the network connection.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+//RemoteAddr returns the remote address of the network connection.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
@@ -0,0 +1,143 @@
+package packet
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+func TestConn(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	client, server := NewConn(clientConn), NewConn(serverConn)
+
+	const writers = 10
+	var wg sync.WaitGroup
+	for i := 1; i <= writers; i++ {
+		wg.Add(1)
+		go func(id uint16) {
+			defer wg.Done()
+			if err := client.WritePacket(&Puback{PacketID: id, Props: NewProperties()}); err != nil {
+				t.Errorf("WritePacket() error = %v", err)
+			}
+		}(uint16(i))
+	}
+
+	seen := make(map[uint16]bool)
+	for i := 0; i < writers; i++ {
+		pkt, err := server.ReadPacket()
+		if err != nil {
+			t.Fatalf("ReadPacket() error = %v", err)
+		}
+		puback, ok := pkt.(*Puback)
+		if !ok {
+			t.Fatalf("ReadPacket() = %T, want *Puback", pkt)
+		}
+		seen[puback.PacketID] = true
+	}
+	wg.Wait()
+	if len(seen) != writers {
+		t.Errorf("read %d distinct packets, want %d", len(seen), writers)
+	}
+}
+
+func TestConnWriteInvalid(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	client, server := NewConn(clientConn), NewConn(serverConn)
+	defer client.conn.Close()
+
+	// the fixed header is encoded before the topic name turns out to be too long
+	invalid := &Publish{Topic: topic.Topic{Levels: []string{strings.Repeat("t", 1<<16)}}, Props: NewProperties()}
+	if err := client.WritePacket(invalid); err == nil {
+		t.Fatal("WritePacket() of invalid packet succeeded")
+	}
+
+	go client.WritePacket(&Pingreq{})
+	if err := server.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	pkt, err := server.ReadPacket()
+	if err != nil {
+		t.Fatalf("ReadPacket() error = %v", err)
+	}
+	if _, ok := pkt.(*Pingreq); !ok {
+		t.Errorf("ReadPacket() = %T, want *Pingreq", pkt)
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	client, server := NewConn(clientConn), NewConn(serverConn)
+
+	closed := make(chan error)
+	go func() {
+		closed <- client.Close(DisconnectServerShuttingDown)
+	}()
+
+	pkt, err := server.ReadPacket()
+	if err != nil {
+		t.Fatalf("ReadPacket() error = %v", err)
+	}
+	if diff := deep.Equal(pkt, &Disconnect{Reason: DisconnectServerShuttingDown, Props: NewProperties()}); diff != nil {
+		t.Error(diff)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	if _, err := server.ReadPacket(); err != io.EOF {
+		t.Errorf("ReadPacket() error = %v, want %v", err, io.EOF)
+	}
+	if err := client.WritePacket(&Pingreq{}); err == nil {
+		t.Error("WritePacket() after Close() succeeded")
+	}
+	if err := client.Close(DisconnectNormalDisconnection); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
+
+func TestConnCloseWhileWriteBlocked(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	client := NewConn(clientConn)
+	client.closeTimeout = 50 * time.Millisecond
+
+	// the peer never reads, so the write blocks while holding the write lock
+	written := make(chan error)
+	go func() {
+		written <- client.WritePacket(&Pingreq{})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan error)
+	go func() {
+		closed <- client.Close(DisconnectServerShuttingDown)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close() blocked behind a stalled write")
+	}
+	if err := <-written; err == nil {
+		t.Error("stalled WritePacket() succeeded")
+	}
+}
+
+func TestConnReadDeadline(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	server := NewConn(serverConn)
+
+	if err := server.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatalf("SetReadDeadline() error = %v", err)
+	}
+	_, err := server.ReadPacket()
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Errorf("ReadPacket() error = %v, want timeout", err)
+	}
+}
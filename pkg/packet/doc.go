@@ -4,4 +4,5 @@ package packet
 Package packet defines all mqtt control packets.
 Each control packet has a method WriteTo(io.Writer) (int64, error).
 To read control packets the package exports the ReadPacket() packet.Packet function.
+The Validator enforces the order of control packets on a network connection.
 */
@@ -0,0 +1,20 @@
+package packet
+
+import "fmt"
+
+//DisconnectError is returned for violations that require closing the network connection.
+//Reason is the reason code to send in the disconnect control packet.
+type DisconnectError struct {
+	Reason DisconnectReason
+	Err    error
+}
+
+//Error implements the error interface.
+func (e *DisconnectError) Error() string {
+	return fmt.Sprintf("%v (disconnect reason %d)", e.Err, e.Reason)
+}
+
+//Unwrap returns the underlying error.
+func (e *DisconnectError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,150 @@
+package packet
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+//Direction distinguishes the packet identifiers chosen by this side of a network connection from those chosen by the peer.
+//Both sides use the full range of packet identifiers independently (2.2.1).
+type Direction byte
+
+//The two directions packet identifiers are tracked for.
+const (
+	Outgoing Direction = iota
+	Incoming
+)
+
+//Errors returned by the IDAllocator.
+var (
+	ErrPacketIDsExhausted = errors.New("all packet identifiers are in use")
+	ErrPacketIDInUse      = errors.New("packet identifier is already in use")
+	ErrInvalidPacketID    = errors.New("packet identifier 0 is invalid")
+)
+
+const maxPacketIDs = 1<<16 - 1
+
+//IDAllocator hands out packet identifiers for outgoing control packets and tracks those in use in both directions.
+//Identifiers are assigned in ascending order, wrapping around and skipping those in use and 0.
+//It is safe for concurrent use.
+type IDAllocator struct {
+	mu       sync.Mutex
+	next     uint16
+	inUse    [2]idSet
+	count    [2]int
+	released chan struct{}
+}
+
+type idSet [(1 << 16) / 64]uint64
+
+func (s *idSet) has(id uint16) bool {
+	return s[id/64]&(1<<(id%64)) != 0
+}
+
+func (s *idSet) set(id uint16) {
+	s[id/64] |= 1 << (id % 64)
+}
+
+func (s *idSet) clear(id uint16) {
+	s[id/64] &^= 1 << (id % 64)
+}
+
+//NewIDAllocator is the constructor of the IDAllocator type.
+func NewIDAllocator() *IDAllocator {
+	return &IDAllocator{next: 1}
+}
+
+//Acquire returns an unused outgoing packet identifier and marks it as in use.
+//ErrPacketIDsExhausted is returned if all identifiers are in use.
+func (a *IDAllocator) Acquire() (uint16, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.acquire()
+}
+
+//AcquireWait is like Acquire, but blocks until an identifier is released if all are in use.
+//The error of ctx is returned if it is done before.
+func (a *IDAllocator) AcquireWait(ctx context.Context) (uint16, error) {
+	for {
+		a.mu.Lock()
+		id, err := a.acquire()
+		if err == nil {
+			a.mu.Unlock()
+			return id, nil
+		}
+		if a.released == nil {
+			a.released = make(chan struct{})
+		}
+		released := a.released
+		a.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func (a *IDAllocator) acquire() (uint16, error) {
+	if a.count[Outgoing] == maxPacketIDs {
+		return 0, ErrPacketIDsExhausted
+	}
+
+	id := a.next
+	for id == 0 || a.inUse[Outgoing].has(id) {
+		id++
+	}
+	a.inUse[Outgoing].set(id)
+	a.count[Outgoing]++
+	a.next = id + 1
+	return id, nil
+}
+
+//Claim marks a specific packet identifier as in use.
+//For the Incoming direction this tracks identifiers chosen by the peer, e.g. of a received subscribe control packet.
+//For the Outgoing direction this restores identifiers of packets still in flight, e.g. from a persisted session.
+//ErrPacketIDInUse is returned if the identifier is already in use.
+func (a *IDAllocator) Claim(dir Direction, id uint16) error {
+	if id == 0 {
+		return ErrInvalidPacketID
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.inUse[dir].has(id) {
+		return ErrPacketIDInUse
+	}
+	a.inUse[dir].set(id)
+	a.count[dir]++
+	return nil
+}
+
+//Release marks a packet identifier as no longer in use so it can be reused.
+//Releasing an identifier that is not in use has no effect.
+func (a *IDAllocator) Release(dir Direction, id uint16) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if id == 0 || !a.inUse[dir].has(id) {
+		return
+	}
+	a.inUse[dir].clear(id)
+	a.count[dir]--
+
+	if dir == Outgoing && a.released != nil {
+		close(a.released)
+		a.released = nil
+	}
+}
+
+//InUse reports whether a packet identifier is in use.
+func (a *IDAllocator) InUse(dir Direction, id uint16) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.inUse[dir].has(id)
+}
@@ -0,0 +1,117 @@
+package packet
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIDAllocatorAcquire(t *testing.T) {
+	a := NewIDAllocator()
+	for want := uint16(1); want <= 3; want++ {
+		if got, err := a.Acquire(); err != nil || got != want {
+			t.Errorf("Acquire() = %d, %v, want %d", got, err, want)
+		}
+	}
+
+	a.Release(Outgoing, 2)
+	if got, _ := a.Acquire(); got != 4 {
+		t.Errorf("Acquire() = %d, want 4: released identifiers are only reused after wrapping around", got)
+	}
+
+	if err := a.Claim(Outgoing, 5); err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+	if got, _ := a.Acquire(); got != 6 {
+		t.Errorf("Acquire() = %d, want 6: claimed identifiers are skipped", got)
+	}
+}
+
+func TestIDAllocatorWrapAround(t *testing.T) {
+	a := NewIDAllocator()
+	a.next = maxPacketIDs
+	if err := a.Claim(Outgoing, 1); err != nil {
+		t.Fatalf("Claim() error = %v", err)
+	}
+
+	if got, _ := a.Acquire(); got != maxPacketIDs {
+		t.Errorf("Acquire() = %d, want %d", got, maxPacketIDs)
+	}
+	if got, _ := a.Acquire(); got != 2 {
+		t.Errorf("Acquire() = %d, want 2: 0 and identifiers in use are skipped", got)
+	}
+}
+
+func TestIDAllocatorExhausted(t *testing.T) {
+	a := NewIDAllocator()
+	for i := 0; i < maxPacketIDs; i++ {
+		if _, err := a.Acquire(); err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+	}
+	if _, err := a.Acquire(); err != ErrPacketIDsExhausted {
+		t.Errorf("Acquire() error = %v, want %v", err, ErrPacketIDsExhausted)
+	}
+	if a.InUse(Incoming, 1) {
+		t.Error("InUse(Incoming) = true, directions must be independent")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := a.AcquireWait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("AcquireWait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	acquired := make(chan uint16)
+	go func() {
+		id, err := a.AcquireWait(context.Background())
+		if err != nil {
+			t.Errorf("AcquireWait() error = %v", err)
+		}
+		acquired <- id
+	}()
+
+	a.Release(Incoming, 42)
+	select {
+	case id := <-acquired:
+		t.Fatalf("AcquireWait() = %d before an outgoing identifier was released", id)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	a.Release(Outgoing, 42)
+	select {
+	case id := <-acquired:
+		if id != 42 {
+			t.Errorf("AcquireWait() = %d, want 42", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("AcquireWait() did not return after release")
+	}
+}
+
+func TestIDAllocatorClaim(t *testing.T) {
+	a := NewIDAllocator()
+	if err := a.Claim(Incoming, 1); err != nil {
+		t.Errorf("Claim() error = %v", err)
+	}
+	if err := a.Claim(Incoming, 1); err != ErrPacketIDInUse {
+		t.Errorf("Claim() error = %v, want %v", err, ErrPacketIDInUse)
+	}
+	if err := a.Claim(Incoming, 0); err != ErrInvalidPacketID {
+		t.Errorf("Claim() error = %v, want %v", err, ErrInvalidPacketID)
+	}
+	if got, _ := a.Acquire(); got != 1 {
+		t.Errorf("Acquire() = %d, want 1: incoming identifiers don't affect outgoing ones", got)
+	}
+
+	a.Release(Incoming, 1)
+	if a.InUse(Incoming, 1) {
+		t.Error("InUse() = true after Release()")
+	}
+	if !a.InUse(Outgoing, 1) {
+		t.Error("InUse(Outgoing) = false, directions must be independent")
+	}
+	if err := a.Claim(Incoming, 1); err != nil {
+		t.Errorf("Claim() after Release() error = %v", err)
+	}
+}
@@ -39,6 +39,32 @@ const (
 	AUTH
 )
 
+var pktTypeNames = [...]string{
+	CONNECT:     "connect",
+	CONNACK:     "connack",
+	PUBLISH:     "publish",
+	PUBACK:      "puback",
+	PUBREC:      "pubrec",
+	PUBREL:      "pubrel",
+	PUBCOMP:     "pubcomp",
+	SUBSCRIBE:   "subscribe",
+	SUBACK:      "suback",
+	UNSUBSCRIBE: "unsubscribe",
+	UNSUBACK:    "unsuback",
+	PINGREQ:     "pingreq",
+	PINGRESP:    "pingresp",
+	DISCONNECT:  "disconnect",
+	AUTH:        "auth",
+}
+
+//String returns the name of the control packet type.
+func (t pktType) String() string {
+	if t == 0 || int(t) >= len(pktTypeNames) {
+		return fmt.Sprintf("reserved(%d)", byte(t))
+	}
+	return pktTypeNames[t]
+}
+
 //Packet defines a control packet.
 type Packet interface {
 	WriteTo(io.Writer) (int64, error)
@@ -116,21 +142,82 @@ func readRestOfPacket(reader io.Reader, header header) (Packet, error) {
 		return &suback, nil
 
 	case PUBACK:
-		fallthrough
+		if header.flags != 0 {
+			return nil, fmt.Errorf("failed to read Puback packet: invalid fixed header: invalid flags '%d'", header.flags)
+		}
+		var puback Puback
+		err := readPuback(limitedReader, &puback, header.length)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Puback packet: %v", err)
+		}
+		return &puback, nil
+
 	case PUBREC:
-		fallthrough
+		if header.flags != 0 {
+			return nil, fmt.Errorf("failed to read Pubrec packet: invalid fixed header: invalid flags '%d'", header.flags)
+		}
+		var pubrec Pubrec
+		err := readPubrec(limitedReader, &pubrec, header.length)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Pubrec packet: %v", err)
+		}
+		return &pubrec, nil
+
 	case PUBREL:
-		fallthrough
+		if header.flags != 2 {
+			return nil, fmt.Errorf("failed to read Pubrel packet: invalid fixed header: invalid flags '%d'", header.flags)
+		}
+		var pubrel Pubrel
+		err := readPubrel(limitedReader, &pubrel, header.length)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Pubrel packet: %v", err)
+		}
+		return &pubrel, nil
+
 	case PUBCOMP:
-		fallthrough
+		if header.flags != 0 {
+			return nil, fmt.Errorf("failed to read Pubcomp packet: invalid fixed header: invalid flags '%d'", header.flags)
+		}
+		var pubcomp Pubcomp
+		err := readPubcomp(limitedReader, &pubcomp, header.length)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Pubcomp packet: %v", err)
+		}
+		return &pubcomp, nil
+
 	case UNSUBSCRIBE:
-		fallthrough
+		if header.flags != 2 {
+			return nil, fmt.Errorf("failed to read Unsubscribe packet: invalid fixed header: invalid flags '%d'", header.flags)
+		}
+		var unsubscribe Unsubscribe
+		err := readUnsubscribe(limitedReader, &unsubscribe)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Unsubscribe packet: %v", err)
+		}
+		return &unsubscribe, nil
+
 	case UNSUBACK:
-		fallthrough
+		if header.flags != 0 {
+			return nil, fmt.Errorf("failed to read Unsuback packet: invalid fixed header: invalid flags '%d'", header.flags)
+		}
+		var unsuback Unsuback
+		err := readUnsuback(limitedReader, &unsuback)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Unsuback packet: %v", err)
+		}
+		return &unsuback, nil
+
 	case PINGREQ:
-		fallthrough
+		if header.flags != 0 || header.length != 0 {
+			return nil, fmt.Errorf("failed to read Pingreq packet: invalid fixed header: invalid flags '%d' or length '%d'", header.flags, header.length)
+		}
+		return &Pingreq{}, nil
+
 	case PINGRESP:
-		panic("implement me")
+		if header.flags != 0 || header.length != 0 {
+			return nil, fmt.Errorf("failed to read Pingresp packet: invalid fixed header: invalid flags '%d' or length '%d'", header.flags, header.length)
+		}
+		return &Pingresp{}, nil
 
 	case DISCONNECT:
 		if header.flags != 0 {
@@ -144,7 +231,7 @@ func readRestOfPacket(reader io.Reader, header header) (Packet, error) {
 		return &disconnect, nil
 
 	case AUTH:
-		panic("implement me")
+		return nil, fmt.Errorf("failed to read Auth packet: protocol error: enhanced authentication is not supported")
 	}
 	return nil, fmt.Errorf("header with invalid packet type '%v'", header.pktType)
 }
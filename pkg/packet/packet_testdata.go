@@ -520,13 +520,12 @@ var (
 	publish1Bin = help.NewByteSequence(
 		help.InOrder,
 		help.NewByteSegment(
-			[]byte{byte(PUBLISH) << 4, 27},
+			[]byte{byte(PUBLISH) << 4, 25},
 			//variable header
 			//topic name
 			[]byte{0, 10},
 			[]byte("device/abc"),
-			//packetID
-			[]byte{0, 100},
+			//no packetID for QoS 0
 			//props length
 			[]byte{5},
 		),
@@ -564,11 +563,11 @@ var (
 		Qos:      Qos0,
 		Retain:   false,
 		Topic:    topic.Topic{Levels: []string{"device", "abc"}},
-		PacketID: 100,
-		Props:    NewProperties(
+		PacketID: 0,
+		Props: NewProperties(
 			Property{PropID: MessageExpiryInterval, Payload: Int32PropPayload(50)},
 		),
-		Payload:  []byte("payload"),
+		Payload: []byte("payload"),
 	}
 
 	publish2 = Publish{
@@ -577,10 +576,10 @@ var (
 		Retain:   true,
 		Topic:    topic.Topic{Levels: []string{"device", "abc", "temp"}},
 		PacketID: 100,
-		Props:    NewProperties(
+		Props: NewProperties(
 			Property{PropID: MessageExpiryInterval, Payload: Int32PropPayload(50)},
 			Property{PropID: PayloadFormatIndicator, Payload: BytePropPayload(1)},
 		),
-		Payload:  []byte("payload"),
+		Payload: []byte("payload"),
 	}
 )
@@ -0,0 +1,32 @@
+package packet
+
+import (
+	"fmt"
+	"io"
+)
+
+//Pingreq defines the pingreq control packet.
+type Pingreq struct{}
+
+//Pingresp defines the pingresp control packet.
+type Pingresp struct{}
+
+//WriteTo writes the pingreq control packet to writer according to the mqtt protocol.
+func (p Pingreq) WriteTo(writer io.Writer) (int64, error) {
+	// 3.12.1 Fixed header
+	n, err := writer.Write([]byte{byte(PINGREQ) << 4, 0})
+	if err != nil {
+		return int64(n), fmt.Errorf("failed to write pingreq packet: failed to write fixed header: %v", err)
+	}
+	return int64(n), nil
+}
+
+//WriteTo writes the pingresp control packet to writer according to the mqtt protocol.
+func (p Pingresp) WriteTo(writer io.Writer) (int64, error) {
+	// 3.13.1 Fixed header
+	n, err := writer.Write([]byte{byte(PINGRESP) << 4, 0})
+	if err != nil {
+		return int64(n), fmt.Errorf("failed to write pingresp packet: failed to write fixed header: %v", err)
+	}
+	return int64(n), nil
+}
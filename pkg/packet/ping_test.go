@@ -0,0 +1,57 @@
+package packet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name string
+		pkt  Packet
+		bin  []byte
+	}{
+		{name: "pingreq", pkt: &Pingreq{}, bin: []byte{byte(PINGREQ) << 4, 0}},
+		{name: "pingresp", pkt: &Pingresp{}, bin: []byte{byte(PINGRESP) << 4, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &bytes.Buffer{}
+			if _, err := tt.pkt.WriteTo(writer); err != nil {
+				t.Fatalf("pkt.WriteTo() error = %v", err)
+			}
+			if !bytes.Equal(writer.Bytes(), tt.bin) {
+				t.Errorf("pkt.WriteTo() = %v, want %v", writer.Bytes(), tt.bin)
+			}
+
+			pkt, err := ReadPacket(bytes.NewReader(tt.bin))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if diff := deep.Equal(pkt, tt.pkt); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestReadPingInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		bin  []byte
+	}{
+		{name: "pingreq with flags => err", bin: []byte{byte(PINGREQ)<<4 | 1, 0}},
+		{name: "pingresp with length => err", bin: []byte{byte(PINGRESP) << 4, 1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if pkt, err := ReadPacket(bytes.NewReader(tt.bin)); err == nil {
+				t.Errorf("Read() = %v, want error", pkt)
+			}
+		})
+	}
+}
@@ -110,6 +110,81 @@ func (p Properties) Add(prop Property) {
 	}
 }
 
+//Clone returns a copy of p that can be modified without affecting p.
+func (p Properties) Clone() Properties {
+	clone := make(map[uint32][]Property, len(p))
+	for propID, propsForID := range p {
+		clone[propID] = append([]Property(nil), propsForID...)
+	}
+	return clone
+}
+
+//Int16 returns the value of the first two byte integer property with identifier propID.
+//It reports false if p contains no such property.
+func (p Properties) Int16(propID uint32) (uint16, bool) {
+	for _, prop := range p[propID] {
+		if payload, ok := prop.Payload.(Int16PropPayload); ok {
+			return uint16(payload), true
+		}
+	}
+	return 0, false
+}
+
+//Int32 returns the value of the first four byte integer property with identifier propID.
+//It reports false if p contains no such property.
+func (p Properties) Int32(propID uint32) (uint32, bool) {
+	for _, prop := range p[propID] {
+		if payload, ok := prop.Payload.(Int32PropPayload); ok {
+			return uint32(payload), true
+		}
+	}
+	return 0, false
+}
+
+//VarInt returns the values of all variable byte integer properties with identifier propID.
+func (p Properties) VarInt(propID uint32) []uint32 {
+	var values []uint32
+	for _, prop := range p[propID] {
+		if payload, ok := prop.Payload.(VarIntPropPayload); ok {
+			values = append(values, uint32(payload))
+		}
+	}
+	return values
+}
+
+//StringProp returns the value of the first UTF-8 encoded string property with identifier propID.
+//It reports false if p contains no such property.
+func (p Properties) StringProp(propID uint32) (string, bool) {
+	for _, prop := range p[propID] {
+		if payload, ok := prop.Payload.(StringPropPayload); ok {
+			return string(payload), true
+		}
+	}
+	return "", false
+}
+
+//Binary returns the value of the first binary data property with identifier propID.
+//It reports false if p contains no such property.
+func (p Properties) Binary(propID uint32) ([]byte, bool) {
+	for _, prop := range p[propID] {
+		if payload, ok := prop.Payload.(BinaryPropPayload); ok {
+			return []byte(payload), true
+		}
+	}
+	return nil, false
+}
+
+//Byte returns the value of the first byte property with identifier propID.
+//It reports false if p contains no such property.
+func (p Properties) Byte(propID uint32) (byte, bool) {
+	for _, prop := range p[propID] {
+		if payload, ok := prop.Payload.(BytePropPayload); ok {
+			return byte(payload), true
+		}
+	}
+	return 0, false
+}
+
 //Reset removes all properties from p.
 func (p Properties) Reset() {
 	for propID := range p {
@@ -146,7 +221,7 @@ func (p VarIntPropPayload) size() uint32 {
 }
 
 func (p BinaryPropPayload) size() uint32 {
-	return uint32(len(p) + len(p))
+	return uint32(2 + len(p))
 }
 
 func (p Properties) size() uint32 {
@@ -52,3 +52,86 @@ func TestReadProperties(t *testing.T) {
 }
 
 //TODO: TestWritePropsTo
+
+func TestPropertiesSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		props Properties
+	}{
+		{
+			name:  "empty",
+			props: NewProperties(),
+		},
+		{
+			name: "binary",
+			props: NewProperties(
+				NewProperty(CorrelationData, BinaryPropPayload("request-1")),
+			),
+		},
+		{
+			name: "mixed",
+			props: NewProperties(
+				NewProperty(ResponseTopic, StringPropPayload("responses/a")),
+				NewProperty(CorrelationData, BinaryPropPayload{0, 1, 2}),
+				NewProperty(PayloadFormatIndicator, BytePropPayload(1)),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := tt.props.WriteTo(&buf); err != nil {
+				t.Fatalf("WriteTo() error = %v", err)
+			}
+			if got := tt.props.size(); got != uint32(buf.Len()) {
+				t.Errorf("size() = %d, want %d", got, buf.Len())
+			}
+
+			read, err := readProperties(&buf)
+			if err != nil {
+				t.Fatalf("readProperties() error = %v", err)
+			}
+			if diff := deep.Equal(read, tt.props); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestPropertiesAccessors(t *testing.T) {
+	props := NewProperties(
+		NewProperty(ReceiveMaximum, Int16PropPayload(10)),
+		NewProperty(SessionExpiryInterval, Int32PropPayload(3600)),
+		NewProperty(SubscriptionIdentifier, VarIntPropPayload(1)),
+		NewProperty(SubscriptionIdentifier, VarIntPropPayload(300)),
+	)
+
+	if got, ok := props.Int16(ReceiveMaximum); !ok || got != 10 {
+		t.Errorf("Int16() = %d, %v, want 10, true", got, ok)
+	}
+	if got, ok := props.Int32(SessionExpiryInterval); !ok || got != 3600 {
+		t.Errorf("Int32() = %d, %v, want 3600, true", got, ok)
+	}
+	if _, ok := props.Int32(MessageExpiryInterval); ok {
+		t.Error("Int32() of absent property reported true")
+	}
+	if diff := deep.Equal(props.VarInt(SubscriptionIdentifier), []uint32{1, 300}); diff != nil {
+		t.Error(diff)
+	}
+
+	props = NewProperties(
+		NewProperty(ResponseTopic, StringPropPayload("responses/a")),
+		NewProperty(CorrelationData, BinaryPropPayload{1, 2}),
+		NewProperty(RequestResponseInformation, BytePropPayload(1)),
+	)
+	if got, ok := props.StringProp(ResponseTopic); !ok || got != "responses/a" {
+		t.Errorf("StringProp() = %q, %v, want %q, true", got, ok, "responses/a")
+	}
+	if got, ok := props.Binary(CorrelationData); !ok || !bytes.Equal(got, []byte{1, 2}) {
+		t.Errorf("Binary() = %v, %v, want [1 2], true", got, ok)
+	}
+	if got, ok := props.Byte(RequestResponseInformation); !ok || got != 1 {
+		t.Errorf("Byte() = %d, %v, want 1, true", got, ok)
+	}
+}
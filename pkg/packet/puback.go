@@ -0,0 +1,36 @@
+package packet
+
+import (
+	"fmt"
+	"io"
+)
+
+//Puback defines the puback control packet.
+type Puback struct {
+	PacketID uint16
+	Reason   PubackReason
+	Props    Properties
+}
+
+//WriteTo writes the puback control packet to writer according to the mqtt protocol.
+func (p Puback) WriteTo(writer io.Writer) (int64, error) {
+	// 3.4.1 Fixed header
+	n, err := writeAck(writer, byte(PUBACK)<<4, p.PacketID, byte(p.Reason), p.Props)
+	if err != nil {
+		return n, fmt.Errorf("failed to write puback packet: %v", err)
+	}
+	return n, nil
+}
+
+func readPuback(reader io.Reader, puback *Puback, remainingLength uint32) error {
+	// 3.4.2 Variable header
+	packetID, reason, props, err := readAck(reader, remainingLength)
+	if err != nil {
+		return fmt.Errorf("failed to read puback packet: %v", err)
+	}
+
+	puback.PacketID = packetID
+	puback.Reason = PubackReason(reason)
+	puback.Props = props
+	return nil
+}
@@ -0,0 +1,36 @@
+package packet
+
+import (
+	"fmt"
+	"io"
+)
+
+//Pubcomp defines the pubcomp control packet.
+type Pubcomp struct {
+	PacketID uint16
+	Reason   PubcompReason
+	Props    Properties
+}
+
+//WriteTo writes the pubcomp control packet to writer according to the mqtt protocol.
+func (p Pubcomp) WriteTo(writer io.Writer) (int64, error) {
+	// 3.7.1 Fixed header
+	n, err := writeAck(writer, byte(PUBCOMP)<<4, p.PacketID, byte(p.Reason), p.Props)
+	if err != nil {
+		return n, fmt.Errorf("failed to write pubcomp packet: %v", err)
+	}
+	return n, nil
+}
+
+func readPubcomp(reader io.Reader, pubcomp *Pubcomp, remainingLength uint32) error {
+	// 3.7.2 Variable header
+	packetID, reason, props, err := readAck(reader, remainingLength)
+	if err != nil {
+		return fmt.Errorf("failed to read pubcomp packet: %v", err)
+	}
+
+	pubcomp.PacketID = packetID
+	pubcomp.Reason = PubcompReason(reason)
+	pubcomp.Props = props
+	return nil
+}
@@ -61,7 +61,9 @@ func writeFixedPublishHeader(p Publish, writer io.Writer) (int64, error) {
 
 	// Remaining length
 	var remainingLength = types.StringSize(p.Topic.String())
-	remainingLength += types.UInt16Size
+	if p.Qos > Qos0 {
+		remainingLength += types.UInt16Size
+	}
 	remainingLength += p.Props.size()
 	remainingLength += uint32(len(p.Payload))
 	n2, err := types.WriteVarIntTo(writer, remainingLength)
@@ -82,10 +84,13 @@ func writeVariablePublishHeader(p Publish, writer io.Writer) (int64, error) {
 		return n, fmt.Errorf("failed to write topic name: %v", err)
 	}
 	// 3.3.2.2 Packet ID
-	n2, err := types.WriteUInt16To(writer, p.PacketID)
-	n += n2
-	if err != nil {
-		return n, fmt.Errorf("failed to write packet ID: %v", err)
+	// only present in QoS 1 and 2 publish packets
+	if p.Qos > Qos0 {
+		n2, err := types.WriteUInt16To(writer, p.PacketID)
+		n += n2
+		if err != nil {
+			return n, fmt.Errorf("failed to write packet ID: %v", err)
+		}
 	}
 
 	// 3.3.2.3 Properties
@@ -114,21 +119,19 @@ func readPublish(reader *io.LimitedReader, publish *Publish, headerFirstByte byt
 	if err != nil {
 		return fmt.Errorf("failed to read Publish packet: failed to read topic name: %v", err)
 	}
-	parsedTopic, err := topic.ParseTopic(topicName)
-	if err != nil {
-		return fmt.Errorf("malformed packet: invalid topic name: %s", topicName)
-	}
-	publish.Topic = parsedTopic
 
 	// 3.3.2.2 Packet ID
-	packetID, err := types.ReadUInt16(reader)
-	if err != nil {
-		return fmt.Errorf("failed to read Publish packet: failed to read packet ID: %v", err)
-	}
-	if packetID == 0 {
-		return fmt.Errorf("malformed packet: invalid packet ID: %d", packetID)
+	// only present in QoS 1 and 2 publish packets
+	if qos > Qos0 {
+		packetID, err := types.ReadUInt16(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read Publish packet: failed to read packet ID: %v", err)
+		}
+		if packetID == 0 {
+			return fmt.Errorf("malformed packet: invalid packet ID: %d", packetID)
+		}
+		publish.PacketID = packetID
 	}
-	publish.PacketID = packetID
 
 	// 3.3.2.3 Properties
 	props, err := readProperties(reader)
@@ -137,6 +140,15 @@ func readPublish(reader *io.LimitedReader, publish *Publish, headerFirstByte byt
 	}
 	publish.Props = props
 
+	// the topic name may only be empty if a topic alias is used (3.3.2.3.4)
+	if _, ok := props[TopicAlias]; !ok || topicName != "" {
+		parsedTopic, err := topic.ParseTopic(topicName)
+		if err != nil {
+			return fmt.Errorf("failed to read Publish packet: invalid topic name: %v", err)
+		}
+		publish.Topic = parsedTopic
+	}
+
 	// 3.3.3 Payload
 	payload := make([]byte, reader.N)
 	_, err = io.ReadFull(reader, payload)
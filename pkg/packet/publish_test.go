@@ -33,6 +33,45 @@ func TestReadPublish(t *testing.T) {
 				reader: bytes.NewReader(publish2Bin.Bytes())},
 			want: &publish2,
 		},
+
+		{
+			name: "topic alias without topic name",
+			args: args{
+				reader: bytes.NewReader(help.Concat(
+					[]byte{byte(PUBLISH)<<4 | 1<<1, 8},
+					[]byte{0, 0},
+					[]byte{0, 1},
+					[]byte{3, byte(TopicAlias), 0, 1},
+				))},
+			want: &Publish{
+				Qos:      Qos1,
+				PacketID: 1,
+				Props:    NewProperties(Property{PropID: TopicAlias, Payload: Int16PropPayload(1)}),
+				Payload:  []byte{},
+			},
+		},
+
+		{
+			name: "empty topic name => err",
+			args: args{
+				reader: bytes.NewReader(help.Concat(
+					[]byte{byte(PUBLISH) << 4, 3},
+					[]byte{0, 0},
+					[]byte{0},
+				))},
+			wantErr: true,
+		},
+
+		{
+			name: "wildcard in topic name => err",
+			args: args{
+				reader: bytes.NewReader(help.Concat(
+					[]byte{byte(PUBLISH) << 4, 6},
+					[]byte{0, 3, 'a', '/', '+'},
+					[]byte{0},
+				))},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
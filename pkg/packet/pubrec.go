@@ -0,0 +1,36 @@
+package packet
+
+import (
+	"fmt"
+	"io"
+)
+
+//Pubrec defines the pubrec control packet.
+type Pubrec struct {
+	PacketID uint16
+	Reason   PubrecReason
+	Props    Properties
+}
+
+//WriteTo writes the pubrec control packet to writer according to the mqtt protocol.
+func (p Pubrec) WriteTo(writer io.Writer) (int64, error) {
+	// 3.5.1 Fixed header
+	n, err := writeAck(writer, byte(PUBREC)<<4, p.PacketID, byte(p.Reason), p.Props)
+	if err != nil {
+		return n, fmt.Errorf("failed to write pubrec packet: %v", err)
+	}
+	return n, nil
+}
+
+func readPubrec(reader io.Reader, pubrec *Pubrec, remainingLength uint32) error {
+	// 3.5.2 Variable header
+	packetID, reason, props, err := readAck(reader, remainingLength)
+	if err != nil {
+		return fmt.Errorf("failed to read pubrec packet: %v", err)
+	}
+
+	pubrec.PacketID = packetID
+	pubrec.Reason = PubrecReason(reason)
+	pubrec.Props = props
+	return nil
+}
@@ -0,0 +1,36 @@
+package packet
+
+import (
+	"fmt"
+	"io"
+)
+
+//Pubrel defines the pubrel control packet.
+type Pubrel struct {
+	PacketID uint16
+	Reason   PubrelReason
+	Props    Properties
+}
+
+//WriteTo writes the pubrel control packet to writer according to the mqtt protocol.
+func (p Pubrel) WriteTo(writer io.Writer) (int64, error) {
+	// 3.6.1 Fixed header
+	n, err := writeAck(writer, byte(PUBREL)<<4|2, p.PacketID, byte(p.Reason), p.Props)
+	if err != nil {
+		return n, fmt.Errorf("failed to write pubrel packet: %v", err)
+	}
+	return n, nil
+}
+
+func readPubrel(reader io.Reader, pubrel *Pubrel, remainingLength uint32) error {
+	// 3.6.2 Variable header
+	packetID, reason, props, err := readAck(reader, remainingLength)
+	if err != nil {
+		return fmt.Errorf("failed to read pubrel packet: %v", err)
+	}
+
+	pubrel.PacketID = packetID
+	pubrel.Reason = PubrelReason(reason)
+	pubrel.Props = props
+	return nil
+}
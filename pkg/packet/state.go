@@ -0,0 +1,281 @@
+package packet
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+//Role is the side of a network connection.
+type Role byte
+
+//The two roles of a network connection.
+const (
+	RoleClient Role = iota
+	RoleServer
+)
+
+//String returns the name of the role.
+func (r Role) String() string {
+	if r == RoleServer {
+		return "server"
+	}
+	return "client"
+}
+
+func (r Role) peer() Role {
+	if r == RoleServer {
+		return RoleClient
+	}
+	return RoleServer
+}
+
+//Validator enforces the order of control packets on a network connection from the perspective of one role.
+//It rejects control packets that must not be sent in the direction they are sent in,
+//control packets out of order, e.g. a second connect or a publish before connect,
+//and control packets with missing packet identifiers or filters.
+//Violations are reported as *DisconnectError.
+//It is safe for concurrent use.
+type Validator struct {
+	mu      sync.Mutex
+	role    Role
+	connect bool
+	connack bool
+	closed  bool
+}
+
+//NewValidator is the constructor of the Validator type.
+func NewValidator(role Role) *Validator {
+	return &Validator{role: role}
+}
+
+//Read reads a control packet from reader using ReadPacket and checks that it may be received.
+func (v *Validator) Read(reader io.Reader) (Packet, error) {
+	pkt, err := ReadPacket(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.Receive(pkt); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
+
+//Write checks that pkt may be sent and writes it to writer.
+//Nothing is written if pkt must not be sent.
+func (v *Validator) Write(writer io.Writer, pkt Packet) (int64, error) {
+	if err := v.Send(pkt); err != nil {
+		return 0, err
+	}
+	return pkt.WriteTo(writer)
+}
+
+//Receive checks that pkt may be received from the peer and records it.
+func (v *Validator) Receive(pkt Packet) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.transition(pkt, v.role.peer())
+}
+
+//Send checks that pkt may be sent to the peer and records it.
+func (v *Validator) Send(pkt Packet) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.transition(pkt, v.role)
+}
+
+func (v *Validator) transition(pkt Packet, sender Role) error {
+	t, ok := typeOf(pkt)
+	if !ok {
+		return protocolError("unknown control packet %T", pkt)
+	}
+	if v.closed {
+		return protocolError("%v after the network connection was closed", t)
+	}
+	if !sentBy(t, sender) {
+		return protocolError("%v must not be sent by the %v", t, sender)
+	}
+
+	switch t {
+	case CONNECT:
+		// 3.1.0-2 only a single connect per network connection
+		if v.connect {
+			return protocolError("second connect")
+		}
+		v.connect = true
+		return nil
+
+	case CONNACK:
+		if !v.connect {
+			return protocolError("connack before connect")
+		}
+		if v.connack {
+			return protocolError("second connack")
+		}
+		v.connack = true
+		// 3.2.2.2 the server closes the network connection after a connack with a failure
+		if connackReason(pkt) >= 0x80 {
+			v.closed = true
+		}
+		return nil
+	}
+
+	// 3.1.0-1 the first control packet from the client is the connect
+	if !v.connect {
+		return protocolError("%v before connect", t)
+	}
+	// 3.2.0-2 the first control packet from the server is the connack
+	if sender == RoleServer && !v.connack {
+		return protocolError("%v before connack", t)
+	}
+
+	if t == DISCONNECT {
+		v.closed = true
+		return nil
+	}
+	return validate(pkt)
+}
+
+//validate checks the rules for a single control packet the decoder doesn't check.
+func validate(pkt Packet) error {
+	switch p := pkt.(type) {
+	case Publish:
+		return validatePublish(p)
+	case *Publish:
+		return validatePublish(*p)
+	case Subscribe:
+		return validateSubscribe(p)
+	case *Subscribe:
+		return validateSubscribe(*p)
+	case Unsubscribe:
+		return validateUnsubscribe(p)
+	case *Unsubscribe:
+		return validateUnsubscribe(*p)
+	case Unsuback:
+		return validatePacketID(UNSUBACK, p.PacketID)
+	case *Unsuback:
+		return validatePacketID(UNSUBACK, p.PacketID)
+	case Suback:
+		return validatePacketID(SUBACK, p.PacketID)
+	case *Suback:
+		return validatePacketID(SUBACK, p.PacketID)
+	case Puback:
+		return validatePacketID(PUBACK, p.PacketID)
+	case *Puback:
+		return validatePacketID(PUBACK, p.PacketID)
+	case Pubrec:
+		return validatePacketID(PUBREC, p.PacketID)
+	case *Pubrec:
+		return validatePacketID(PUBREC, p.PacketID)
+	case Pubrel:
+		return validatePacketID(PUBREL, p.PacketID)
+	case *Pubrel:
+		return validatePacketID(PUBREL, p.PacketID)
+	case Pubcomp:
+		return validatePacketID(PUBCOMP, p.PacketID)
+	case *Pubcomp:
+		return validatePacketID(PUBCOMP, p.PacketID)
+	}
+	return nil
+}
+
+func validatePublish(publish Publish) error {
+	if publish.Qos > Qos2 {
+		return &DisconnectError{
+			Reason: DisconnectMalformedPacket,
+			Err:    fmt.Errorf("publish with invalid QoS %d", publish.Qos),
+		}
+	}
+	if publish.Qos == Qos0 {
+		return nil
+	}
+	return validatePacketID(PUBLISH, publish.PacketID)
+}
+
+func validateSubscribe(subscribe Subscribe) error {
+	// 3.8.3-2 at least one filter
+	if len(subscribe.Filters) == 0 {
+		return protocolError("subscribe without filters")
+	}
+	return validatePacketID(SUBSCRIBE, subscribe.PacketID)
+}
+
+func validateUnsubscribe(unsubscribe Unsubscribe) error {
+	// 3.10.3-2 at least one filter
+	if len(unsubscribe.Filters) == 0 {
+		return protocolError("unsubscribe without filters")
+	}
+	return validatePacketID(UNSUBSCRIBE, unsubscribe.PacketID)
+}
+
+// 2.2.1 packet identifiers are non-zero
+func validatePacketID(t pktType, id uint16) error {
+	if id == 0 {
+		return protocolError("%v with packet identifier 0", t)
+	}
+	return nil
+}
+
+func protocolError(format string, args ...interface{}) *DisconnectError {
+	return &DisconnectError{
+		Reason: DisconnectProtocolError,
+		Err:    fmt.Errorf(format, args...),
+	}
+}
+
+//sentBy reports whether control packets of type t may be sent by role (2.1.2).
+func sentBy(t pktType, role Role) bool {
+	switch t {
+	case CONNECT, SUBSCRIBE, UNSUBSCRIBE, PINGREQ:
+		return role == RoleClient
+	case CONNACK, SUBACK, UNSUBACK, PINGRESP:
+		return role == RoleServer
+	}
+	return true
+}
+
+func connackReason(pkt Packet) ConnectReason {
+	switch p := pkt.(type) {
+	case Connack:
+		return p.ConnectReason
+	case *Connack:
+		return p.ConnectReason
+	}
+	return 0
+}
+
+func typeOf(pkt Packet) (pktType, bool) {
+	switch pkt.(type) {
+	case Connect, *Connect:
+		return CONNECT, true
+	case Connack, *Connack:
+		return CONNACK, true
+	case Publish, *Publish:
+		return PUBLISH, true
+	case Puback, *Puback:
+		return PUBACK, true
+	case Pubrec, *Pubrec:
+		return PUBREC, true
+	case Pubrel, *Pubrel:
+		return PUBREL, true
+	case Pubcomp, *Pubcomp:
+		return PUBCOMP, true
+	case Subscribe, *Subscribe:
+		return SUBSCRIBE, true
+	case Suback, *Suback:
+		return SUBACK, true
+	case Unsubscribe, *Unsubscribe:
+		return UNSUBSCRIBE, true
+	case Unsuback, *Unsuback:
+		return UNSUBACK, true
+	case Pingreq, *Pingreq:
+		return PINGREQ, true
+	case Pingresp, *Pingresp:
+		return PINGRESP, true
+	case Disconnect, *Disconnect:
+		return DISCONNECT, true
+	}
+	return 0, false
+}
@@ -0,0 +1,162 @@
+package packet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type step struct {
+	send bool
+	pkt  Packet
+}
+
+func recv(pkt Packet) step { return step{pkt: pkt} }
+func sent(pkt Packet) step { return step{send: true, pkt: pkt} }
+
+func TestValidator(t *testing.T) {
+	subscribe := &Subscribe{PacketID: 1, Filters: []SubscriptionFilter{{Filter: "a"}}}
+	publish := &Publish{Qos: Qos1, PacketID: 1}
+
+	tests := []struct {
+		name  string
+		role  Role
+		steps []step
+		// wantReason is the reason of the error for the last step, 0 if all steps are valid
+		wantReason DisconnectReason
+	}{
+		{
+			name:  "server: session",
+			role:  RoleServer,
+			steps: []step{recv(&Connect{}), sent(&Connack{}), recv(subscribe), sent(&Suback{PacketID: 1}), recv(publish), sent(&Puback{PacketID: 1}), recv(&Pingreq{}), sent(&Pingresp{}), recv(&Disconnect{})},
+		},
+		{
+			name:  "client: session",
+			role:  RoleClient,
+			steps: []step{sent(&Connect{}), sent(publish), recv(&Connack{}), recv(&Puback{PacketID: 1}), recv(&Publish{}), sent(&Disconnect{})},
+		},
+		{
+			name:       "server: publish before connect",
+			role:       RoleServer,
+			steps:      []step{recv(publish)},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "server: second connect",
+			role:       RoleServer,
+			steps:      []step{recv(&Connect{}), sent(&Connack{}), recv(&Connect{})},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "server: receives connack",
+			role:       RoleServer,
+			steps:      []step{recv(&Connect{}), sent(&Connack{}), recv(&Connack{})},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "server: sends publish before connack",
+			role:       RoleServer,
+			steps:      []step{recv(&Connect{}), sent(publish)},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "server: sends after failed connack",
+			role:       RoleServer,
+			steps:      []step{recv(&Connect{}), sent(&Connack{ConnectReason: ConnectProtocolError}), sent(&Disconnect{})},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "server: subscribe without filters",
+			role:       RoleServer,
+			steps:      []step{recv(&Connect{}), sent(&Connack{}), recv(&Subscribe{PacketID: 1})},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "server: publish without packet identifier",
+			role:       RoleServer,
+			steps:      []step{recv(&Connect{}), sent(&Connack{}), recv(&Publish{Qos: Qos2})},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "server: packet after disconnect",
+			role:       RoleServer,
+			steps:      []step{recv(&Connect{}), sent(&Connack{}), recv(&Disconnect{}), recv(&Pingreq{})},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "client: receives before connect",
+			role:       RoleClient,
+			steps:      []step{recv(&Connack{})},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "client: receives publish before connack",
+			role:       RoleClient,
+			steps:      []step{sent(&Connect{}), recv(publish)},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "client: second connack",
+			role:       RoleClient,
+			steps:      []step{sent(&Connect{}), recv(&Connack{}), recv(&Connack{})},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "client: receives subscribe",
+			role:       RoleClient,
+			steps:      []step{sent(&Connect{}), recv(&Connack{}), recv(subscribe)},
+			wantReason: DisconnectProtocolError,
+		},
+		{
+			name:       "client: sends pingresp",
+			role:       RoleClient,
+			steps:      []step{sent(&Connect{}), recv(&Connack{}), sent(Pingresp{})},
+			wantReason: DisconnectProtocolError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewValidator(tt.role)
+			for i, step := range tt.steps {
+				var err error
+				if step.send {
+					err = v.Send(step.pkt)
+				} else {
+					err = v.Receive(step.pkt)
+				}
+
+				if i < len(tt.steps)-1 || tt.wantReason == 0 {
+					if err != nil {
+						t.Fatalf("step %d: unexpected error = %v", i, err)
+					}
+					continue
+				}
+				var disconnectErr *DisconnectError
+				if !errors.As(err, &disconnectErr) || disconnectErr.Reason != tt.wantReason {
+					t.Errorf("step %d: error = %v, want reason %d", i, err, tt.wantReason)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatorReadWrite(t *testing.T) {
+	client := NewValidator(RoleClient)
+	server := NewValidator(RoleServer)
+	buf := &bytes.Buffer{}
+
+	if _, err := client.Write(buf, &Pingreq{}); err == nil {
+		t.Fatal("Write() of pingreq before connect succeeded")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("Write() wrote %d bytes of an illegal packet", buf.Len())
+	}
+
+	if _, err := (Pingreq{}).WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if _, err := server.Read(buf); err == nil {
+		t.Error("Read() of pingreq before connect succeeded")
+	}
+}
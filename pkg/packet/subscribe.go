@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"github.com/squ94wk/mqtt-common/internal/types"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
 )
 
 //Subscribe defines the subscribe control packet.
@@ -114,6 +115,10 @@ func readSubscribe(reader io.Reader, subscribe *Subscribe) error {
 		if err != nil {
 			return fmt.Errorf("failed to read subscribe packet: failed to read filter: %v", err)
 		}
+		parsedFilter, err := topic.ParseFilter(filter)
+		if err != nil {
+			return fmt.Errorf("failed to read subscribe packet: protocol error: %v", err)
+		}
 
 		var buf [1]byte
 		_, err = io.ReadFull(reader, buf[:])
@@ -127,6 +132,11 @@ func readSubscribe(reader io.Reader, subscribe *Subscribe) error {
 		retainAsPublished := options&(1<<3) > 0
 		retainHandling := options & (3 << 4) >> 4
 
+		// 3.8.3.1 No Local must not be set on a shared subscription
+		if noLocal && parsedFilter.IsShared() {
+			return fmt.Errorf("failed to read subscribe packet: protocol error: no local is set on shared subscription '%s'", filter)
+		}
+
 		filters = append(filters, SubscriptionFilter{
 			Filter:            filter,
 			MaxQoS:            maxQoS,
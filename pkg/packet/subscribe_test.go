@@ -35,6 +35,70 @@ func TestReadSubscribe(t *testing.T) {
 			},
 			want: &subscribe2,
 		},
+
+		{
+			name: "invalid filter => err",
+			args: args{
+				reader: bytes.NewReader(help.Concat(
+					[]byte{byte(SUBSCRIBE)<<4 | 2, 10},
+					[]byte{0, 1},
+					[]byte{0},
+					[]byte{0, 5, 'a', '/', '#', '/', 'b', 0},
+				)),
+			},
+			wantErr: true,
+		},
+
+		{
+			name: "shared subscription",
+			args: args{
+				reader: bytes.NewReader(help.Concat(
+					[]byte{byte(SUBSCRIBE)<<4 | 2, 16},
+					[]byte{0, 1},
+					[]byte{0},
+					[]byte{0, 10},
+					[]byte("$share/g/#"),
+					[]byte{1},
+				)),
+			},
+			want: &Subscribe{
+				PacketID: 1,
+				Props:    NewProperties(),
+				Filters: []SubscriptionFilter{
+					{Filter: "$share/g/#", MaxQoS: Qos1},
+				},
+			},
+		},
+
+		{
+			name: "no local on shared subscription => err",
+			args: args{
+				reader: bytes.NewReader(help.Concat(
+					[]byte{byte(SUBSCRIBE)<<4 | 2, 16},
+					[]byte{0, 1},
+					[]byte{0},
+					[]byte{0, 10},
+					[]byte("$share/g/#"),
+					[]byte{1 | 1<<2},
+				)),
+			},
+			wantErr: true,
+		},
+
+		{
+			name: "invalid share name => err",
+			args: args{
+				reader: bytes.NewReader(help.Concat(
+					[]byte{byte(SUBSCRIBE)<<4 | 2, 16},
+					[]byte{0, 1},
+					[]byte{0},
+					[]byte{0, 10},
+					[]byte("$share/+/#"),
+					[]byte{0},
+				)),
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
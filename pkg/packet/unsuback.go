@@ -0,0 +1,93 @@
+package packet
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/squ94wk/mqtt-common/internal/types"
+)
+
+//Unsuback defines the unsuback control packet.
+type Unsuback struct {
+	PacketID uint16
+	Props    Properties
+	Reasons  []UnsubackReason
+}
+
+//WriteTo writes the unsuback control packet to writer according to the mqtt protocol.
+func (u Unsuback) WriteTo(writer io.Writer) (int64, error) {
+	var n int64
+	// 3.11.1 Fixed header
+	firstByte := byte(UNSUBACK) << 4
+	n1, err := writer.Write([]byte{firstByte})
+	n += int64(n1)
+	if err != nil {
+		return n, fmt.Errorf("failed to write unsuback packet: failed to write fixed header: %v", err)
+	}
+
+	//3.11.2 Variable header
+	var remainingLength = types.UInt16Size // packetID
+	remainingLength += u.Props.size()
+	remainingLength += uint32(len(u.Reasons))
+	n2, err := types.WriteVarIntTo(writer, remainingLength)
+	n += n2
+	if err != nil {
+		return n, fmt.Errorf("failed to write unsuback packet: failed to write packet length: %v", err)
+	}
+
+	n3, err := types.WriteUInt16To(writer, u.PacketID)
+	n += n3
+	if err != nil {
+		return n, fmt.Errorf("failed to write unsuback packet: failed to write packetID: %v", err)
+	}
+
+	n4, err := u.Props.WriteTo(writer)
+	n += n4
+	if err != nil {
+		return n, fmt.Errorf("failed to write unsuback packet: failed to write properties: %v", err)
+	}
+
+	// 3.11.3 Payload
+	reasonsBuf := make([]byte, len(u.Reasons))
+	for i, reason := range u.Reasons {
+		reasonsBuf[i] = byte(reason)
+	}
+	n5, err := writer.Write(reasonsBuf)
+	n += int64(n5)
+	if err != nil {
+		return n, fmt.Errorf("failed to write unsuback packet: failed to write unsuback reason codes: %v", err)
+	}
+
+	return n, nil
+}
+
+func readUnsuback(reader io.Reader, unsuback *Unsuback) error {
+	// 3.11.2 Variable header
+	// 3.11.2.1 Unsuback packet ID
+	packetID, err := types.ReadUInt16(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read unsuback packet: failed to read packet ID: %v", err)
+	}
+	unsuback.PacketID = packetID
+
+	// 3.11.2.1 Unsuback properties
+	props, err := readProperties(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read unsuback packet: failed to read properties: %v", err)
+	}
+	unsuback.Props = props
+
+	// 3.11.3 Payload
+	reasonBuf := make([]byte, reader.(*io.LimitedReader).N)
+	_, err = io.ReadFull(reader, reasonBuf)
+	if err != nil {
+		return fmt.Errorf("failed to read unsuback packet: failed to read reason codes: %v", err)
+	}
+
+	reasons := make([]UnsubackReason, len(reasonBuf))
+	for i, reason := range reasonBuf {
+		reasons[i] = UnsubackReason(reason)
+	}
+	unsuback.Reasons = reasons
+	return nil
+}
@@ -0,0 +1,95 @@
+package packet
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/squ94wk/mqtt-common/internal/types"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//Unsubscribe defines the unsubscribe control packet.
+type Unsubscribe struct {
+	PacketID uint16
+	Props    Properties
+	Filters  []string
+}
+
+//WriteTo writes the unsubscribe control packet to writer according to the mqtt protocol.
+func (u Unsubscribe) WriteTo(writer io.Writer) (int64, error) {
+	var n int64
+	// 3.10.1 Fixed header
+	firstByte := byte(UNSUBSCRIBE)<<4 | 2
+	n1, err := writer.Write([]byte{firstByte})
+	n += int64(n1)
+	if err != nil {
+		return n, fmt.Errorf("failed to write unsubscribe packet: failed to write fixed header: %v", err)
+	}
+
+	//3.10.2 Variable header
+	var remainingLength = types.UInt16Size // packetID
+	remainingLength += u.Props.size()
+	for _, filter := range u.Filters {
+		remainingLength += types.StringSize(filter)
+	}
+	n2, err := types.WriteVarIntTo(writer, remainingLength)
+	n += n2
+	if err != nil {
+		return n, fmt.Errorf("failed to write unsubscribe packet: failed to write packet length: %v", err)
+	}
+
+	n3, err := types.WriteUInt16To(writer, u.PacketID)
+	n += n3
+	if err != nil {
+		return n, fmt.Errorf("failed to write unsubscribe packet: failed to write packetID: %v", err)
+	}
+
+	n4, err := u.Props.WriteTo(writer)
+	n += n4
+	if err != nil {
+		return n, fmt.Errorf("failed to write unsubscribe packet: failed to write properties: %v", err)
+	}
+
+	// 3.10.3 Payload
+	for _, filter := range u.Filters {
+		n5, err := types.WriteStringTo(writer, filter)
+		n += n5
+		if err != nil {
+			return n, fmt.Errorf("failed to write unsubscribe packet: failed to write filter: %v", err)
+		}
+	}
+
+	return n, nil
+}
+
+func readUnsubscribe(reader io.Reader, unsubscribe *Unsubscribe) error {
+	// 3.10.2 Variable header
+	// 3.10.2.1 Unsubscribe packet ID
+	packetID, err := types.ReadUInt16(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read unsubscribe packet: failed to read packet ID: %v", err)
+	}
+	unsubscribe.PacketID = packetID
+
+	// 3.10.2.1 Unsubscribe properties
+	props, err := readProperties(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read unsubscribe packet: failed to read properties: %v", err)
+	}
+	unsubscribe.Props = props
+
+	// 3.10.3 Payload
+	var filters []string
+	for reader.(*io.LimitedReader).N > 0 {
+		filter, err := types.ReadString(reader)
+		if err != nil {
+			return fmt.Errorf("failed to read unsubscribe packet: failed to read filter: %v", err)
+		}
+		if err := topic.ValidateFilter(filter); err != nil {
+			return fmt.Errorf("failed to read unsubscribe packet: protocol error: %v", err)
+		}
+		filters = append(filters, filter)
+	}
+	unsubscribe.Filters = filters
+	return nil
+}
@@ -0,0 +1,75 @@
+package packet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/internal/help"
+)
+
+var unsubscribeTests = []struct {
+	name string
+	pkt  Packet
+	bin  []byte
+}{
+	{
+		name: "unsubscribe",
+		pkt:  &Unsubscribe{PacketID: 10, Props: NewProperties(), Filters: []string{"a/+", "$share/g/#"}},
+		bin:  []byte{byte(UNSUBSCRIBE)<<4 | 2, 20, 0, 10, 0, 0, 3, 'a', '/', '+', 0, 10, '$', 's', 'h', 'a', 'r', 'e', '/', 'g', '/', '#'},
+	},
+	{
+		name: "unsuback",
+		pkt:  &Unsuback{PacketID: 10, Props: NewProperties(), Reasons: []UnsubackReason{UnsubackSuccess, UnsubackNoSubscriptionExisted}},
+		bin:  []byte{byte(UNSUBACK) << 4, 5, 0, 10, 0, 0, byte(UnsubackNoSubscriptionExisted)},
+	},
+}
+
+func TestReadUnsubscribe(t *testing.T) {
+	for _, tt := range unsubscribeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt, err := ReadPacket(bytes.NewReader(tt.bin))
+			if err != nil {
+				t.Errorf("Read() error = %v", err)
+				return
+			}
+			if diff := deep.Equal(tt.pkt, pkt); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestWriteUnsubscribe(t *testing.T) {
+	for _, tt := range unsubscribeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &bytes.Buffer{}
+			if _, err := tt.pkt.WriteTo(writer); err != nil {
+				t.Errorf("pkt.WriteTo() error = %v", err)
+				return
+			}
+			if diff := help.Match(help.NewByteSegment(tt.bin), writer.Bytes()); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestReadUnsubscribeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		bin  []byte
+	}{
+		{name: "unsubscribe with invalid flags => err", bin: []byte{byte(UNSUBSCRIBE) << 4, 6, 0, 1, 0, 0, 1, 'a'}},
+		{name: "unsubscribe with invalid filter => err", bin: []byte{byte(UNSUBSCRIBE)<<4 | 2, 7, 0, 1, 0, 0, 2, 'a', '#'}},
+		{name: "auth => err", bin: []byte{byte(AUTH) << 4, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if pkt, err := ReadPacket(bytes.NewReader(tt.bin)); err == nil {
+				t.Errorf("Read() = %v, want error", pkt)
+			}
+		})
+	}
+}
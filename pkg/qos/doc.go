@@ -0,0 +1,9 @@
+package qos
+
+/*
+Package qos implements the delivery protocols of QoS 1 and QoS 2 application messages (4.3).
+A Sender tracks the publish control packets this side of a network connection sends,
+a Receiver those it receives.
+Both consume and produce the control packets defined in package packet and leave the network I/O to the user.
+SendQuota and ReceiveQuota implement the flow control based on the receive maximum (4.9).
+*/
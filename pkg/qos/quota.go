@@ -0,0 +1,189 @@
+package qos
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//DefaultReceiveMaximum is the receive maximum if the property is absent (3.1.2.11.3).
+const DefaultReceiveMaximum = 1<<16 - 1
+
+//ReceiveMaximum returns the receive maximum of the properties of a connect or connack control packet.
+//A *packet.DisconnectError is returned if the value is 0.
+func ReceiveMaximum(props packet.Properties) (uint16, error) {
+	max, ok := props.Int16(packet.ReceiveMaximum)
+	if !ok {
+		return DefaultReceiveMaximum, nil
+	}
+	if max == 0 {
+		return 0, &packet.DisconnectError{
+			Reason: packet.DisconnectProtocolError,
+			Err:    fmt.Errorf("receive maximum must not be 0"),
+		}
+	}
+	return max, nil
+}
+
+//SendQuota limits the number of QoS 1 and QoS 2 messages sent but not yet acknowledged
+//to the receive maximum of the receiver (4.9).
+//It is safe for concurrent use.
+type SendQuota struct {
+	mu        sync.Mutex
+	max       uint16
+	quota     uint16
+	available chan struct{}
+}
+
+//NewSendQuota is the constructor of the SendQuota type.
+//Max is the receive maximum the receiver sent in the connect or connack control packet.
+func NewSendQuota(max uint16) *SendQuota {
+	return &SendQuota{
+		max:   max,
+		quota: max,
+	}
+}
+
+//TryAcquire decrements the quota before a QoS 1 or QoS 2 publish is sent.
+//It reports false if the quota is exhausted and the publish must not be sent yet.
+func (q *SendQuota) TryAcquire() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.quota == 0 {
+		return false
+	}
+	q.quota--
+	return true
+}
+
+//Acquire is like TryAcquire, but blocks until the quota is available or ctx is done.
+func (q *SendQuota) Acquire(ctx context.Context) error {
+	for {
+		q.mu.Lock()
+		if q.quota > 0 {
+			q.quota--
+			q.mu.Unlock()
+			return nil
+		}
+		if q.available == nil {
+			q.available = make(chan struct{})
+		}
+		available := q.available
+		q.mu.Unlock()
+
+		select {
+		case <-available:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//HandleAck increments the quota for a received acknowledgement that ends a delivery:
+//a puback, a pubcomp or a pubrec with a reason code indicating an error.
+//Other control packets are ignored.
+func (q *SendQuota) HandleAck(pkt packet.Packet) {
+	switch ack := pkt.(type) {
+	case *packet.Puback, *packet.Pubcomp:
+	case *packet.Pubrec:
+		if ack.Reason < 0x80 {
+			return
+		}
+	default:
+		return
+	}
+
+	q.Release()
+}
+
+//Release increments the quota acquired for a QoS 1 or QoS 2 publish that isn't sent after all.
+func (q *SendQuota) Release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.quota < q.max {
+		q.quota++
+	}
+	if q.available != nil {
+		close(q.available)
+		q.available = nil
+	}
+}
+
+//Available returns the current quota.
+func (q *SendQuota) Available() uint16 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.quota
+}
+
+//ReceiveQuota checks that the sender doesn't exceed the receive maximum sent in the connect or connack control packet (3.3.4).
+//It is safe for concurrent use.
+type ReceiveQuota struct {
+	mu      sync.Mutex
+	max     uint16
+	pending map[uint16]struct{}
+}
+
+//NewReceiveQuota is the constructor of the ReceiveQuota type.
+//Max is the receive maximum this side sent in the connect or connack control packet.
+func NewReceiveQuota(max uint16) *ReceiveQuota {
+	return &ReceiveQuota{
+		max:     max,
+		pending: make(map[uint16]struct{}),
+	}
+}
+
+//HandlePublish records a received publish.
+//A *packet.DisconnectError with DisconnectReceiveMaximumExceeded is returned
+//if more QoS 1 and QoS 2 messages than the receive maximum are unacknowledged.
+//Duplicates of unacknowledged messages are not counted again.
+func (q *ReceiveQuota) HandlePublish(publish packet.Publish) error {
+	if publish.Qos == packet.Qos0 {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.pending[publish.PacketID]; ok {
+		return nil
+	}
+	if len(q.pending) >= int(q.max) {
+		return &packet.DisconnectError{
+			Reason: packet.DisconnectReceiveMaximumExceeded,
+			Err:    fmt.Errorf("more than %d messages are unacknowledged", q.max),
+		}
+	}
+	q.pending[publish.PacketID] = struct{}{}
+	return nil
+}
+
+//HandleAck records a sent acknowledgement that ends a delivery:
+//a puback, a pubcomp or a pubrec with a reason code indicating an error.
+//Other control packets are ignored.
+func (q *ReceiveQuota) HandleAck(pkt packet.Packet) {
+	var id uint16
+	switch ack := pkt.(type) {
+	case *packet.Puback:
+		id = ack.PacketID
+	case *packet.Pubcomp:
+		id = ack.PacketID
+	case *packet.Pubrec:
+		if ack.Reason < 0x80 {
+			return
+		}
+		id = ack.PacketID
+	default:
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.pending, id)
+}
@@ -0,0 +1,138 @@
+package qos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+func TestReceiveMaximum(t *testing.T) {
+	tests := []struct {
+		name    string
+		props   packet.Properties
+		want    uint16
+		wantErr bool
+	}{
+		{name: "absent", props: packet.NewProperties(), want: DefaultReceiveMaximum},
+		{name: "set", props: packet.NewProperties(packet.NewProperty(packet.ReceiveMaximum, packet.Int16PropPayload(10))), want: 10},
+		{name: "0 => err", props: packet.NewProperties(packet.NewProperty(packet.ReceiveMaximum, packet.Int16PropPayload(0))), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReceiveMaximum(tt.props)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReceiveMaximum() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ReceiveMaximum() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendQuota(t *testing.T) {
+	q := NewSendQuota(2)
+	if !q.TryAcquire() || !q.TryAcquire() {
+		t.Fatal("TryAcquire() = false, want true")
+	}
+	if q.TryAcquire() {
+		t.Fatal("TryAcquire() = true with exhausted quota")
+	}
+
+	q.HandleAck(&packet.Pubrec{PacketID: 1, Reason: packet.PubrecSuccess})
+	q.HandleAck(&packet.Pubrel{PacketID: 1})
+	if got := q.Available(); got != 0 {
+		t.Errorf("Available() = %d, want 0: successful pubrec doesn't end the delivery", got)
+	}
+
+	q.HandleAck(&packet.Pubcomp{PacketID: 1})
+	if got := q.Available(); got != 1 {
+		t.Errorf("Available() = %d, want 1", got)
+	}
+	q.HandleAck(&packet.Pubrec{PacketID: 2, Reason: packet.PubrecQuotaExceeded})
+	q.HandleAck(&packet.Puback{PacketID: 3})
+	if got := q.Available(); got != 2 {
+		t.Errorf("Available() = %d, want quota not to exceed the receive maximum of 2", got)
+	}
+
+	q.TryAcquire()
+	q.Release()
+	q.Release()
+	if got := q.Available(); got != 2 {
+		t.Errorf("Available() = %d after Release(), want 2", got)
+	}
+}
+
+func TestSendQuotaAcquire(t *testing.T) {
+	q := NewSendQuota(1)
+	if err := q.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Acquire() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- q.Acquire(context.Background())
+	}()
+	q.HandleAck(&packet.Puback{PacketID: 1})
+
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("Acquire() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire() did not return after puback")
+	}
+}
+
+func TestReceiveQuota(t *testing.T) {
+	publish := func(qos byte, id uint16) packet.Publish {
+		p := message(qos, "")
+		p.PacketID = id
+		return p
+	}
+
+	q := NewReceiveQuota(2)
+	for _, p := range []packet.Publish{
+		publish(packet.Qos1, 1),
+		publish(packet.Qos2, 2),
+		publish(packet.Qos0, 0),
+		publish(packet.Qos2, 2),
+	} {
+		if err := q.HandlePublish(p); err != nil {
+			t.Fatalf("HandlePublish() error = %v", err)
+		}
+	}
+
+	err := q.HandlePublish(publish(packet.Qos1, 3))
+	var disconnectErr *packet.DisconnectError
+	if !errors.As(err, &disconnectErr) || disconnectErr.Reason != packet.DisconnectReceiveMaximumExceeded {
+		t.Fatalf("HandlePublish() error = %v, want reason %d", err, packet.DisconnectReceiveMaximumExceeded)
+	}
+
+	q.HandleAck(&packet.Pubrec{PacketID: 2, Reason: packet.PubrecSuccess})
+	if err := q.HandlePublish(publish(packet.Qos1, 3)); err == nil {
+		t.Fatal("HandlePublish() succeeded, successful pubrec doesn't end the delivery")
+	}
+
+	q.HandleAck(&packet.Puback{PacketID: 1})
+	if err := q.HandlePublish(publish(packet.Qos1, 3)); err != nil {
+		t.Fatalf("HandlePublish() error = %v", err)
+	}
+
+	q.HandleAck(&packet.Pubcomp{PacketID: 2})
+	if err := q.HandlePublish(publish(packet.Qos2, 4)); err != nil {
+		t.Fatalf("HandlePublish() error = %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package qos
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//Receiver implements the receiver side of the QoS 1 and QoS 2 delivery protocols (4.3.2, 4.3.3).
+//QoS 2 messages are deduplicated by their packet identifier until the matching pubrel is received.
+//It is safe for concurrent use.
+type Receiver struct {
+	mu      sync.Mutex
+	pending map[uint16]struct{}
+}
+
+//NewReceiver is the constructor of the Receiver type.
+func NewReceiver() *Receiver {
+	return &Receiver{
+		pending: make(map[uint16]struct{}),
+	}
+}
+
+//HandlePublish returns the acknowledgement that has to be sent in response to publish, nil for QoS 0 messages.
+//It reports whether publish is new and has to be delivered to the application.
+//Reason is sent in the puback or pubrec control packet.
+//A QoS 2 message acknowledged with a reason code indicating an error is not recorded (4.3.3).
+func (r *Receiver) HandlePublish(publish packet.Publish, reason packet.PubackReason) (packet.Packet, bool) {
+	switch publish.Qos {
+	case packet.Qos1:
+		return &packet.Puback{
+			PacketID: publish.PacketID,
+			Reason:   reason,
+			Props:    packet.NewProperties(),
+		}, true
+
+	case packet.Qos2:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		pubrec := &packet.Pubrec{
+			PacketID: publish.PacketID,
+			Reason:   packet.PubrecReason(reason),
+			Props:    packet.NewProperties(),
+		}
+		if _, ok := r.pending[publish.PacketID]; ok {
+			// duplicate, the message has already been delivered
+			pubrec.Reason = packet.PubrecSuccess
+			return pubrec, false
+		}
+		if reason < 0x80 {
+			r.pending[publish.PacketID] = struct{}{}
+		}
+		return pubrec, true
+	}
+
+	return nil, true
+}
+
+//Duplicate reports whether publish is a QoS 2 message that has already been received and awaits its pubrel (4.3.3).
+//A duplicate is acknowledged by HandlePublish but not delivered again.
+func (r *Receiver) Duplicate(publish packet.Publish) bool {
+	if publish.Qos != packet.Qos2 {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.pending[publish.PacketID]
+	return ok
+}
+
+//HandlePubrel returns the pubcomp control packet that has to be sent in response to pubrel.
+//The packet identifier of pubrel can then be used for new QoS 2 messages.
+func (r *Receiver) HandlePubrel(pubrel packet.Pubrel) *packet.Pubcomp {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pubcomp := &packet.Pubcomp{
+		PacketID: pubrel.PacketID,
+		Reason:   packet.PubcompSuccess,
+		Props:    packet.NewProperties(),
+	}
+	if _, ok := r.pending[pubrel.PacketID]; !ok {
+		pubcomp.Reason = packet.PubcompPacketIdentifierNotFound
+		return pubcomp
+	}
+	delete(r.pending, pubrel.PacketID)
+	return pubcomp
+}
+
+//Pending returns the packet identifiers of the QoS 2 messages awaiting a pubrel in ascending order, e.g. to persist them.
+func (r *Receiver) Pending() []uint16 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]uint16, 0, len(r.pending))
+	for id := range r.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+//Restore adds previously persisted packet identifiers of QoS 2 messages awaiting a pubrel.
+func (r *Receiver) Restore(ids []uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		r.pending[id] = struct{}{}
+	}
+}
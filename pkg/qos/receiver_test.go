@@ -0,0 +1,132 @@
+package qos
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+func TestReceiverHandlePublish(t *testing.T) {
+	withID := func(publish packet.Publish, id uint16, dup bool) packet.Publish {
+		publish.PacketID = id
+		publish.Dup = dup
+		return publish
+	}
+
+	r := NewReceiver()
+	tests := []struct {
+		name        string
+		publish     packet.Publish
+		reason      packet.PubackReason
+		wantAck     packet.Packet
+		wantDeliver bool
+	}{
+		{
+			name:        "QoS 0",
+			publish:     message(packet.Qos0, "0"),
+			wantDeliver: true,
+		},
+		{
+			name:        "QoS 1",
+			publish:     withID(message(packet.Qos1, "1"), 1, false),
+			wantAck:     &packet.Puback{PacketID: 1, Reason: packet.PubackSuccess, Props: packet.NewProperties()},
+			wantDeliver: true,
+		},
+		{
+			name:        "QoS 1 duplicate is delivered again",
+			publish:     withID(message(packet.Qos1, "1"), 1, true),
+			wantAck:     &packet.Puback{PacketID: 1, Reason: packet.PubackSuccess, Props: packet.NewProperties()},
+			wantDeliver: true,
+		},
+		{
+			name:        "QoS 1 with reason",
+			publish:     withID(message(packet.Qos1, "1"), 2, false),
+			reason:      packet.PubackNoMatchingSubscribers,
+			wantAck:     &packet.Puback{PacketID: 2, Reason: packet.PubackNoMatchingSubscribers, Props: packet.NewProperties()},
+			wantDeliver: true,
+		},
+		{
+			name:        "QoS 2",
+			publish:     withID(message(packet.Qos2, "2"), 1, false),
+			wantAck:     &packet.Pubrec{PacketID: 1, Reason: packet.PubrecSuccess, Props: packet.NewProperties()},
+			wantDeliver: true,
+		},
+		{
+			name:        "QoS 2 duplicate is not delivered again",
+			publish:     withID(message(packet.Qos2, "2"), 1, true),
+			wantAck:     &packet.Pubrec{PacketID: 1, Reason: packet.PubrecSuccess, Props: packet.NewProperties()},
+			wantDeliver: false,
+		},
+		{
+			name:        "QoS 2 rejected",
+			publish:     withID(message(packet.Qos2, "2"), 2, false),
+			reason:      packet.PubackNotAuthorized,
+			wantAck:     &packet.Pubrec{PacketID: 2, Reason: packet.PubrecNotAuthorized, Props: packet.NewProperties()},
+			wantDeliver: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack, deliver := r.HandlePublish(tt.publish, tt.reason)
+			if diff := deep.Equal(ack, tt.wantAck); diff != nil {
+				t.Error(diff)
+			}
+			if deliver != tt.wantDeliver {
+				t.Errorf("HandlePublish() deliver = %v, want %v", deliver, tt.wantDeliver)
+			}
+		})
+	}
+
+	if diff := deep.Equal(r.Pending(), []uint16{1}); diff != nil {
+		t.Errorf("Pending(): %v", diff)
+	}
+}
+
+func TestReceiverHandlePubrel(t *testing.T) {
+	r := NewReceiver()
+	publish := message(packet.Qos2, "2")
+	publish.PacketID = 1
+	r.HandlePublish(publish, packet.PubackSuccess)
+
+	pubcomp := r.HandlePubrel(packet.Pubrel{PacketID: 1})
+	want := &packet.Pubcomp{PacketID: 1, Reason: packet.PubcompSuccess, Props: packet.NewProperties()}
+	if diff := deep.Equal(pubcomp, want); diff != nil {
+		t.Error(diff)
+	}
+
+	pubcomp = r.HandlePubrel(packet.Pubrel{PacketID: 1})
+	want.Reason = packet.PubcompPacketIdentifierNotFound
+	if diff := deep.Equal(pubcomp, want); diff != nil {
+		t.Error(diff)
+	}
+
+	// the packet identifier is free for a new message
+	if _, deliver := r.HandlePublish(publish, packet.PubackSuccess); !deliver {
+		t.Error("HandlePublish() deliver = false after pubrel, want true")
+	}
+}
+
+func TestReceiverRestore(t *testing.T) {
+	r := NewReceiver()
+	r.Restore([]uint16{3, 1})
+	if diff := deep.Equal(r.Pending(), []uint16{1, 3}); diff != nil {
+		t.Error(diff)
+	}
+
+	publish := message(packet.Qos2, "2")
+	publish.PacketID = 3
+	publish.Dup = true
+	if !r.Duplicate(publish) {
+		t.Error("Duplicate() = false for restored packet ID, want true")
+	}
+	publish.PacketID = 2
+	if r.Duplicate(publish) {
+		t.Error("Duplicate() = true for new packet ID, want false")
+	}
+	publish.PacketID = 3
+	if _, deliver := r.HandlePublish(publish, packet.PubackSuccess); deliver {
+		t.Error("HandlePublish() deliver = true for restored packet ID, want false")
+	}
+}
@@ -0,0 +1,234 @@
+package qos
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//State is the state of an outgoing QoS 1 or QoS 2 message.
+type State byte
+
+//The states of an outgoing message that hasn't completed yet.
+const (
+	AwaitingPuback State = iota + 1
+	AwaitingPubrec
+	AwaitingPubcomp
+)
+
+//ErrUnknownPacketID is returned if an acknowledgement refers to no message in flight.
+//This is expected after a session was resumed and can be ignored.
+var ErrUnknownPacketID = errors.New("no message in flight with this packet identifier")
+
+//Message is an outgoing message in flight.
+type Message struct {
+	Publish packet.Publish
+	State   State
+}
+
+//Sender implements the sender side of the QoS 1 and QoS 2 delivery protocols (4.3.2, 4.3.3).
+//Messages in flight are kept in the order they were sent, so they can be resent in that order (4.4).
+//It is safe for concurrent use.
+type Sender struct {
+	mu       sync.Mutex
+	ids      *packet.IDAllocator
+	order    *list.List
+	inFlight map[uint16]*list.Element
+}
+
+//NewSender is the constructor of the Sender type.
+//Ids assigns the packet identifiers of outgoing messages and is usually shared with other outgoing control packets.
+func NewSender(ids *packet.IDAllocator) *Sender {
+	return &Sender{
+		ids:      ids,
+		order:    list.New(),
+		inFlight: make(map[uint16]*list.Element),
+	}
+}
+
+//Send returns publish as it has to be sent.
+//QoS 1 and QoS 2 messages are assigned a packet identifier and tracked until they are acknowledged.
+//If all packet identifiers are in use Send blocks until one is released or ctx is done.
+func (s *Sender) Send(ctx context.Context, publish packet.Publish) (packet.Publish, error) {
+	publish.Dup = false
+	switch publish.Qos {
+	case packet.Qos0:
+		publish.PacketID = 0
+		return publish, nil
+	case packet.Qos1, packet.Qos2:
+	default:
+		return publish, fmt.Errorf("invalid QoS %d", publish.Qos)
+	}
+
+	id, err := s.ids.AcquireWait(ctx)
+	if err != nil {
+		return publish, fmt.Errorf("failed to acquire packet identifier: %v", err)
+	}
+	publish.PacketID = id
+
+	state := AwaitingPuback
+	if publish.Qos == packet.Qos2 {
+		state = AwaitingPubrec
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight[id] = s.order.PushBack(&Message{Publish: publish, State: state})
+	return publish, nil
+}
+
+//Cancel stops tracking the message with packet identifier id returned by Send, if it isn't sent after all, and releases the identifier.
+//Unknown packet identifiers are ignored.
+func (s *Sender) Cancel(id uint16) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.inFlight[id]; ok {
+		s.complete(id)
+	}
+}
+
+//HandlePuback completes the QoS 1 message acknowledged by puback and returns it.
+//The message is complete regardless of the reason code of puback (4.3.2).
+func (s *Sender) HandlePuback(puback packet.Puback) (packet.Publish, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, err := s.get(puback.PacketID, AwaitingPuback)
+	if err != nil {
+		return packet.Publish{}, err
+	}
+	s.complete(puback.PacketID)
+	return msg.Publish, nil
+}
+
+//HandlePubrec returns the pubrel control packet that has to be sent in response to pubrec.
+//If the reason code of pubrec indicates an error, the message is complete and no pubrel is returned (4.3.3).
+//For unknown packet identifiers a pubrel with reason code PubrelPacketIdentifierNotFound is returned.
+func (s *Sender) HandlePubrec(pubrec packet.Pubrec) (*packet.Pubrel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.inFlight[pubrec.PacketID]; ok && elem.Value.(*Message).State == AwaitingPubcomp {
+		// a duplicate pubrec is answered again
+		return &packet.Pubrel{
+			PacketID: pubrec.PacketID,
+			Reason:   packet.PubrelSuccess,
+			Props:    packet.NewProperties(),
+		}, nil
+	}
+
+	msg, err := s.get(pubrec.PacketID, AwaitingPubrec)
+	if err == ErrUnknownPacketID {
+		return &packet.Pubrel{
+			PacketID: pubrec.PacketID,
+			Reason:   packet.PubrelPacketIdentifierNotFound,
+			Props:    packet.NewProperties(),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if pubrec.Reason >= 0x80 {
+		s.complete(pubrec.PacketID)
+		return nil, nil
+	}
+
+	msg.State = AwaitingPubcomp
+	return &packet.Pubrel{
+		PacketID: pubrec.PacketID,
+		Reason:   packet.PubrelSuccess,
+		Props:    packet.NewProperties(),
+	}, nil
+}
+
+//HandlePubcomp completes the QoS 2 message acknowledged by pubcomp and returns it.
+func (s *Sender) HandlePubcomp(pubcomp packet.Pubcomp) (packet.Publish, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, err := s.get(pubcomp.PacketID, AwaitingPubcomp)
+	if err != nil {
+		return packet.Publish{}, err
+	}
+	s.complete(pubcomp.PacketID)
+	return msg.Publish, nil
+}
+
+//Resend returns the control packets that have to be resent when a session is resumed (4.4).
+//Publish control packets are resent with the DUP flag set, messages awaiting a pubcomp are resent as pubrel.
+func (s *Sender) Resend() []packet.Packet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pkts := make([]packet.Packet, 0, s.order.Len())
+	for elem := s.order.Front(); elem != nil; elem = elem.Next() {
+		msg := elem.Value.(*Message)
+		if msg.State == AwaitingPubcomp {
+			pkts = append(pkts, &packet.Pubrel{
+				PacketID: msg.Publish.PacketID,
+				Reason:   packet.PubrelSuccess,
+				Props:    packet.NewProperties(),
+			})
+			continue
+		}
+		publish := msg.Publish
+		publish.Dup = true
+		pkts = append(pkts, &publish)
+	}
+	return pkts
+}
+
+//InFlight returns the messages in flight in the order they were sent, e.g. to persist them.
+func (s *Sender) InFlight() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := make([]Message, 0, s.order.Len())
+	for elem := s.order.Front(); elem != nil; elem = elem.Next() {
+		msgs = append(msgs, *elem.Value.(*Message))
+	}
+	return msgs
+}
+
+//Restore adds previously persisted messages in flight and claims their packet identifiers.
+func (s *Sender) Restore(msgs []Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, msg := range msgs {
+		id := msg.Publish.PacketID
+		if err := s.ids.Claim(packet.Outgoing, id); err != nil {
+			return fmt.Errorf("failed to restore message with packet identifier %d: %v", id, err)
+		}
+		msg := msg
+		s.inFlight[id] = s.order.PushBack(&msg)
+	}
+	return nil
+}
+
+func (s *Sender) get(id uint16, state State) (*Message, error) {
+	elem, ok := s.inFlight[id]
+	if !ok {
+		return nil, ErrUnknownPacketID
+	}
+	msg := elem.Value.(*Message)
+	if msg.State != state {
+		return nil, &packet.DisconnectError{
+			Reason: packet.DisconnectProtocolError,
+			Err:    fmt.Errorf("unexpected acknowledgement for message with packet identifier %d", id),
+		}
+	}
+	return msg, nil
+}
+
+func (s *Sender) complete(id uint16) {
+	s.order.Remove(s.inFlight[id])
+	delete(s.inFlight, id)
+	s.ids.Release(packet.Outgoing, id)
+}
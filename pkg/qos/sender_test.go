@@ -0,0 +1,205 @@
+package qos
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+func message(qos byte, payload string) packet.Publish {
+	return packet.Publish{
+		Qos:     qos,
+		Topic:   topic.Topic{Levels: []string{"a"}},
+		Props:   packet.NewProperties(),
+		Payload: []byte(payload),
+	}
+}
+
+func send(t *testing.T, s *Sender, publish packet.Publish) packet.Publish {
+	t.Helper()
+	sent, err := s.Send(context.Background(), publish)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	return sent
+}
+
+func TestSenderQos0(t *testing.T) {
+	s := NewSender(packet.NewIDAllocator())
+	sent := send(t, s, message(packet.Qos0, "0"))
+	if sent.PacketID != 0 {
+		t.Errorf("Send() packet ID = %d, want 0", sent.PacketID)
+	}
+	if len(s.InFlight()) != 0 {
+		t.Errorf("InFlight() = %v, QoS 0 messages must not be tracked", s.InFlight())
+	}
+}
+
+func TestSenderQos1(t *testing.T) {
+	ids := packet.NewIDAllocator()
+	s := NewSender(ids)
+	sent := send(t, s, message(packet.Qos1, "1"))
+	if sent.PacketID == 0 {
+		t.Fatal("Send() didn't assign a packet ID")
+	}
+
+	if _, err := s.HandlePubcomp(packet.Pubcomp{PacketID: sent.PacketID}); !isProtocolError(err) {
+		t.Errorf("HandlePubcomp() error = %v, want protocol error", err)
+	}
+
+	got, err := s.HandlePuback(packet.Puback{PacketID: sent.PacketID, Reason: packet.PubackNotAuthorized})
+	if err != nil {
+		t.Fatalf("HandlePuback() error = %v", err)
+	}
+	if diff := deep.Equal(got, sent); diff != nil {
+		t.Error(diff)
+	}
+	if ids.InUse(packet.Outgoing, sent.PacketID) {
+		t.Error("packet ID is still in use after puback")
+	}
+
+	if _, err := s.HandlePuback(packet.Puback{PacketID: sent.PacketID}); err != ErrUnknownPacketID {
+		t.Errorf("HandlePuback() error = %v, want %v", err, ErrUnknownPacketID)
+	}
+}
+
+func TestSenderCancel(t *testing.T) {
+	ids := packet.NewIDAllocator()
+	s := NewSender(ids)
+	sent := send(t, s, message(packet.Qos1, "1"))
+
+	s.Cancel(sent.PacketID)
+	if len(s.InFlight()) != 0 {
+		t.Errorf("InFlight() = %v after Cancel()", s.InFlight())
+	}
+	if ids.InUse(packet.Outgoing, sent.PacketID) {
+		t.Error("packet ID is still in use after Cancel()")
+	}
+	s.Cancel(sent.PacketID)
+}
+
+func TestSenderQos2(t *testing.T) {
+	ids := packet.NewIDAllocator()
+	s := NewSender(ids)
+	sent := send(t, s, message(packet.Qos2, "2"))
+
+	if _, err := s.HandlePuback(packet.Puback{PacketID: sent.PacketID}); !isProtocolError(err) {
+		t.Errorf("HandlePuback() error = %v, want protocol error", err)
+	}
+
+	pubrel, err := s.HandlePubrec(packet.Pubrec{PacketID: sent.PacketID})
+	if err != nil {
+		t.Fatalf("HandlePubrec() error = %v", err)
+	}
+	want := &packet.Pubrel{PacketID: sent.PacketID, Reason: packet.PubrelSuccess, Props: packet.NewProperties()}
+	if diff := deep.Equal(pubrel, want); diff != nil {
+		t.Error(diff)
+	}
+
+	// a duplicate pubrec is answered again
+	pubrel, err = s.HandlePubrec(packet.Pubrec{PacketID: sent.PacketID})
+	if err != nil {
+		t.Fatalf("HandlePubrec() error = %v", err)
+	}
+	if diff := deep.Equal(pubrel, want); diff != nil {
+		t.Error(diff)
+	}
+
+	got, err := s.HandlePubcomp(packet.Pubcomp{PacketID: sent.PacketID})
+	if err != nil {
+		t.Fatalf("HandlePubcomp() error = %v", err)
+	}
+	if diff := deep.Equal(got, sent); diff != nil {
+		t.Error(diff)
+	}
+	if ids.InUse(packet.Outgoing, sent.PacketID) {
+		t.Error("packet ID is still in use after pubcomp")
+	}
+
+	pubrel, err = s.HandlePubrec(packet.Pubrec{PacketID: sent.PacketID})
+	if err != nil {
+		t.Fatalf("HandlePubrec() error = %v", err)
+	}
+	if pubrel == nil || pubrel.Reason != packet.PubrelPacketIdentifierNotFound {
+		t.Errorf("HandlePubrec() = %v, want reason %d", pubrel, packet.PubrelPacketIdentifierNotFound)
+	}
+}
+
+func TestSenderQos2Rejected(t *testing.T) {
+	ids := packet.NewIDAllocator()
+	s := NewSender(ids)
+	sent := send(t, s, message(packet.Qos2, "2"))
+
+	pubrel, err := s.HandlePubrec(packet.Pubrec{PacketID: sent.PacketID, Reason: packet.PubrecQuotaExceeded})
+	if err != nil || pubrel != nil {
+		t.Errorf("HandlePubrec() = %v, %v, want no pubrel", pubrel, err)
+	}
+	if len(s.InFlight()) != 0 || ids.InUse(packet.Outgoing, sent.PacketID) {
+		t.Error("rejected message is still in flight")
+	}
+}
+
+func TestSenderResend(t *testing.T) {
+	s := NewSender(packet.NewIDAllocator())
+	first := send(t, s, message(packet.Qos1, "1"))
+	second := send(t, s, message(packet.Qos2, "2"))
+	third := send(t, s, message(packet.Qos2, "3"))
+	send(t, s, message(packet.Qos0, "0"))
+
+	if _, err := s.HandlePubrec(packet.Pubrec{PacketID: second.PacketID}); err != nil {
+		t.Fatalf("HandlePubrec() error = %v", err)
+	}
+
+	first.Dup = true
+	third.Dup = true
+	want := []packet.Packet{
+		&first,
+		&packet.Pubrel{PacketID: second.PacketID, Reason: packet.PubrelSuccess, Props: packet.NewProperties()},
+		&third,
+	}
+	if diff := deep.Equal(s.Resend(), want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSenderRestore(t *testing.T) {
+	s := NewSender(packet.NewIDAllocator())
+	send(t, s, message(packet.Qos1, "1"))
+	second := send(t, s, message(packet.Qos2, "2"))
+	if _, err := s.HandlePubrec(packet.Pubrec{PacketID: second.PacketID}); err != nil {
+		t.Fatalf("HandlePubrec() error = %v", err)
+	}
+	state := s.InFlight()
+
+	ids := packet.NewIDAllocator()
+	restored := NewSender(ids)
+	if err := restored.Restore(state); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if diff := deep.Equal(restored.InFlight(), state); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(restored.Resend(), s.Resend()); diff != nil {
+		t.Error(diff)
+	}
+
+	next := send(t, restored, message(packet.Qos1, "3"))
+	for _, msg := range state {
+		if next.PacketID == msg.Publish.PacketID {
+			t.Errorf("Send() reused packet ID %d of a restored message", next.PacketID)
+		}
+	}
+
+	if err := restored.Restore(state); err == nil {
+		t.Error("Restore() of packet IDs in use succeeded")
+	}
+}
+
+func isProtocolError(err error) bool {
+	var disconnectErr *packet.DisconnectError
+	return errors.As(err, &disconnectErr) && disconnectErr.Reason == packet.DisconnectProtocolError
+}
@@ -0,0 +1,8 @@
+package queue
+
+/*
+Package queue implements the bounded queue of messages waiting to be sent to the client of a session (4.1).
+The number and the size of the queued messages are limited, a DropPolicy decides which messages are dropped once a limit is reached.
+Expired messages are skipped instead of being sent (3.3.2.3.3).
+The queued messages can be taken and restored, e.g. to save them with the session in a store.SessionStore.
+*/
@@ -0,0 +1,237 @@
+package queue
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/expiry"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//DefaultMaxCount is the number of messages a Queue holds if Options.MaxCount is 0.
+const DefaultMaxCount = 1000
+
+//DropPolicy decides which messages are dropped if a Queue is full.
+type DropPolicy int
+
+//Drop policies of a Queue.
+const (
+	//DropNewest drops new messages until there is room again.
+	DropNewest DropPolicy = iota
+	//DropOldest drops the messages queued first to make room for new ones.
+	DropOldest
+	//DropQoS0First drops the oldest QoS 0 messages to make room for new ones.
+	//New QoS 0 messages and new messages that don't fit after all QoS 0 messages were dropped are dropped themselves.
+	DropQoS0First
+)
+
+//String returns the name of the policy as used in command line flags.
+func (p DropPolicy) String() string {
+	switch p {
+	case DropNewest:
+		return "newest"
+	case DropOldest:
+		return "oldest"
+	case DropQoS0First:
+		return "qos0"
+	default:
+		return fmt.Sprintf("DropPolicy(%d)", int(p))
+	}
+}
+
+//ParseDropPolicy returns the policy with the name returned by DropPolicy.String.
+func ParseDropPolicy(name string) (DropPolicy, error) {
+	for _, p := range []DropPolicy{DropNewest, DropOldest, DropQoS0First} {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown drop policy '%s'", name)
+}
+
+//Options configures a Queue.
+type Options struct {
+	//MaxCount limits the number of queued messages, DefaultMaxCount if 0.
+	MaxCount int
+	//MaxBytes limits the total size of the queued publish control packets, unlimited if 0.
+	MaxBytes int
+	//Policy decides which messages are dropped once a limit is reached.
+	Policy DropPolicy
+}
+
+//Queue holds the messages waiting to be sent to the client of a session in the order they were received.
+//It is not safe for concurrent use.
+type Queue struct {
+	opts    Options
+	msgs    []entry
+	bytes   int
+	dropped uint64
+}
+
+//entry is a queued message together with its size.
+type entry struct {
+	msg  expiry.Message
+	size int
+}
+
+//New is the constructor of the Queue type.
+func New(opts Options) *Queue {
+	if opts.MaxCount <= 0 {
+		opts.MaxCount = DefaultMaxCount
+	}
+	return &Queue{opts: opts}
+}
+
+//Push queues msg at now and reports whether it was queued.
+//If the Queue is full, messages expired at now are dropped first, then messages are dropped according to the DropPolicy,
+//which may be msg itself.
+//It returns an error if msg can't be encoded to determine its size.
+func (q *Queue) Push(msg expiry.Message, now time.Time) (bool, error) {
+	e, err := newEntry(msg)
+	if err != nil {
+		return false, err
+	}
+	if q.opts.MaxBytes > 0 && e.size > q.opts.MaxBytes {
+		q.dropped++
+		return false, nil
+	}
+	if !q.fits(e) {
+		// 3.3.2.3.3 expired messages are discarded before anything that could still be delivered
+		q.dropExpired(now)
+	}
+
+	switch q.opts.Policy {
+	case DropOldest:
+		for !q.fits(e) {
+			q.removeAt(0)
+		}
+	case DropQoS0First:
+		if !q.fits(e) && msg.Publish.Qos == packet.Qos0 {
+			q.dropped++
+			return false, nil
+		}
+		for i := 0; i < len(q.msgs) && !q.fits(e); {
+			if q.msgs[i].msg.Publish.Qos == packet.Qos0 {
+				q.removeAt(i)
+				continue
+			}
+			i++
+		}
+	}
+	if !q.fits(e) {
+		q.dropped++
+		return false, nil
+	}
+	q.msgs = append(q.msgs, e)
+	q.bytes += e.size
+	return true, nil
+}
+
+//PushFront returns msg to the front of the Queue, e.g. because it couldn't be sent after Pop.
+//It isn't subject to the limits, msg was in the Queue before.
+func (q *Queue) PushFront(msg expiry.Message) error {
+	e, err := newEntry(msg)
+	if err != nil {
+		return err
+	}
+	q.msgs = append([]entry{e}, q.msgs...)
+	q.bytes += e.size
+	return nil
+}
+
+//Pop removes the next message that hasn't expired at now and reports whether there was one.
+//Expired messages are dropped on the way (3.3.2.3.3).
+func (q *Queue) Pop(now time.Time) (expiry.Message, bool) {
+	for len(q.msgs) > 0 {
+		e := q.msgs[0]
+		q.msgs[0] = entry{}
+		q.msgs = q.msgs[1:]
+		q.bytes -= e.size
+		if e.msg.Expired(now) {
+			q.dropped++
+			continue
+		}
+		return e.msg, true
+	}
+	return expiry.Message{}, false
+}
+
+//Len returns the number of queued messages, including the ones that have expired but weren't dropped yet.
+func (q *Queue) Len() int {
+	return len(q.msgs)
+}
+
+//Bytes returns the total size of the queued publish control packets.
+func (q *Queue) Bytes() int {
+	return q.bytes
+}
+
+//Dropped returns the number of messages dropped because the Queue was full or they expired.
+func (q *Queue) Dropped() uint64 {
+	return q.dropped
+}
+
+//Messages returns a copy of the queued messages in order, e.g. to save them.
+func (q *Queue) Messages() []expiry.Message {
+	if len(q.msgs) == 0 {
+		return nil
+	}
+	msgs := make([]expiry.Message, len(q.msgs))
+	for i, e := range q.msgs {
+		msgs[i] = e.msg
+	}
+	return msgs
+}
+
+//Restore queues saved messages in order at now, e.g. when a session is restored.
+//The limits of the Queue apply, as if the messages were pushed one by one.
+func (q *Queue) Restore(msgs []expiry.Message, now time.Time) error {
+	for _, msg := range msgs {
+		if _, err := q.Push(msg, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//newEntry returns the entry of msg with the size of its encoded publish control packet.
+func newEntry(msg expiry.Message) (entry, error) {
+	size, err := msg.Publish.WriteTo(ioutil.Discard)
+	if err != nil {
+		return entry{}, fmt.Errorf("failed to queue message: %v", err)
+	}
+	return entry{msg: msg, size: int(size)}, nil
+}
+
+//fits reports whether e can be queued without exceeding the limits.
+func (q *Queue) fits(e entry) bool {
+	if len(q.msgs) >= q.opts.MaxCount {
+		return false
+	}
+	return q.opts.MaxBytes <= 0 || q.bytes+e.size <= q.opts.MaxBytes
+}
+
+func (q *Queue) dropExpired(now time.Time) {
+	kept := q.msgs[:0]
+	for _, e := range q.msgs {
+		if e.msg.Expired(now) {
+			q.bytes -= e.size
+			q.dropped++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	for i := len(kept); i < len(q.msgs); i++ {
+		q.msgs[i] = entry{}
+	}
+	q.msgs = kept
+}
+
+func (q *Queue) removeAt(i int) {
+	q.bytes -= q.msgs[i].size
+	q.dropped++
+	copy(q.msgs[i:], q.msgs[i+1:])
+	q.msgs[len(q.msgs)-1] = entry{}
+	q.msgs = q.msgs[:len(q.msgs)-1]
+}
@@ -0,0 +1,218 @@
+package queue
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/expiry"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+var received = time.Unix(1000, 0)
+
+//msg returns a message received at received with payload and an optional message expiry interval in seconds.
+func msg(qosLevel byte, payload string, interval uint32) expiry.Message {
+	props := packet.NewProperties()
+	if interval > 0 {
+		props.Add(packet.NewProperty(packet.MessageExpiryInterval, packet.Int32PropPayload(interval)))
+	}
+	publish := packet.Publish{Qos: qosLevel, Topic: topic.Topic{Levels: []string{"t"}}, Props: props, Payload: []byte(payload)}
+	return expiry.Message{Publish: publish, Received: received}
+}
+
+func size(m expiry.Message) int {
+	n, _ := m.Publish.WriteTo(ioutil.Discard)
+	return int(n)
+}
+
+//payloads drains q at now and returns the payloads of the messages.
+func payloads(q *Queue, now time.Time) []string {
+	var got []string
+	for {
+		m, ok := q.Pop(now)
+		if !ok {
+			return got
+		}
+		got = append(got, string(m.Publish.Payload))
+	}
+}
+
+func TestQueuePolicies(t *testing.T) {
+	oneByteSize := size(msg(packet.Qos1, "1", 0))
+	tests := []struct {
+		name  string
+		opts  Options
+		push  []expiry.Message
+		want  []string
+		drops uint64
+	}{
+		{
+			name: "newest",
+			opts: Options{MaxCount: 2, Policy: DropNewest},
+			push: []expiry.Message{msg(packet.Qos1, "1", 0), msg(packet.Qos1, "2", 0), msg(packet.Qos1, "3", 0)},
+			want: []string{"1", "2"}, drops: 1,
+		},
+		{
+			name: "oldest",
+			opts: Options{MaxCount: 2, Policy: DropOldest},
+			push: []expiry.Message{msg(packet.Qos1, "1", 0), msg(packet.Qos1, "2", 0), msg(packet.Qos1, "3", 0)},
+			want: []string{"2", "3"}, drops: 1,
+		},
+		{
+			name: "qos 0 first",
+			opts: Options{MaxCount: 3, Policy: DropQoS0First},
+			push: []expiry.Message{msg(packet.Qos1, "1", 0), msg(packet.Qos0, "2", 0), msg(packet.Qos0, "3", 0), msg(packet.Qos2, "4", 0)},
+			want: []string{"1", "3", "4"}, drops: 1,
+		},
+		{
+			name: "qos 0 first drops new qos 0",
+			opts: Options{MaxCount: 2, Policy: DropQoS0First},
+			push: []expiry.Message{msg(packet.Qos0, "1", 0), msg(packet.Qos1, "2", 0), msg(packet.Qos0, "3", 0)},
+			want: []string{"1", "2"}, drops: 1,
+		},
+		{
+			name: "qos 0 first without qos 0",
+			opts: Options{MaxCount: 2, Policy: DropQoS0First},
+			push: []expiry.Message{msg(packet.Qos1, "1", 0), msg(packet.Qos1, "2", 0), msg(packet.Qos1, "3", 0)},
+			want: []string{"1", "2"}, drops: 1,
+		},
+		{
+			name: "bytes",
+			opts: Options{MaxBytes: 2*oneByteSize + 1, Policy: DropOldest},
+			push: []expiry.Message{msg(packet.Qos1, "1", 0), msg(packet.Qos1, "2", 0), msg(packet.Qos1, "34", 0)},
+			want: []string{"2", "34"}, drops: 1,
+		},
+		{
+			name: "larger than max bytes",
+			opts: Options{MaxBytes: oneByteSize, Policy: DropOldest},
+			push: []expiry.Message{msg(packet.Qos1, "1", 0), msg(packet.Qos1, "23", 0)},
+			want: []string{"1"}, drops: 1,
+		},
+		{
+			name: "expired dropped before policy",
+			opts: Options{MaxCount: 2, Policy: DropNewest},
+			push: []expiry.Message{msg(packet.Qos1, "1", 0), {Publish: msg(packet.Qos1, "2", 5).Publish, Received: received.Add(-time.Minute)}, msg(packet.Qos1, "3", 0)},
+			want: []string{"1", "3"}, drops: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := New(tt.opts)
+			for _, m := range tt.push {
+				q.Push(m, received)
+			}
+			if got := payloads(q, received); deep.Equal(got, tt.want) != nil {
+				t.Errorf("queued %v, want %v", got, tt.want)
+			}
+			if q.Dropped() != tt.drops {
+				t.Errorf("Dropped() = %d, want %d", q.Dropped(), tt.drops)
+			}
+			if q.Len() != 0 || q.Bytes() != 0 {
+				t.Errorf("Len() = %d, Bytes() = %d after draining, want 0", q.Len(), q.Bytes())
+			}
+		})
+	}
+}
+
+func TestQueueSkipsExpired(t *testing.T) {
+	q := New(Options{})
+	q.Push(msg(packet.Qos1, "expires", 10), received)
+	q.Push(msg(packet.Qos1, "stays", 0), received)
+	q.Push(msg(packet.Qos1, "lasts", 60), received)
+
+	if got, want := payloads(q, received.Add(10*time.Second)), []string{"stays", "lasts"}; deep.Equal(got, want) != nil {
+		t.Errorf("drained %v, want %v", got, want)
+	}
+	if q.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", q.Dropped())
+	}
+}
+
+func TestQueuePushDropsExpiredAtNow(t *testing.T) {
+	q := New(Options{MaxCount: 2, Policy: DropNewest})
+	q.Push(msg(packet.Qos1, "1", 0), received)
+	q.Push(msg(packet.Qos1, "2", 5), received)
+
+	// the new message was received as long ago as the others, e.g. when it is restored,
+	// whether messages have expired depends on the current time only
+	now := received.Add(10 * time.Second)
+	if queued, err := q.Push(msg(packet.Qos1, "3", 0), now); err != nil || !queued {
+		t.Fatalf("Push() = %v, %v, want true", queued, err)
+	}
+	if got, want := payloads(q, now), []string{"1", "3"}; deep.Equal(got, want) != nil {
+		t.Errorf("drained %v, want %v", got, want)
+	}
+}
+
+func TestQueuePushInvalid(t *testing.T) {
+	q := New(Options{})
+	invalid := msg(packet.Qos1, "invalid", 0)
+	invalid.Publish.Topic = topic.Topic{Levels: []string{strings.Repeat("t", 1<<16)}}
+
+	if queued, err := q.Push(invalid, received); err == nil || queued {
+		t.Errorf("Push() of message that can't be encoded = %v, %v, want error", queued, err)
+	}
+	if err := q.PushFront(invalid); err == nil {
+		t.Error("PushFront() of message that can't be encoded succeeded")
+	}
+	if err := q.Restore([]expiry.Message{msg(packet.Qos1, "1", 0), invalid}, received); err == nil {
+		t.Error("Restore() of message that can't be encoded succeeded")
+	}
+	if got, want := payloads(q, received), []string{"1"}; deep.Equal(got, want) != nil {
+		t.Errorf("drained %v, want %v", got, want)
+	}
+}
+
+func TestQueuePushFront(t *testing.T) {
+	q := New(Options{MaxCount: 1})
+	q.Push(msg(packet.Qos1, "1", 0), received)
+	m, _ := q.Pop(received)
+	q.Push(msg(packet.Qos1, "2", 0), received)
+	if err := q.PushFront(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := payloads(q, received), []string{"1", "2"}; deep.Equal(got, want) != nil {
+		t.Errorf("drained %v, want %v", got, want)
+	}
+}
+
+func TestQueueRestore(t *testing.T) {
+	q := New(Options{})
+	if msgs := q.Messages(); msgs != nil {
+		t.Errorf("Messages() of empty queue = %v, want nil", msgs)
+	}
+	saved := []expiry.Message{msg(packet.Qos1, "1", 0), msg(packet.Qos2, "2", 30), msg(packet.Qos1, "3", 0)}
+	for _, m := range saved {
+		q.Push(m, received)
+	}
+	if diff := deep.Equal(q.Messages(), saved); diff != nil {
+		t.Error(diff)
+	}
+
+	restored := New(Options{MaxCount: 2, Policy: DropOldest})
+	if err := restored.Restore(q.Messages(), received); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(restored.Messages(), saved[1:]); diff != nil {
+		t.Error(diff)
+	}
+	if restored.Bytes() != size(saved[1])+size(saved[2]) {
+		t.Errorf("Bytes() = %d, want %d", restored.Bytes(), size(saved[1])+size(saved[2]))
+	}
+}
+
+func TestParseDropPolicy(t *testing.T) {
+	for _, p := range []DropPolicy{DropNewest, DropOldest, DropQoS0First} {
+		if got, err := ParseDropPolicy(p.String()); err != nil || got != p {
+			t.Errorf("ParseDropPolicy(%q) = %v, %v, want %v", p.String(), got, err, p)
+		}
+	}
+	if _, err := ParseDropPolicy("random"); err == nil {
+		t.Error("ParseDropPolicy() of unknown policy succeeded")
+	}
+}
@@ -0,0 +1,6 @@
+package retain
+
+/*
+Package retain implements the handling of retained messages.
+Retained messages are kept in a pluggable Storage, an in memory implementation is provided.
+*/
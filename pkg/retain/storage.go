@@ -0,0 +1,68 @@
+package retain
+
+import (
+	"sync"
+
+	"github.com/squ94wk/mqtt-common/pkg/expiry"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//Message is a retained application message together with the time it was received.
+type Message = expiry.Message
+
+//Storage is the interface a backend for retained messages has to implement.
+//There is at most one retained message per topic name.
+type Storage interface {
+	//Set stores msg, replacing the retained message with the same topic name.
+	Set(msg Message) error
+	//Delete removes the retained message for t, if any.
+	Delete(t topic.Topic) error
+	//Match returns all retained messages whose topic name matches filter.
+	Match(filter topic.Filter) ([]Message, error)
+}
+
+//MemoryStorage is an in memory implementation of Storage.
+//It is safe for concurrent use.
+type MemoryStorage struct {
+	mu       sync.RWMutex
+	messages map[string]Message
+}
+
+//NewMemoryStorage is the constructor of the MemoryStorage type.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		messages: make(map[string]Message),
+	}
+}
+
+//Set implements Storage.
+func (s *MemoryStorage) Set(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages[msg.Publish.Topic.String()] = msg
+	return nil
+}
+
+//Delete implements Storage.
+func (s *MemoryStorage) Delete(t topic.Topic) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.messages, t.String())
+	return nil
+}
+
+//Match implements Storage.
+func (s *MemoryStorage) Match(filter topic.Filter) ([]Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var msgs []Message
+	for _, msg := range s.messages {
+		if filter.Matches(msg.Publish.Topic) {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, nil
+}
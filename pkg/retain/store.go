@@ -0,0 +1,109 @@
+package retain
+
+import (
+	"fmt"
+
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//Store handles retained messages as described in 3.3.1.3 on top of a Storage.
+type Store struct {
+	storage Storage
+	clk     clock.Clock
+}
+
+//NewStore is the constructor of the Store type.
+//Clk tells the time retained messages are received and looked up, to expire them (3.3.2.3.3).
+func NewStore(storage Storage, clk clock.Clock) *Store {
+	return &Store{
+		storage: storage,
+		clk:     clk,
+	}
+}
+
+//Retain sets or clears the retained message for the topic of publish.
+//Publish packets without the retain flag are ignored.
+//A retained message with an empty payload deletes the retained message for its topic and is not stored itself.
+func (s *Store) Retain(publish packet.Publish) error {
+	if !publish.Retain {
+		return nil
+	}
+
+	if len(publish.Payload) == 0 {
+		if err := s.storage.Delete(publish.Topic); err != nil {
+			return fmt.Errorf("failed to delete retained message for topic '%s': %v", publish.Topic, err)
+		}
+		return nil
+	}
+
+	msg := Message{Publish: publish, Received: s.clk.Now()}
+	if err := s.storage.Set(msg); err != nil {
+		return fmt.Errorf("failed to store retained message for topic '%s': %v", publish.Topic, err)
+	}
+	return nil
+}
+
+//Lookup returns all retained messages whose topic matches filter.
+//Expired messages are removed from the storage instead of being returned (3.3.2.3.3).
+//The message expiry interval of the returned messages is reduced by the time they were retained.
+func (s *Store) Lookup(filter topic.Filter) ([]packet.Publish, error) {
+	msgs, err := s.storage.Match(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up retained messages for filter '%s': %v", filter, err)
+	}
+
+	now := s.clk.Now()
+	var publishes []packet.Publish
+	for _, msg := range msgs {
+		publish, ok := msg.Outbound(now)
+		if !ok {
+			if err := s.storage.Delete(msg.Publish.Topic); err != nil {
+				return nil, fmt.Errorf("failed to delete expired retained message for topic '%s': %v", msg.Publish.Topic, err)
+			}
+			continue
+		}
+		publishes = append(publishes, publish)
+	}
+	return publishes, nil
+}
+
+//Subscribe returns the retained messages that have to be sent for a subscription according to its retain handling (3.8.3.1).
+//Existed reports whether the subscription already existed before it was made.
+//The retain flag of the returned messages is set and their QoS is limited to the maximum QoS of the subscription.
+//Retained messages are not sent for shared subscriptions (4.8.2).
+func (s *Store) Subscribe(sub packet.SubscriptionFilter, existed bool) ([]packet.Publish, error) {
+	switch sub.RetainHandling {
+	case packet.RetainHandlingAlways:
+	case packet.RetainHandlingIfNotPresent:
+		if existed {
+			return nil, nil
+		}
+	case packet.RetainHandlingNever:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("protocol error: invalid retain handling '%d'", sub.RetainHandling)
+	}
+
+	filter, err := topic.ParseFilter(sub.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up retained messages: %v", err)
+	}
+	if filter.IsShared() {
+		return nil, nil
+	}
+
+	publishes, err := s.Lookup(filter)
+	if err != nil {
+		return nil, err
+	}
+	for i := range publishes {
+		publishes[i].Retain = true
+		publishes[i].Dup = false
+		if publishes[i].Qos > sub.MaxQoS {
+			publishes[i].Qos = sub.MaxQoS
+		}
+	}
+	return publishes, nil
+}
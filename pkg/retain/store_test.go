@@ -0,0 +1,185 @@
+package retain
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/clock"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+func retained(topicName string, payload string, props ...packet.Property) packet.Publish {
+	t, err := topic.ParseTopic(topicName)
+	if err != nil {
+		panic(err)
+	}
+	return packet.Publish{
+		Qos:      packet.Qos1,
+		Retain:   true,
+		Topic:    t,
+		PacketID: 1,
+		Props:    packet.NewProperties(props...),
+		Payload:  []byte(payload),
+	}
+}
+
+func topics(publishes []packet.Publish) []string {
+	got := make([]string, len(publishes))
+	for i, p := range publishes {
+		got[i] = p.Topic.String() + "=" + string(p.Payload)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestStoreRetain(t *testing.T) {
+	store := NewStore(NewMemoryStorage(), clock.NewFake(time.Unix(0, 0)))
+	steps := []packet.Publish{
+		retained("a/b", "1"),
+		retained("a/c", "2"),
+		retained("b", "3"),
+		retained("a/b", "4"),
+		retained("b", ""),
+		{Topic: topic.Topic{Levels: []string{"a", "d"}}, Payload: []byte("not retained")},
+	}
+	for _, p := range steps {
+		if err := store.Retain(p); err != nil {
+			t.Fatalf("Retain() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{filter: "#", want: []string{"a/b=4", "a/c=2"}},
+		{filter: "a/+", want: []string{"a/b=4", "a/c=2"}},
+		{filter: "a/c", want: []string{"a/c=2"}},
+		{filter: "b", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filter, _ := topic.ParseFilter(tt.filter)
+			got, err := store.Lookup(filter)
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if diff := deep.Equal(topics(got), tt.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestStoreMessageExpiry(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	store := NewStore(NewMemoryStorage(), clk)
+
+	err := store.Retain(retained("a", "1",
+		packet.NewProperty(packet.MessageExpiryInterval, packet.Int32PropPayload(10)),
+		packet.NewProperty(packet.ContentType, packet.StringPropPayload("text/plain")),
+	))
+	if err != nil {
+		t.Fatalf("Retain() error = %v", err)
+	}
+	filter, _ := topic.ParseFilter("a")
+
+	clk.Advance(4 * time.Second)
+	got, err := store.Lookup(filter)
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	want := []packet.Publish{retained("a", "1",
+		packet.NewProperty(packet.MessageExpiryInterval, packet.Int32PropPayload(6)),
+		packet.NewProperty(packet.ContentType, packet.StringPropPayload("text/plain")),
+	)}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+
+	clk.Advance(6 * time.Second)
+	got, err = store.Lookup(filter)
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Lookup() = %v, want expired message to be dropped", got)
+	}
+	if msgs, _ := store.storage.Match(filter); len(msgs) != 0 {
+		t.Errorf("expired message is still stored: %v", msgs)
+	}
+}
+
+func TestStoreSubscribe(t *testing.T) {
+	store := NewStore(NewMemoryStorage(), clock.NewFake(time.Unix(0, 0)))
+	if err := store.Retain(retained("a", "1")); err != nil {
+		t.Fatalf("Retain() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		sub     packet.SubscriptionFilter
+		existed bool
+		want    []packet.Publish
+		wantErr bool
+	}{
+		{
+			name: "retain handling 0",
+			sub:  packet.SubscriptionFilter{Filter: "#", MaxQoS: packet.Qos2, RetainHandling: packet.RetainHandlingAlways},
+			want: []packet.Publish{retained("a", "1")},
+		},
+		{
+			name:    "retain handling 0 on existing subscription",
+			sub:     packet.SubscriptionFilter{Filter: "#", MaxQoS: packet.Qos2, RetainHandling: packet.RetainHandlingAlways},
+			existed: true,
+			want:    []packet.Publish{retained("a", "1")},
+		},
+		{
+			name: "retain handling 1",
+			sub:  packet.SubscriptionFilter{Filter: "#", MaxQoS: packet.Qos2, RetainHandling: packet.RetainHandlingIfNotPresent},
+			want: []packet.Publish{retained("a", "1")},
+		},
+		{
+			name:    "retain handling 1 on existing subscription",
+			sub:     packet.SubscriptionFilter{Filter: "#", MaxQoS: packet.Qos2, RetainHandling: packet.RetainHandlingIfNotPresent},
+			existed: true,
+		},
+		{
+			name: "retain handling 2",
+			sub:  packet.SubscriptionFilter{Filter: "#", MaxQoS: packet.Qos2, RetainHandling: packet.RetainHandlingNever},
+		},
+		{
+			name: "QoS is limited to maximum QoS",
+			sub:  packet.SubscriptionFilter{Filter: "a", MaxQoS: packet.Qos0},
+			want: []packet.Publish{func() packet.Publish {
+				p := retained("a", "1")
+				p.Qos = packet.Qos0
+				return p
+			}()},
+		},
+		{
+			name: "shared subscription",
+			sub:  packet.SubscriptionFilter{Filter: "$share/g/#", MaxQoS: packet.Qos2},
+		},
+		{
+			name:    "invalid retain handling => err",
+			sub:     packet.SubscriptionFilter{Filter: "#", RetainHandling: 3},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Subscribe(tt.sub, tt.existed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Subscribe() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
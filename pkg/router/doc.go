@@ -0,0 +1,7 @@
+package router
+
+/*
+Package router dispatches received application messages to the handlers registered for matching topic filters.
+Messages carrying subscription identifiers (3.3.2.3.8) are routed by identifier, others by matching the topic filters.
+Middleware wraps every handler, e.g. to recover from panics or to record metrics.
+*/
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//Recover returns a Middleware that recovers from panics of the handlers it wraps.
+//OnPanic is called with the message and the value passed to panic.
+func Recover(onPanic func(publish packet.Publish, v interface{})) Middleware {
+	return func(next Handler) Handler {
+		return func(publish packet.Publish) {
+			defer func() {
+				if v := recover(); v != nil {
+					onPanic(publish, v)
+				}
+			}()
+			next(publish)
+		}
+	}
+}
+
+//Instrument returns a Middleware that calls observe with every message and the time its handler took, e.g. to record metrics.
+func Instrument(observe func(publish packet.Publish, d time.Duration)) Middleware {
+	return func(next Handler) Handler {
+		return func(publish packet.Publish) {
+			start := time.Now()
+			next(publish)
+			observe(publish, time.Since(start))
+		}
+	}
+}
+
+//Decoder decodes the payload of a message.
+type Decoder func(payload []byte) ([]byte, error)
+
+//Decode returns a Middleware that decodes the payload of messages by their ContentType property (3.3.2.3.9), e.g. to decompress it.
+//Messages with a content type missing in decoders are passed on unchanged.
+//If decoding fails, onError is called and the message is dropped.
+func Decode(decoders map[string]Decoder, onError func(publish packet.Publish, err error)) Middleware {
+	return func(next Handler) Handler {
+		return func(publish packet.Publish) {
+			contentType, ok := publish.Props.StringProp(packet.ContentType)
+			if !ok {
+				next(publish)
+				return
+			}
+			decode, ok := decoders[contentType]
+			if !ok {
+				next(publish)
+				return
+			}
+			payload, err := decode(publish.Payload)
+			if err != nil {
+				onError(publish, err)
+				return
+			}
+			publish.Payload = payload
+			next(publish)
+		}
+	}
+}
+
+//Chain wraps handler in middleware, e.g. to use middleware for a single filter.
+//The first middleware is the outermost one.
+func Chain(handler Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
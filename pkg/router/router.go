@@ -0,0 +1,169 @@
+package router
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+//maxSubscriptionID is the highest subscription identifier (3.8.2.1.2).
+const maxSubscriptionID = 268435455
+
+//ErrSubscriptionIDsExhausted is returned by Handle if all subscription identifiers are in use.
+var ErrSubscriptionIDsExhausted = errors.New("router: all subscription identifiers are in use")
+
+//Handler handles an application message.
+type Handler func(publish packet.Publish)
+
+//Middleware wraps a handler, e.g. to add behavior before and after it.
+type Middleware func(next Handler) Handler
+
+//Router dispatches application messages to the handlers of matching topic filters.
+//Each filter is assigned a subscription identifier that has to be sent in the subscribe control packet for the filter.
+//Messages that carry subscription identifiers known to the Router are dispatched by identifier without matching any filters,
+//all others are matched against every registered filter.
+//It is safe for concurrent use.
+type Router struct {
+	//NotFound handles messages no registered filter matches, they are dropped if it is nil.
+	NotFound Handler
+
+	mu         sync.RWMutex
+	byID       map[uint32]*route
+	byFilter   map[string]*route
+	nextID     uint32
+	middleware []Middleware
+}
+
+//route is a registered handler.
+type route struct {
+	filter  topic.Filter
+	id      uint32
+	handler Handler
+}
+
+//New is the constructor of the Router type.
+func New() *Router {
+	return &Router{
+		byID:     make(map[uint32]*route),
+		byFilter: make(map[string]*route),
+		nextID:   1,
+	}
+}
+
+//Use appends middleware to the chain wrapping every handler.
+//The first middleware is the outermost one.
+func (r *Router) Use(middleware ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.middleware = append(r.middleware, middleware...)
+}
+
+//Handle registers handler for filter and returns the subscription identifier of filter.
+//A handler registered before for the same filter is replaced, the subscription identifier is kept.
+func (r *Router) Handle(filter topic.Filter, handler Handler) (uint32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := filter.String()
+	if rt, ok := r.byFilter[key]; ok {
+		rt.handler = handler
+		return rt.id, nil
+	}
+
+	id, err := r.acquireID()
+	if err != nil {
+		return 0, err
+	}
+	rt := &route{filter: filter, id: id, handler: handler}
+	r.byID[id] = rt
+	r.byFilter[key] = rt
+	return id, nil
+}
+
+//acquireID returns an unused subscription identifier; r.mu must be held.
+func (r *Router) acquireID() (uint32, error) {
+	if len(r.byID) == maxSubscriptionID {
+		return 0, ErrSubscriptionIDsExhausted
+	}
+	for {
+		id := r.nextID
+		r.nextID++
+		if r.nextID > maxSubscriptionID {
+			r.nextID = 1
+		}
+		if _, ok := r.byID[id]; !ok {
+			return id, nil
+		}
+	}
+}
+
+//Remove removes the handler of filter.
+//It reports whether a handler was registered.
+func (r *Router) Remove(filter topic.Filter) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := filter.String()
+	rt, ok := r.byFilter[key]
+	if !ok {
+		return false
+	}
+	delete(r.byFilter, key)
+	delete(r.byID, rt.id)
+	return true
+}
+
+//SubscriptionID returns the subscription identifier of filter.
+//It reports false if no handler is registered for filter.
+func (r *Router) SubscriptionID(filter topic.Filter) (uint32, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rt, ok := r.byFilter[filter.String()]
+	if !ok {
+		return 0, false
+	}
+	return rt.id, true
+}
+
+//Route dispatches publish to the handlers of all matching filters.
+//Its signature allows it to be used as handler of a client.
+func (r *Router) Route(publish packet.Publish) {
+	r.mu.RLock()
+	handlers := r.match(publish)
+	middleware := r.middleware
+	r.mu.RUnlock()
+
+	if len(handlers) == 0 && r.NotFound != nil {
+		handlers = append(handlers, r.NotFound)
+	}
+	for _, handler := range handlers {
+		Chain(handler, middleware...)(publish)
+	}
+}
+
+//match returns the handlers for publish; r.mu must be held.
+func (r *Router) match(publish packet.Publish) []Handler {
+	var handlers []Handler
+	if publish.Props != nil {
+		// 3.3.4-3 the server sends the identifiers of all matching subscriptions that have one
+		for _, id := range publish.Props.VarInt(packet.SubscriptionIdentifier) {
+			if rt, ok := r.byID[id]; ok {
+				handlers = append(handlers, rt.handler)
+			}
+		}
+		if len(handlers) > 0 {
+			return handlers
+		}
+	}
+
+	for _, rt := range r.byFilter {
+		if rt.filter.Matches(publish.Topic) {
+			handlers = append(handlers, rt.handler)
+		}
+	}
+	return handlers
+}
@@ -0,0 +1,244 @@
+package router
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+func mustFilter(t *testing.T, input string) topic.Filter {
+	t.Helper()
+	filter, err := topic.ParseFilter(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filter
+}
+
+func newPublish(t *testing.T, name string, ids ...uint32) packet.Publish {
+	t.Helper()
+	tpc, err := topic.ParseTopic(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := packet.NewProperties()
+	for _, id := range ids {
+		props.Add(packet.NewProperty(packet.SubscriptionIdentifier, packet.VarIntPropPayload(id)))
+	}
+	return packet.Publish{Topic: tpc, Props: props}
+}
+
+//recorder registers a handler per filter that records the filter.
+type recorder struct {
+	router *Router
+	ids    map[string]uint32
+	got    []string
+}
+
+func newRecorder(t *testing.T, filters ...string) *recorder {
+	rec := &recorder{router: New(), ids: make(map[string]uint32)}
+	for _, filter := range filters {
+		filter := filter
+		id, err := rec.router.Handle(mustFilter(t, filter), func(packet.Publish) {
+			rec.got = append(rec.got, filter)
+		})
+		if err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+		rec.ids[filter] = id
+	}
+	return rec
+}
+
+func (r *recorder) route(publish packet.Publish) []string {
+	r.got = nil
+	r.router.Route(publish)
+	sort.Strings(r.got)
+	return r.got
+}
+
+func TestRoute(t *testing.T) {
+	filters := []string{"a/b", "a/+", "a/#", "$share/g/a/b", "b/#"}
+	tests := []struct {
+		name  string
+		topic string
+		ids   []string
+		want  []string
+	}{
+		{name: "wildcards", topic: "a/b", want: []string{"$share/g/a/b", "a/#", "a/+", "a/b"}},
+		{name: "parent level", topic: "a", want: []string{"a/#"}},
+		{name: "no match", topic: "c"},
+		{name: "by identifier", topic: "a/b", ids: []string{"a/+"}, want: []string{"a/+"}},
+		{name: "by identifiers", topic: "a/b", ids: []string{"a/#", "a/b"}, want: []string{"a/#", "a/b"}},
+		// identifiers take precedence over the topic
+		{name: "identifier only", topic: "x", ids: []string{"b/#"}, want: []string{"b/#"}},
+	}
+
+	rec := newRecorder(t, filters...)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []uint32
+			for _, filter := range tt.ids {
+				ids = append(ids, rec.ids[filter])
+			}
+			if diff := deep.Equal(rec.route(newPublish(t, tt.topic, ids...)), tt.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+
+	t.Run("unknown identifier", func(t *testing.T) {
+		if diff := deep.Equal(rec.route(newPublish(t, "b/c", 1000)), []string{"b/#"}); diff != nil {
+			t.Error(diff)
+		}
+	})
+}
+
+func TestHandleRemove(t *testing.T) {
+	rec := newRecorder(t, "a", "b")
+	if rec.ids["a"] == rec.ids["b"] || rec.ids["a"] == 0 || rec.ids["b"] == 0 {
+		t.Fatalf("subscription identifiers = %v", rec.ids)
+	}
+
+	replaced := false
+	id, err := rec.router.Handle(mustFilter(t, "a"), func(packet.Publish) { replaced = true })
+	if err != nil || id != rec.ids["a"] {
+		t.Errorf("Handle() = %d, %v, want %d, nil", id, err, rec.ids["a"])
+	}
+	rec.router.Route(newPublish(t, "a"))
+	if !replaced {
+		t.Error("replaced handler not called")
+	}
+
+	if !rec.router.Remove(mustFilter(t, "b")) {
+		t.Error("Remove() = false")
+	}
+	if rec.router.Remove(mustFilter(t, "b")) {
+		t.Error("second Remove() = true")
+	}
+	if _, ok := rec.router.SubscriptionID(mustFilter(t, "b")); ok {
+		t.Error("SubscriptionID() of removed filter reported true")
+	}
+	if got := rec.route(newPublish(t, "b", rec.ids["b"])); len(got) != 0 {
+		t.Errorf("removed handler called: %v", got)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	rec := newRecorder(t, "a")
+	var notFound []string
+	rec.router.NotFound = func(publish packet.Publish) {
+		notFound = append(notFound, publish.Topic.String())
+	}
+
+	rec.route(newPublish(t, "a"))
+	rec.route(newPublish(t, "b"))
+	if diff := deep.Equal(notFound, []string{"b"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r := New()
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(publish packet.Publish) {
+				calls = append(calls, name+" before")
+				next(publish)
+				calls = append(calls, name+" after")
+			}
+		}
+	}
+	r.Use(trace("outer"), trace("inner"))
+	if _, err := r.Handle(mustFilter(t, "a"), func(packet.Publish) { calls = append(calls, "handler") }); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Route(newPublish(t, "a"))
+	want := []string{"outer before", "inner before", "handler", "inner after", "outer after"}
+	if diff := deep.Equal(calls, want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	r := New()
+	var recovered interface{}
+	r.Use(Recover(func(publish packet.Publish, v interface{}) { recovered = v }))
+	if _, err := r.Handle(mustFilter(t, "a"), func(packet.Publish) { panic("boom") }); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Route(newPublish(t, "a"))
+	if recovered != "boom" {
+		t.Errorf("recovered %v, want boom", recovered)
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	r := New()
+	var observed []string
+	r.Use(Instrument(func(publish packet.Publish, d time.Duration) {
+		observed = append(observed, publish.Topic.String())
+	}))
+	if _, err := r.Handle(mustFilter(t, "a/+"), func(packet.Publish) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Route(newPublish(t, "a/b"))
+	r.Route(newPublish(t, "a/c"))
+	if diff := deep.Equal(observed, []string{"a/b", "a/c"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	errDecode := errors.New("invalid payload")
+	decode := Decode(map[string]Decoder{
+		"text/upper": func(payload []byte) ([]byte, error) {
+			if len(payload) == 0 {
+				return nil, errDecode
+			}
+			return []byte(strings.ToUpper(string(payload))), nil
+		},
+	}, func(publish packet.Publish, err error) {
+		if err != errDecode {
+			t.Errorf("onError() with %v, want %v", err, errDecode)
+		}
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		payload     string
+		want        []string
+	}{
+		{name: "decoded", contentType: "text/upper", payload: "abc", want: []string{"ABC"}},
+		{name: "unknown content type", contentType: "text/plain", payload: "abc", want: []string{"abc"}},
+		{name: "no content type", payload: "abc", want: []string{"abc"}},
+		{name: "failed", contentType: "text/upper", payload: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			handler := Chain(func(publish packet.Publish) { got = append(got, string(publish.Payload)) }, decode)
+
+			publish := newPublish(t, "a")
+			publish.Payload = []byte(tt.payload)
+			if tt.contentType != "" {
+				publish.Props.Add(packet.NewProperty(packet.ContentType, packet.StringPropPayload(tt.contentType)))
+			}
+			handler(publish)
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
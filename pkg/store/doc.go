@@ -0,0 +1,8 @@
+package store
+
+/*
+Package store persists the state of sessions, so they survive a restart of the server (4.1).
+A SessionStore keeps subscriptions, messages in flight and queued messages per client identifier.
+An in memory implementation and an append-only log in a file are provided,
+the records of the log are serialized with the encoding of the control packets.
+*/
@@ -0,0 +1,285 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+//minGarbage is the number of superseded records from which on the log is compacted,
+//once they also outnumber the stored sessions.
+const minGarbage = 1024
+
+//ErrClosed is returned by a FileStore after Close.
+var ErrClosed = errors.New("store: file store closed")
+
+//FileStore is an implementation of SessionStore that appends every change as a record to a log file.
+//The sessions are also kept in memory, the log is only read when it is opened.
+//It is safe for concurrent use.
+type FileStore struct {
+	path string
+
+	mu       sync.Mutex
+	file     logFile
+	sessions map[string]Session
+	// size is the size of the log up to the end of the last complete record
+	size int64
+	// garbage is the number of records in the log superseded by later ones
+	garbage int
+	// failed is set if a partially written record couldn't be removed from the log
+	failed error
+}
+
+//logFile is the log file records are appended to.
+type logFile interface {
+	io.WriteCloser
+	Sync() error
+	Truncate(size int64) error
+}
+
+//OpenFileStore opens the log at path, creating it if it doesn't exist, and recovers the sessions stored in it.
+//An incomplete or corrupt last record, e.g. one that was written partially when the process crashed, is dropped.
+//Any other unreadable record fails OpenFileStore without modifying the log, the sessions following it would be lost otherwise.
+//A log that isn't of the current format fails OpenFileStore.
+//The log is compacted after recovering the sessions.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		path:     path,
+		sessions: make(map[string]Session),
+	}
+
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReader(f)
+	headerSize, err := readLogHeader(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("store: failed to read '%s': %w", path, err)
+	}
+	reader := &countingReader{reader: buffered, n: headerSize}
+	for {
+		start := reader.n
+		rec, err := readFrame(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if torn(err, reader.n, info.Size()) {
+				// the torn record is dropped by the compaction
+				break
+			}
+			return nil, fmt.Errorf("store: failed to read record at offset %d of '%s': %w", start, path, err)
+		}
+		s.apply(rec)
+	}
+
+	if err := s.compactLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//torn reports whether err is caused by the last record of a log of size bytes being written partially.
+//Such a record is incomplete, or its checksum doesn't match and the log ends right after it at offset end.
+//A length exceeding maxFrameSize can't be the result of a partial write and is never considered torn.
+func torn(err error, end, size int64) bool {
+	switch err {
+	case io.ErrUnexpectedEOF:
+		return true
+	case errCorrupt:
+		return end == size
+	default:
+		return false
+	}
+}
+
+//countingReader counts the bytes read from reader.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+//apply applies rec to the sessions in memory; s.mu must be held unless s isn't shared yet.
+func (s *FileStore) apply(rec record) {
+	clientID := rec.session.ClientID
+	if _, ok := s.sessions[clientID]; ok {
+		s.garbage++
+	}
+	switch rec.kind {
+	case recordSave:
+		s.sessions[clientID] = rec.session
+	case recordDelete:
+		delete(s.sessions, clientID)
+		// the delete record itself becomes garbage once compacted
+		s.garbage++
+	}
+}
+
+//Save implements SessionStore.
+func (s *FileStore) Save(sess Session) error {
+	return s.append(record{kind: recordSave, session: sess})
+}
+
+//Delete implements SessionStore.
+func (s *FileStore) Delete(clientID string) error {
+	s.mu.Lock()
+	_, ok := s.sessions[clientID]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return s.append(record{kind: recordDelete, session: Session{ClientID: clientID}})
+}
+
+//Load implements SessionStore.
+func (s *FileStore) Load() ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sorted(s.sessions), nil
+}
+
+//append writes rec to the log and applies it.
+//If rec can't be written completely, the log is truncated to the end of the previous record.
+//If that fails as well, the log is unusable and every further change fails until it is compacted successfully.
+func (s *FileStore) append(rec record) error {
+	var buf bytes.Buffer
+	if err := writeFrame(&buf, rec); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return ErrClosed
+	}
+	if s.failed != nil {
+		return s.failed
+	}
+	if err := s.write(buf.Bytes()); err != nil {
+		// records appended after a partial one would be unreadable
+		if truncErr := s.file.Truncate(s.size); truncErr != nil {
+			s.failed = fmt.Errorf("store: log '%s' is unusable, failed to remove partial record: %v", s.path, truncErr)
+		}
+		return err
+	}
+	s.size += int64(buf.Len())
+	s.apply(rec)
+
+	if s.garbage >= minGarbage && s.garbage > len(s.sessions) {
+		return s.compactLocked()
+	}
+	return nil
+}
+
+//write writes frame to the log and syncs it; s.mu must be held.
+func (s *FileStore) write(frame []byte) error {
+	if _, err := s.file.Write(frame); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+//Compact rewrites the log with a single record per stored session.
+//The new log replaces the old one atomically, so the sessions survive if the process crashes while compacting.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return ErrClosed
+	}
+	return s.compactLocked()
+}
+
+//compactLocked rewrites the log and reopens it for appending; s.mu must be held.
+func (s *FileStore) compactLocked() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	size, err := writeSessions(tmp, sorted(s.sessions))
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	s.file = f
+	s.size = size
+	s.garbage = 0
+	s.failed = nil
+	return nil
+}
+
+//writeSessions writes the header of the log and a save record per session to f, syncs it and returns its size.
+func writeSessions(f *os.File, sessions []Session) (int64, error) {
+	writer := bufio.NewWriter(f)
+	size, err := writeLogHeader(writer)
+	if err != nil {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	for _, sess := range sessions {
+		buf.Reset()
+		if err := writeFrame(&buf, record{kind: recordSave, session: sess}); err != nil {
+			return 0, err
+		}
+		n, err := buf.WriteTo(writer)
+		size += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return 0, err
+	}
+	return size, f.Sync()
+}
+
+//Close closes the log file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return ErrClosed
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,408 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func tempLog(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "sessions.log"), func() { os.RemoveAll(dir) }
+}
+
+func mustOpen(t *testing.T, path string) *FileStore {
+	t.Helper()
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore() error = %v", err)
+	}
+	return s
+}
+
+func mustLoad(t *testing.T, s SessionStore) []Session {
+	t.Helper()
+	sessions, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return sessions
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestFileStoreRecovery(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	s := mustOpen(t, path)
+	a, b := testSession(t, "a"), testSession(t, "b")
+	for _, sess := range []Session{a, b, {ClientID: "c", Expiry: 1}} {
+		if err := s.Save(sess); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	b.Queue = nil
+	if err := s.Save(b); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := s.Delete("c"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := s.Save(a); err != ErrClosed {
+		t.Errorf("Save() after Close() error = %v, want %v", err, ErrClosed)
+	}
+
+	s = mustOpen(t, path)
+	defer s.Close()
+	if diff := deep.Equal(mustLoad(t, s), []Session{a, b}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestFileStoreTornWrite(t *testing.T) {
+	a := testSession(t, "a")
+	tests := []struct {
+		name string
+		// corrupt corrupts the last record of the log at path, intact is the size of the log without it
+		corrupt func(t *testing.T, path string, intact int64)
+	}{
+		{name: "truncated record", corrupt: func(t *testing.T, path string, intact int64) {
+			if err := os.Truncate(path, fileSize(t, path)-3); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{name: "truncated header", corrupt: func(t *testing.T, path string, intact int64) {
+			if err := os.Truncate(path, intact+3); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{name: "corrupt record", corrupt: func(t *testing.T, path string, intact int64) {
+			f, err := os.OpenFile(path, os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.WriteAt([]byte{0xff}, fileSize(t, path)-1); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, cleanup := tempLog(t)
+			defer cleanup()
+
+			s := mustOpen(t, path)
+			if err := s.Save(a); err != nil {
+				t.Fatal(err)
+			}
+			intact := fileSize(t, path)
+			if err := s.Save(testSession(t, "b")); err != nil {
+				t.Fatal(err)
+			}
+			s.Close()
+			tt.corrupt(t, path, intact)
+
+			s = mustOpen(t, path)
+			defer s.Close()
+			if diff := deep.Equal(mustLoad(t, s), []Session{a}); diff != nil {
+				t.Error(diff)
+			}
+			if size := fileSize(t, path); size != intact {
+				t.Errorf("log size after recovery = %d, want %d", size, intact)
+			}
+			// the log can be appended to after the dropped record
+			if err := s.Save(Session{ClientID: "c"}); err != nil {
+				t.Fatal(err)
+			}
+			reopened := mustOpen(t, path)
+			defer reopened.Close()
+			if diff := deep.Equal(mustLoad(t, reopened), []Session{a, {ClientID: "c"}}); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+//shortWriteFile writes only part of the next frame and fails, optionally failing to truncate the log afterwards.
+type shortWriteFile struct {
+	logFile
+	short       bool
+	truncateErr error
+}
+
+var errShortWrite = errors.New("short write")
+
+func (f *shortWriteFile) Write(p []byte) (int, error) {
+	if !f.short {
+		return f.logFile.Write(p)
+	}
+	f.short = false
+	n, err := f.logFile.Write(p[:len(p)/2])
+	if err != nil {
+		return n, err
+	}
+	return n, errShortWrite
+}
+
+func (f *shortWriteFile) Truncate(size int64) error {
+	if f.truncateErr != nil {
+		return f.truncateErr
+	}
+	return f.logFile.Truncate(size)
+}
+
+func TestFileStoreShortWrite(t *testing.T) {
+	a, b := testSession(t, "a"), testSession(t, "b")
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	s := mustOpen(t, path)
+	defer s.Close()
+	if err := s.Save(a); err != nil {
+		t.Fatal(err)
+	}
+	intact := fileSize(t, path)
+	s.file = &shortWriteFile{logFile: s.file, short: true}
+	if err := s.Save(b); err != errShortWrite {
+		t.Fatalf("Save() error = %v, want %v", err, errShortWrite)
+	}
+	if size := fileSize(t, path); size != intact {
+		t.Errorf("log size after short write = %d, want %d", size, intact)
+	}
+	if diff := deep.Equal(mustLoad(t, s), []Session{a}); diff != nil {
+		t.Error(diff)
+	}
+
+	// records appended after the failed one are recovered
+	if err := s.Save(b); err != nil {
+		t.Fatal(err)
+	}
+	reopened := mustOpen(t, path)
+	defer reopened.Close()
+	if diff := deep.Equal(mustLoad(t, reopened), []Session{a, b}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestFileStoreShortWriteNotTruncated(t *testing.T) {
+	a, b := testSession(t, "a"), testSession(t, "b")
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	s := mustOpen(t, path)
+	defer s.Close()
+	if err := s.Save(a); err != nil {
+		t.Fatal(err)
+	}
+	s.file = &shortWriteFile{logFile: s.file, short: true, truncateErr: errors.New("truncate failed")}
+	if err := s.Save(b); err != errShortWrite {
+		t.Fatalf("Save() error = %v, want %v", err, errShortWrite)
+	}
+	size := fileSize(t, path)
+
+	// nothing is appended after the partial record
+	if err := s.Save(b); err == nil {
+		t.Error("Save() after failed truncate succeeded")
+	}
+	if err := s.Delete("a"); err == nil {
+		t.Error("Delete() after failed truncate succeeded")
+	}
+	if got := fileSize(t, path); got != size {
+		t.Errorf("log size = %d after failed changes, want %d", got, size)
+	}
+
+	// compacting rewrites the log from the sessions in memory
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(b); err != nil {
+		t.Fatalf("Save() after compaction error = %v", err)
+	}
+	reopened := mustOpen(t, path)
+	defer reopened.Close()
+	if diff := deep.Equal(mustLoad(t, reopened), []Session{a, b}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestFileStoreCorruptRecord(t *testing.T) {
+	tests := []struct {
+		name string
+		// corrupt corrupts the record at offset start of the log at path
+		corrupt func(t *testing.T, f *os.File, start int64)
+	}{
+		{name: "checksum mismatch", corrupt: func(t *testing.T, f *os.File, start int64) {
+			if _, err := f.WriteAt([]byte{0xff}, start+frameHeaderSize+1); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{name: "too large", corrupt: func(t *testing.T, f *os.File, start int64) {
+			if _, err := f.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, start); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, cleanup := tempLog(t)
+			defer cleanup()
+
+			s := mustOpen(t, path)
+			if err := s.Save(testSession(t, "a")); err != nil {
+				t.Fatal(err)
+			}
+			start := fileSize(t, path)
+			for _, clientID := range []string{"b", "c"} {
+				if err := s.Save(testSession(t, clientID)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s.Close()
+
+			f, err := os.OpenFile(path, os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.corrupt(t, f, start)
+			f.Close()
+			corrupted, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the session after the corrupt record must not be dropped silently
+			if s, err := OpenFileStore(path); err == nil {
+				s.Close()
+				t.Fatal("OpenFileStore() of log with corrupt record in the middle succeeded")
+			}
+			if content, _ := ioutil.ReadFile(path); !bytes.Equal(content, corrupted) {
+				t.Error("OpenFileStore() modified the log with a corrupt record")
+			}
+		})
+	}
+}
+
+func TestReadFrameTooLarge(t *testing.T) {
+	header := []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}
+	var tooLarge *frameTooLargeError
+	if _, err := readFrame(bytes.NewReader(header)); !errors.As(err, &tooLarge) {
+		t.Errorf("readFrame() error = %v, want *frameTooLargeError", err)
+	}
+}
+
+func TestFileStoreInvalidHeader(t *testing.T) {
+	var frame bytes.Buffer
+	if err := writeFrame(&frame, record{kind: recordSave, session: Session{ClientID: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{name: "unknown version", content: append(logMagic[:], currentVersion+1)},
+		{name: "missing header", content: frame.Bytes()},
+		{name: "truncated header", content: logMagic[:2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, cleanup := tempLog(t)
+			defer cleanup()
+			if err := ioutil.WriteFile(path, tt.content, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if s, err := OpenFileStore(path); err == nil {
+				s.Close()
+				t.Fatal("OpenFileStore() of log with invalid header succeeded")
+			}
+			if content, _ := ioutil.ReadFile(path); !bytes.Equal(content, tt.content) {
+				t.Error("OpenFileStore() modified the log with invalid header")
+			}
+		})
+	}
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	s := mustOpen(t, path)
+	defer s.Close()
+	a := testSession(t, "a")
+	if err := s.Save(a); err != nil {
+		t.Fatal(err)
+	}
+	single := fileSize(t, path)
+
+	for i := 0; i < 10; i++ {
+		if err := s.Save(testSession(t, "b")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if size := fileSize(t, path); size <= single {
+		t.Fatalf("log size before compaction = %d, want more than %d", size, single)
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if size := fileSize(t, path); size != single {
+		t.Errorf("log size after compaction = %d, want %d", size, single)
+	}
+	if diff := deep.Equal(mustLoad(t, s), []Session{a}); diff != nil {
+		t.Error(diff)
+	}
+
+	// records appended after the compaction are recovered as well
+	if err := s.Save(Session{ClientID: "c", Expiry: 5}); err != nil {
+		t.Fatal(err)
+	}
+	reopened := mustOpen(t, path)
+	defer reopened.Close()
+	if diff := deep.Equal(mustLoad(t, reopened), []Session{a, {ClientID: "c", Expiry: 5}}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestFileStoreAutomaticCompaction(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+
+	s := mustOpen(t, path)
+	defer s.Close()
+	for i := 0; i < minGarbage+1; i++ {
+		if err := s.Save(Session{ClientID: "a", Expiry: uint32(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.garbage >= minGarbage {
+		t.Errorf("garbage = %d after %d saves, want compaction", s.garbage, minGarbage+1)
+	}
+	if diff := deep.Equal(mustLoad(t, s), []Session{{ClientID: "a", Expiry: minGarbage}}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,328 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/internal/types"
+	"github.com/squ94wk/mqtt-common/pkg/expiry"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/qos"
+)
+
+//Kinds of records in the log.
+const (
+	recordSave byte = iota + 1
+	recordDelete
+)
+
+//logMagic starts every log, followed by a byte with the version of its format.
+var logMagic = [4]byte{'M', 'Q', 'S', 'L'}
+
+//currentVersion is the version of the log format, logs of other versions can't be read.
+const currentVersion byte = 1
+
+//writeLogHeader writes the header of a log in the current format to writer.
+func writeLogHeader(writer io.Writer) (int64, error) {
+	n, err := writer.Write(append(logMagic[:], currentVersion))
+	return int64(n), err
+}
+
+//readLogHeader reads the header of a log and returns its size, which is 0 for an empty log.
+//An error is returned if the log doesn't start with logMagic or isn't of the current version.
+func readLogHeader(reader *bufio.Reader) (int64, error) {
+	if _, err := reader.Peek(1); err == io.EOF {
+		return 0, nil
+	}
+	var header [len(logMagic) + 1]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return 0, fmt.Errorf("failed to read log header: %v", err)
+	}
+	if !bytes.Equal(header[:len(logMagic)], logMagic[:]) {
+		return 0, fmt.Errorf("not a session log")
+	}
+	if version := header[len(logMagic)]; version != currentVersion {
+		return 0, fmt.Errorf("unsupported log version %d", version)
+	}
+	return int64(len(header)), nil
+}
+
+//frameHeaderSize is the size of the length and the checksum preceding every record.
+const frameHeaderSize = 8
+
+//maxFrameSize limits the size of a record, so a corrupt length doesn't make readFrame allocate arbitrary amounts of memory.
+const maxFrameSize = 64 << 20
+
+//errCorrupt is returned for records whose checksum doesn't match, e.g. after an interrupted write.
+var errCorrupt = errors.New("checksum mismatch")
+
+//frameTooLargeError is returned for records whose length exceeds maxFrameSize.
+type frameTooLargeError struct {
+	size uint32
+}
+
+func (e *frameTooLargeError) Error() string {
+	return fmt.Sprintf("record of %d bytes exceeds the maximum of %d bytes", e.size, maxFrameSize)
+}
+
+//record is an entry of the log.
+//Delete records only carry the client identifier.
+type record struct {
+	kind    byte
+	session Session
+}
+
+//writeFrame appends rec to buf, preceded by its length and checksum.
+func writeFrame(buf *bytes.Buffer, rec record) error {
+	var body bytes.Buffer
+	if err := writeRecord(&body, rec); err != nil {
+		return err
+	}
+	if _, err := types.WriteUInt32To(buf, uint32(body.Len())); err != nil {
+		return err
+	}
+	if _, err := types.WriteUInt32To(buf, crc32.ChecksumIEEE(body.Bytes())); err != nil {
+		return err
+	}
+	_, err := body.WriteTo(buf)
+	return err
+}
+
+//readFrame reads the next record from reader.
+//It returns io.EOF if reader ends before the record and io.ErrUnexpectedEOF if it ends within it.
+//An error of type *frameTooLargeError is returned for records longer than maxFrameSize, before reading them.
+func readFrame(reader io.Reader) (record, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return record{}, err
+	}
+	size := binary.BigEndian.Uint32(header[:4])
+	if size > maxFrameSize {
+		return record{}, &frameTooLargeError{size: size}
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		if err == io.EOF {
+			return record{}, io.ErrUnexpectedEOF
+		}
+		return record{}, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:]) {
+		return record{}, errCorrupt
+	}
+	return readRecord(bytes.NewReader(body))
+}
+
+//writeRecord writes the kind and client identifier of rec, followed by the state of the session for save records.
+//Subscriptions are written as subscribe control packets, messages as publish control packets.
+func writeRecord(writer io.Writer, rec record) error {
+	sess := rec.session
+	if _, err := writer.Write([]byte{rec.kind}); err != nil {
+		return err
+	}
+	if _, err := types.WriteStringTo(writer, sess.ClientID); err != nil {
+		return err
+	}
+	if rec.kind == recordDelete {
+		return nil
+	}
+
+	if _, err := types.WriteUInt32To(writer, sess.Expiry); err != nil {
+		return err
+	}
+	if err := writeTime(writer, sess.Disconnected); err != nil {
+		return err
+	}
+
+	if _, err := types.WriteVarIntTo(writer, uint32(len(sess.Subscriptions))); err != nil {
+		return err
+	}
+	for _, sub := range sess.Subscriptions {
+		props := packet.NewProperties()
+		if sub.Identifier != 0 {
+			props.Add(packet.NewProperty(packet.SubscriptionIdentifier, packet.VarIntPropPayload(sub.Identifier)))
+		}
+		// the packet identifier is required but meaningless
+		subscribe := packet.Subscribe{PacketID: 1, Props: props, Filters: []packet.SubscriptionFilter{sub.SubscriptionFilter}}
+		if _, err := subscribe.WriteTo(writer); err != nil {
+			return err
+		}
+	}
+
+	if _, err := types.WriteVarIntTo(writer, uint32(len(sess.InFlight))); err != nil {
+		return err
+	}
+	for _, msg := range sess.InFlight {
+		if _, err := writer.Write([]byte{byte(msg.State)}); err != nil {
+			return err
+		}
+		if _, err := withProps(msg.Publish).WriteTo(writer); err != nil {
+			return err
+		}
+	}
+
+	if _, err := types.WriteVarIntTo(writer, uint32(len(sess.Pending))); err != nil {
+		return err
+	}
+	for _, id := range sess.Pending {
+		if _, err := types.WriteUInt16To(writer, id); err != nil {
+			return err
+		}
+	}
+
+	if _, err := types.WriteVarIntTo(writer, uint32(len(sess.Queue))); err != nil {
+		return err
+	}
+	for _, msg := range sess.Queue {
+		// 3.3.2.3.3 the receive time is kept to expire the message after a restart
+		if err := writeTime(writer, msg.Received); err != nil {
+			return err
+		}
+		// 2.2.1 QoS 1 and QoS 2 publish control packets need a packet identifier, queued messages get theirs when they are sent
+		publish := withProps(msg.Publish)
+		if publish.Qos > packet.Qos0 {
+			publish.PacketID = 1
+		}
+		if _, err := publish.WriteTo(writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readRecord(reader io.Reader) (record, error) {
+	var kind [1]byte
+	if _, err := io.ReadFull(reader, kind[:]); err != nil {
+		return record{}, err
+	}
+	rec := record{kind: kind[0]}
+	if rec.kind != recordSave && rec.kind != recordDelete {
+		return record{}, fmt.Errorf("invalid record kind %d", rec.kind)
+	}
+	clientID, err := types.ReadString(reader)
+	if err != nil {
+		return record{}, err
+	}
+	rec.session.ClientID = clientID
+	if rec.kind == recordDelete {
+		return rec, nil
+	}
+
+	sess := &rec.session
+	if sess.Expiry, err = types.ReadUInt32(reader); err != nil {
+		return record{}, err
+	}
+	if sess.Disconnected, err = readTime(reader); err != nil {
+		return record{}, err
+	}
+
+	n, err := types.ReadVarInt(reader)
+	if err != nil {
+		return record{}, err
+	}
+	for i := uint32(0); i < n; i++ {
+		pkt, err := packet.ReadPacket(reader)
+		if err != nil {
+			return record{}, err
+		}
+		subscribe, ok := pkt.(*packet.Subscribe)
+		if !ok || len(subscribe.Filters) != 1 {
+			return record{}, fmt.Errorf("invalid subscription of session '%s'", clientID)
+		}
+		sub := Subscription{SubscriptionFilter: subscribe.Filters[0]}
+		if ids := subscribe.Props.VarInt(packet.SubscriptionIdentifier); len(ids) == 1 {
+			sub.Identifier = ids[0]
+		}
+		sess.Subscriptions = append(sess.Subscriptions, sub)
+	}
+
+	if n, err = types.ReadVarInt(reader); err != nil {
+		return record{}, err
+	}
+	for i := uint32(0); i < n; i++ {
+		var state [1]byte
+		if _, err := io.ReadFull(reader, state[:]); err != nil {
+			return record{}, err
+		}
+		publish, err := readPublish(reader)
+		if err != nil {
+			return record{}, err
+		}
+		sess.InFlight = append(sess.InFlight, qos.Message{Publish: publish, State: qos.State(state[0])})
+	}
+
+	if n, err = types.ReadVarInt(reader); err != nil {
+		return record{}, err
+	}
+	for i := uint32(0); i < n; i++ {
+		id, err := types.ReadUInt16(reader)
+		if err != nil {
+			return record{}, err
+		}
+		sess.Pending = append(sess.Pending, id)
+	}
+
+	if n, err = types.ReadVarInt(reader); err != nil {
+		return record{}, err
+	}
+	for i := uint32(0); i < n; i++ {
+		received, err := readTime(reader)
+		if err != nil {
+			return record{}, err
+		}
+		publish, err := readPublish(reader)
+		if err != nil {
+			return record{}, err
+		}
+		publish.PacketID = 0
+		sess.Queue = append(sess.Queue, expiry.Message{Publish: publish, Received: received})
+	}
+	return rec, nil
+}
+
+//writeTime writes t as nanoseconds since the unix epoch, 0 for the zero time.
+func writeTime(writer io.Writer, t time.Time) error {
+	var nanos [8]byte
+	if !t.IsZero() {
+		binary.BigEndian.PutUint64(nanos[:], uint64(t.UnixNano()))
+	}
+	_, err := writer.Write(nanos[:])
+	return err
+}
+
+func readTime(reader io.Reader) (time.Time, error) {
+	var nanos [8]byte
+	if _, err := io.ReadFull(reader, nanos[:]); err != nil {
+		return time.Time{}, err
+	}
+	if n := binary.BigEndian.Uint64(nanos[:]); n != 0 {
+		return time.Unix(0, int64(n)), nil
+	}
+	return time.Time{}, nil
+}
+
+func readPublish(reader io.Reader) (packet.Publish, error) {
+	pkt, err := packet.ReadPacket(reader)
+	if err != nil {
+		return packet.Publish{}, err
+	}
+	publish, ok := pkt.(*packet.Publish)
+	if !ok {
+		return packet.Publish{}, fmt.Errorf("read %T, want publish", pkt)
+	}
+	return *publish, nil
+}
+
+//withProps returns publish with empty properties if it has none, so it can be written.
+func withProps(publish packet.Publish) packet.Publish {
+	if publish.Props == nil {
+		publish.Props = packet.NewProperties()
+	}
+	return publish
+}
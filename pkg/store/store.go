@@ -0,0 +1,97 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/squ94wk/mqtt-common/pkg/expiry"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/qos"
+)
+
+//Subscription is a subscription of a session.
+type Subscription struct {
+	packet.SubscriptionFilter
+	//Identifier is the subscription identifier, 0 if there is none (3.8.2.1.2).
+	Identifier uint32
+}
+
+//Session is the persisted state of a session.
+type Session struct {
+	ClientID string
+	//Expiry is the session expiry interval in seconds (3.1.2.11.2).
+	Expiry uint32
+	//Disconnected is the time the network connection of the client was closed, zero while it is connected.
+	Disconnected time.Time
+
+	Subscriptions []Subscription
+	//InFlight are the outgoing QoS 1 and QoS 2 messages in the order they were sent.
+	InFlight []qos.Message
+	//Pending are the packet identifiers of incoming QoS 2 messages awaiting a pubrel.
+	Pending []uint16
+	//Queue are the messages that haven't been sent yet, together with the time they were received to expire them (3.3.2.3.3).
+	Queue []expiry.Message
+}
+
+//SessionStore is the interface a backend for session state has to implement.
+//There is at most one session per client identifier.
+type SessionStore interface {
+	//Save stores sess, replacing the session with the same client identifier.
+	Save(sess Session) error
+	//Delete removes the session of clientID, if any.
+	Delete(clientID string) error
+	//Load returns all stored sessions ordered by client identifier.
+	Load() ([]Session, error)
+}
+
+//MemoryStore is an in memory implementation of SessionStore.
+//It is safe for concurrent use.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]Session
+}
+
+//NewMemoryStore is the constructor of the MemoryStore type.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[string]Session),
+	}
+}
+
+//Save implements SessionStore.
+func (s *MemoryStore) Save(sess Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[sess.ClientID] = sess
+	return nil
+}
+
+//Delete implements SessionStore.
+func (s *MemoryStore) Delete(clientID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, clientID)
+	return nil
+}
+
+//Load implements SessionStore.
+func (s *MemoryStore) Load() ([]Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return sorted(s.sessions), nil
+}
+
+func sorted(sessions map[string]Session) []Session {
+	out := make([]Session, 0, len(sessions))
+	for _, sess := range sessions {
+		out = append(out, sess)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ClientID < out[j].ClientID
+	})
+	return out
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/expiry"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+	"github.com/squ94wk/mqtt-common/pkg/qos"
+	"github.com/squ94wk/mqtt-common/pkg/topic"
+)
+
+func newPublish(t *testing.T, qosLevel byte, id uint16, name, payload string) packet.Publish {
+	t.Helper()
+	tpc, err := topic.ParseTopic(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packet.Publish{Qos: qosLevel, PacketID: id, Topic: tpc, Props: packet.NewProperties(), Payload: []byte(payload)}
+}
+
+//testSession returns a session using every part of the state.
+func testSession(t *testing.T, clientID string) Session {
+	expiring := newPublish(t, packet.Qos1, 0, "a/c", "queued")
+	expiring.Props.Add(packet.NewProperty(packet.MessageExpiryInterval, packet.Int32PropPayload(60)))
+	return Session{
+		ClientID:     clientID,
+		Expiry:       3600,
+		Disconnected: time.Unix(1000, 500),
+		Subscriptions: []Subscription{
+			{SubscriptionFilter: packet.SubscriptionFilter{Filter: "a/#", MaxQoS: packet.Qos2, NoLocal: true}, Identifier: 7},
+			{SubscriptionFilter: packet.SubscriptionFilter{Filter: "$share/g/b", MaxQoS: packet.Qos1, RetainAsPublished: true, RetainHandling: 2}},
+		},
+		InFlight: []qos.Message{
+			{Publish: newPublish(t, packet.Qos1, 3, "a/b", "one"), State: qos.AwaitingPuback},
+			{Publish: newPublish(t, packet.Qos2, 1, "a/b", "two"), State: qos.AwaitingPubcomp},
+		},
+		Pending: []uint16{2, 9},
+		Queue: []expiry.Message{
+			{Publish: newPublish(t, packet.Qos0, 0, "a/b", "zero")},
+			{Publish: expiring, Received: time.Unix(990, 0)},
+		},
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		rec  record
+	}{
+		{name: "save", rec: record{kind: recordSave, session: testSession(t, "a")}},
+		{name: "empty session", rec: record{kind: recordSave, session: Session{ClientID: "b", Expiry: 1}}},
+		{name: "delete", rec: record{kind: recordDelete, session: Session{ClientID: "c"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeFrame(&buf, tt.rec); err != nil {
+				t.Fatalf("writeFrame() error = %v", err)
+			}
+			got, err := readFrame(&buf)
+			if err != nil {
+				t.Fatalf("readFrame() error = %v", err)
+			}
+			if diff := deep.Equal(got, tt.rec); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	for _, clientID := range []string{"b", "a", "c"} {
+		if err := s.Save(Session{ClientID: clientID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Save(Session{ClientID: "b", Expiry: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(sessions, []Session{{ClientID: "a"}, {ClientID: "b", Expiry: 10}}); diff != nil {
+		t.Error(diff)
+	}
+}
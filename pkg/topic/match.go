@@ -0,0 +1,141 @@
+package topic
+
+import "strings"
+
+//Matches reports whether the topic filter f matches the topic name t (4.7).
+//The wildcard '+' matches exactly one level, '#' matches any number of levels including the parent level.
+//Filters starting with a wildcard don't match topics starting with '$' (4.7.2).
+func (f Filter) Matches(t Topic) bool {
+	if len(f.Levels) == 0 || len(t.Levels) == 0 {
+		return false
+	}
+	if isWildcard(f.Levels[0]) && strings.HasPrefix(t.Levels[0], "$") {
+		return false
+	}
+
+	for i, level := range f.Levels {
+		if level == MultiLevelWildcard {
+			return true
+		}
+		if i >= len(t.Levels) {
+			return false
+		}
+		if level != SingleLevelWildcard && level != t.Levels[i] {
+			return false
+		}
+	}
+	return len(f.Levels) == len(t.Levels)
+}
+
+//Covers reports whether f matches every topic name other matches, e.g. 'a/#' covers 'a/+/c'.
+//Share names are ignored, the filters following them are compared.
+func (f Filter) Covers(other Filter) bool {
+	a, b := f.Levels, other.Levels
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	// 4.7.2 a filter starting with a wildcard doesn't match the topics starting with '$' other may match
+	if isWildcard(a[0]) && !isWildcard(b[0]) && strings.HasPrefix(b[0], "$") {
+		return false
+	}
+
+	for i, level := range a {
+		if level == MultiLevelWildcard {
+			return true
+		}
+		if i >= len(b) {
+			return false
+		}
+		if b[i] == MultiLevelWildcard {
+			// other also matches the parent level, unless it would be the empty topic name; only '+/#' covers it then
+			return !hasParent(b[:i]) && level == SingleLevelWildcard && len(a) == i+2 && a[i+1] == MultiLevelWildcard
+		}
+		if level != SingleLevelWildcard && level != b[i] {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+//Overlaps reports whether there is a topic name both f and other match, e.g. 'a/+' and '+/b' overlap.
+//Share names are ignored, the filters following them are compared.
+func (f Filter) Overlaps(other Filter) bool {
+	a, b := f.Levels, other.Levels
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	if isWildcard(a[0]) && !isWildcard(b[0]) && strings.HasPrefix(b[0], "$") ||
+		isWildcard(b[0]) && !isWildcard(a[0]) && strings.HasPrefix(a[0], "$") {
+		return false
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == MultiLevelWildcard || b[i] == MultiLevelWildcard {
+			return true
+		}
+		if !isWildcard(a[i]) && !isWildcard(b[i]) && a[i] != b[i] {
+			return false
+		}
+	}
+	if len(a) == len(b) {
+		return true
+	}
+	// 'a/#' also matches the parent level 'a'
+	longer, shorter := a, b
+	if len(b) > len(a) {
+		longer, shorter = b, a
+	}
+	return longer[len(shorter)] == MultiLevelWildcard && hasParent(shorter) && hasParent(longer[:len(shorter)])
+}
+
+//hasParent reports whether there is a topic name consisting of levels, the parent level a following '#' matches.
+//A single empty level would be the empty topic name, which is invalid (4.7.3).
+func hasParent(levels []string) bool {
+	return len(levels) > 1 || len(levels) == 1 && levels[0] != ""
+}
+
+//Match reports whether the topic filter matches the topic name with the same semantics as Filter.Matches.
+//It works directly on the raw strings and doesn't allocate.
+//Both inputs are expected to be valid, see ValidateFilter and ValidateTopic.
+//For shared subscriptions the filter following the share name is matched.
+func Match(filter, topic string) bool {
+	_, filter, _ = cutShare(filter)
+	if filter == "" || topic == "" {
+		return false
+	}
+	if (filter[0] == '+' || filter[0] == '#') && topic[0] == '$' {
+		return false
+	}
+
+	for {
+		filterLevel, filterRest, filterMore := cutLevel(filter)
+		if filterLevel == MultiLevelWildcard {
+			return true
+		}
+		topicLevel, topicRest, topicMore := cutLevel(topic)
+		if filterLevel != SingleLevelWildcard && filterLevel != topicLevel {
+			return false
+		}
+
+		switch {
+		case !filterMore:
+			return !topicMore
+		case !topicMore:
+			// 'a/#' also matches the parent level 'a'
+			return filterRest == MultiLevelWildcard
+		}
+		filter, topic = filterRest, topicRest
+	}
+}
+
+func isWildcard(level string) bool {
+	return level == SingleLevelWildcard || level == MultiLevelWildcard
+}
+
+func cutLevel(s string) (level, rest string, more bool) {
+	i := strings.IndexByte(s, Separator[0])
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+1:], true
+}
@@ -0,0 +1,241 @@
+package topic
+
+import "testing"
+
+var matchTests = []struct {
+	filter string
+	topic  string
+	want   bool
+}{
+	{filter: "a", topic: "a", want: true},
+	{filter: "a", topic: "b", want: false},
+	{filter: "a/b/c", topic: "a/b/c", want: true},
+	{filter: "a/b/c", topic: "a/b", want: false},
+	{filter: "a/b", topic: "a/b/c", want: false},
+	{filter: "A", topic: "a", want: false},
+	{filter: "/a", topic: "/a", want: true},
+	{filter: "/a", topic: "a", want: false},
+	{filter: "a/", topic: "a/", want: true},
+	{filter: "a/", topic: "a", want: false},
+
+	{filter: "+", topic: "a", want: true},
+	{filter: "+", topic: "a/b", want: false},
+	{filter: "+", topic: "/a", want: false},
+	{filter: "+/+", topic: "/a", want: true},
+	{filter: "/+", topic: "/a", want: true},
+	{filter: "a/+", topic: "a/", want: true},
+	{filter: "a/+", topic: "a", want: false},
+	{filter: "a/+/c", topic: "a/b/c", want: true},
+	{filter: "a/+/c", topic: "a/b/d", want: false},
+	{filter: "a/+/+", topic: "a/b/c", want: true},
+	{filter: "a/+/+", topic: "a/b/c/d", want: false},
+
+	{filter: "#", topic: "a", want: true},
+	{filter: "#", topic: "a/b/c", want: true},
+	{filter: "#", topic: "/", want: true},
+	{filter: "a/#", topic: "a", want: true},
+	{filter: "a/#", topic: "a/b", want: true},
+	{filter: "a/#", topic: "a/b/c", want: true},
+	{filter: "a/#", topic: "b/a", want: false},
+	{filter: "a/b/#", topic: "a", want: false},
+	{filter: "a/+/#", topic: "a/b", want: true},
+	{filter: "a/+/#", topic: "a", want: false},
+	{filter: "+/#", topic: "a", want: true},
+
+	{filter: "#", topic: "$SYS", want: false},
+	{filter: "#", topic: "$SYS/broker", want: false},
+	{filter: "+/broker", topic: "$SYS/broker", want: false},
+	{filter: "$SYS/#", topic: "$SYS/broker", want: true},
+	{filter: "$SYS/+", topic: "$SYS/broker", want: true},
+	{filter: "a/#", topic: "a/$SYS", want: true},
+	{filter: "+/$SYS", topic: "a/$SYS", want: true},
+
+	{filter: "$share/g/a/+", topic: "a/b", want: true},
+	{filter: "$share/g/#", topic: "a/b", want: true},
+	{filter: "$share/g/#", topic: "$SYS/broker", want: false},
+	{filter: "$share/g/a", topic: "$share/g/a", want: false},
+}
+
+func TestFilterMatches(t *testing.T) {
+	for _, tt := range matchTests {
+		t.Run(tt.filter+" "+tt.topic, func(t *testing.T) {
+			filter, err := ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			topic, err := ParseTopic(tt.topic)
+			if err != nil {
+				t.Fatalf("ParseTopic() error = %v", err)
+			}
+			if got := filter.Matches(topic); got != tt.want {
+				t.Errorf("Filter.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for _, tt := range matchTests {
+		t.Run(tt.filter+" "+tt.topic, func(t *testing.T) {
+			if got := Match(tt.filter, tt.topic); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchDoesNotAllocate(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		Match("a/+/c/#", "a/b/c/d/e")
+	})
+	if allocs != 0 {
+		t.Errorf("Match() allocates %v times, want 0", allocs)
+	}
+}
+
+var coverTests = []struct {
+	a, b     string
+	covers   bool
+	overlaps bool
+}{
+	{a: "a", b: "a", covers: true, overlaps: true},
+	{a: "a", b: "b"},
+	{a: "a/b", b: "a"},
+	{a: "a", b: "a/b"},
+	{a: "/a", b: "a"},
+	{a: "a/", b: "a/", covers: true, overlaps: true},
+
+	{a: "+", b: "a", covers: true, overlaps: true},
+	{a: "a", b: "+", overlaps: true},
+	{a: "+", b: "+", covers: true, overlaps: true},
+	{a: "+", b: "a/b"},
+	{a: "a/+", b: "a/b", covers: true, overlaps: true},
+	{a: "a/+", b: "a"},
+	{a: "a/+", b: "b/+"},
+	{a: "+/b", b: "a/+", overlaps: true},
+	{a: "+/+", b: "a/+", covers: true, overlaps: true},
+	{a: "a/+/c", b: "a/b/d"},
+	{a: "a/+/c", b: "+/b/c", overlaps: true},
+
+	{a: "#", b: "a/+/c", covers: true, overlaps: true},
+	{a: "#", b: "#", covers: true, overlaps: true},
+	{a: "#", b: "+/#", covers: true, overlaps: true},
+	{a: "+/#", b: "#", covers: true, overlaps: true},
+	{a: "a/#", b: "a/+/c", covers: true, overlaps: true},
+	{a: "a/#", b: "a", covers: true, overlaps: true},
+	{a: "a", b: "a/#", overlaps: true},
+	{a: "a/#", b: "a/#", covers: true, overlaps: true},
+	{a: "a/#", b: "#", overlaps: true},
+	{a: "a/+", b: "a/#", overlaps: true},
+	{a: "a/+/#", b: "a/#", overlaps: true},
+	{a: "a/+/#", b: "a"},
+	{a: "a/b/#", b: "a/+", overlaps: true},
+	{a: "a/#", b: "b/#"},
+	{a: "+/a/#", b: "+/+/b", overlaps: true},
+
+	{a: "#", b: "$SYS"},
+	{a: "#", b: "$SYS/#"},
+	{a: "+/#", b: "$SYS/a"},
+	{a: "+/broker", b: "$SYS/broker"},
+	{a: "$SYS/#", b: "$SYS/broker", covers: true, overlaps: true},
+	{a: "$SYS/#", b: "#"},
+	{a: "$SYS/+", b: "+/+"},
+	{a: "a/#", b: "a/$SYS", covers: true, overlaps: true},
+	{a: "+/$SYS", b: "a/+", overlaps: true},
+
+	{a: "$share/g/a/#", b: "a/b", covers: true, overlaps: true},
+	{a: "a/+", b: "$share/g/a/b", covers: true, overlaps: true},
+	{a: "$share/g/#", b: "$share/h/$SYS/a"},
+}
+
+func TestFilterCoversOverlaps(t *testing.T) {
+	for _, tt := range coverTests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			a, err := ParseFilter(tt.a)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			b, err := ParseFilter(tt.b)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			if got := a.Covers(b); got != tt.covers {
+				t.Errorf("Filter.Covers() = %v, want %v", got, tt.covers)
+			}
+			if got := a.Overlaps(b); got != tt.overlaps {
+				t.Errorf("Filter.Overlaps() = %v, want %v", got, tt.overlaps)
+			}
+			if got := b.Overlaps(a); got != tt.overlaps {
+				t.Errorf("reversed Filter.Overlaps() = %v, want %v", got, tt.overlaps)
+			}
+		})
+	}
+}
+
+//TestFilterCoversOverlapsExhaustive checks Covers and Overlaps against Matches for all filters of up to three levels
+//over a small alphabet, using all topic names of up to four levels over the same alphabet and a level no filter contains.
+func TestFilterCoversOverlapsExhaustive(t *testing.T) {
+	filterLevels := []string{"a", "$b", "", SingleLevelWildcard, MultiLevelWildcard}
+	topicLevels := []string{"a", "$b", "", "c"}
+	// the empty string is the only combination that is invalid
+	var filters []Filter
+	for _, input := range combine(filterLevels, 3) {
+		if filter, err := ParseFilter(input); err == nil {
+			filters = append(filters, filter)
+		}
+	}
+	var topics []Topic
+	for _, input := range combine(topicLevels, 4) {
+		if topic, err := ParseTopic(input); err == nil {
+			topics = append(topics, topic)
+		}
+	}
+
+	matched := make([][]bool, len(filters))
+	for i, filter := range filters {
+		matched[i] = make([]bool, len(topics))
+		for j, topic := range topics {
+			matched[i][j] = filter.Matches(topic)
+		}
+	}
+
+	for i, a := range filters {
+		for j, b := range filters {
+			covers, overlaps := true, false
+			for k := range topics {
+				if matched[j][k] && !matched[i][k] {
+					covers = false
+				}
+				if matched[i][k] && matched[j][k] {
+					overlaps = true
+				}
+			}
+			if got := a.Covers(b); got != covers {
+				t.Errorf("Filter.Covers() of %s and %s = %v, want %v", a, b, got, covers)
+			}
+			if got := a.Overlaps(b); got != overlaps {
+				t.Errorf("Filter.Overlaps() of %s and %s = %v, want %v", a, b, got, overlaps)
+			}
+		}
+	}
+}
+
+//combine returns all strings of 1 to n levels.
+func combine(levels []string, n int) []string {
+	var all, last []string
+	for _, level := range levels {
+		last = append(last, level)
+	}
+	all = append(all, last...)
+	for i := 1; i < n; i++ {
+		var next []string
+		for _, prefix := range last {
+			for _, level := range levels {
+				next = append(next, prefix+Separator+level)
+			}
+		}
+		all = append(all, next...)
+		last = next
+	}
+	return all
+}
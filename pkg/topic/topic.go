@@ -2,38 +2,87 @@ package topic
 
 import "strings"
 
+//Separator, wildcards and size limits defined in 4.7 Topic Names and Topic Filters.
+const (
+	Separator           = "/"
+	SingleLevelWildcard = "+"
+	MultiLevelWildcard  = "#"
+	SharePrefix         = "$share"
+
+	maxLength = 1<<16 - 1
+)
+
 //Topic defines an mqtt topic as a structured type.
 type Topic struct {
 	Levels []string
 }
 
 //Filter analogously defines an mqtt topic filter.
+//For shared subscriptions (4.8.2) ShareName is set and Levels hold the filter without the '$share/{ShareName}' prefix.
 type Filter struct {
-	Levels []string
+	ShareName string
+	Levels    []string
 }
 
 //String prints the topic as string by inserting back the '/' separator.
 func (t Topic) String() string {
-	return strings.Join(t.Levels, "/")
+	return strings.Join(t.Levels, Separator)
 }
 
 //String prints the topic filter as string by inserting back the '/' separator.
+//The '$share/{ShareName}' prefix is added for shared subscriptions.
 func (f Filter) String() string {
-	return strings.Join(f.Levels, "/")
+	if f.IsShared() {
+		return SharePrefix + Separator + f.ShareName + Separator + strings.Join(f.Levels, Separator)
+	}
+	return strings.Join(f.Levels, Separator)
+}
+
+//IsShared reports whether f is a shared subscription.
+func (f Filter) IsShared() bool {
+	return f.ShareName != ""
 }
 
 //ParseTopic parses a topic from an input string.
+//An error of type *InvalidTopicError is returned if input is not a valid topic name according to 4.7.
 func ParseTopic(input string) (Topic, error) {
-	levels := strings.Split(input, "/")
+	if err := ValidateTopic(input); err != nil {
+		return Topic{}, err
+	}
+
+	levels := strings.Split(input, Separator)
 	return Topic{
 		Levels: levels,
 	}, nil
 }
 
 //ParseFilter parses a topic filter from an input string.
+//Shared subscriptions of the form '$share/{ShareName}/{filter}' are recognized.
+//An error of type *InvalidFilterError is returned if input is not a valid topic filter according to 4.7 and 4.8.2.
 func ParseFilter(input string) (Filter, error) {
-	levels := strings.Split(input, "/")
+	if err := ValidateFilter(input); err != nil {
+		return Filter{}, err
+	}
+
+	shareName, filter, _ := cutShare(input)
+	levels := strings.Split(filter, Separator)
 	return Filter{
-		Levels: levels,
+		ShareName: shareName,
+		Levels:    levels,
 	}, nil
 }
+
+//cutShare splits a shared subscription into share name and filter.
+//If input is no shared subscription it is returned unchanged as filter.
+func cutShare(input string) (shareName, filter string, shared bool) {
+	if !strings.HasPrefix(input, SharePrefix+Separator) {
+		return "", input, false
+	}
+
+	rest := input[len(SharePrefix+Separator):]
+	i := strings.IndexByte(rest, Separator[0])
+	if i < 0 {
+		return rest, "", true
+	}
+	return rest[:i], rest[i+1:], true
+}
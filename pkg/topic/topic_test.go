@@ -0,0 +1,129 @@
+package topic
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseTopic(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Topic
+		wantErr error
+	}{
+		{name: "single level", input: "a", want: Topic{Levels: []string{"a"}}},
+		{name: "multiple levels", input: "a/b/c", want: Topic{Levels: []string{"a", "b", "c"}}},
+		{name: "leading separator", input: "/a", want: Topic{Levels: []string{"", "a"}}},
+		{name: "trailing separator", input: "a/", want: Topic{Levels: []string{"a", ""}}},
+		{name: "only separator", input: "/", want: Topic{Levels: []string{"", ""}}},
+		{name: "spaces", input: "a b/ c", want: Topic{Levels: []string{"a b", " c"}}},
+		{name: "$ topic", input: "$SYS/broker", want: Topic{Levels: []string{"$SYS", "broker"}}},
+		{name: "empty => err", input: "", wantErr: ErrEmpty},
+		{name: "too long => err", input: strings.Repeat("a", 1<<16), wantErr: ErrTooLong},
+		{name: "null character => err", input: "a/\x00", wantErr: ErrNullCharacter},
+		{name: "invalid utf8 => err", input: "a/\xff", wantErr: ErrInvalidUTF8},
+		{name: "single level wildcard => err", input: "a/+", wantErr: ErrWildcardInTopic},
+		{name: "multi level wildcard => err", input: "a/#", wantErr: ErrWildcardInTopic},
+		{name: "wildcard in level => err", input: "a/b+", wantErr: ErrWildcardInTopic},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTopic(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseTopic() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				var invalid *InvalidTopicError
+				if !errors.As(err, &invalid) {
+					t.Errorf("ParseTopic() error = %T, want *InvalidTopicError", err)
+				}
+				return
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Filter
+		wantErr error
+	}{
+		{name: "single level", input: "a", want: Filter{Levels: []string{"a"}}},
+		{name: "multiple levels", input: "a/b/c", want: Filter{Levels: []string{"a", "b", "c"}}},
+		{name: "only multi level wildcard", input: "#", want: Filter{Levels: []string{"#"}}},
+		{name: "only single level wildcard", input: "+", want: Filter{Levels: []string{"+"}}},
+		{name: "trailing multi level wildcard", input: "a/#", want: Filter{Levels: []string{"a", "#"}}},
+		{name: "single level wildcards", input: "+/b/+", want: Filter{Levels: []string{"+", "b", "+"}}},
+		{name: "mixed wildcards", input: "+/+/#", want: Filter{Levels: []string{"+", "+", "#"}}},
+		{name: "leading separator", input: "/+", want: Filter{Levels: []string{"", "+"}}},
+		{name: "shared subscription", input: "$share/group/a/+", want: Filter{ShareName: "group", Levels: []string{"a", "+"}}},
+		{name: "shared subscription with multi level wildcard", input: "$share/group/#", want: Filter{ShareName: "group", Levels: []string{"#"}}},
+		{name: "shared subscription with leading separator", input: "$share/group//a", want: Filter{ShareName: "group", Levels: []string{"", "a"}}},
+		{name: "$share without filter is no shared subscription", input: "$share", want: Filter{Levels: []string{"$share"}}},
+		{name: "$shared is no shared subscription", input: "$shared/a", want: Filter{Levels: []string{"$shared", "a"}}},
+		{name: "empty => err", input: "", wantErr: ErrEmpty},
+		{name: "too long => err", input: strings.Repeat("a", 1<<16), wantErr: ErrTooLong},
+		{name: "null character => err", input: "a/\x00", wantErr: ErrNullCharacter},
+		{name: "invalid utf8 => err", input: "\xc3\x28", wantErr: ErrInvalidUTF8},
+		{name: "multi level wildcard not last => err", input: "a/#/b", wantErr: ErrMultiLevelWildcardNotLast},
+		{name: "multi level wildcard in level => err", input: "a/b#", wantErr: ErrWildcardNotWholeLevel},
+		{name: "single level wildcard in level => err", input: "a/+b", wantErr: ErrWildcardNotWholeLevel},
+		{name: "double wildcard => err", input: "++", wantErr: ErrWildcardNotWholeLevel},
+		{name: "empty share name => err", input: "$share//a", wantErr: ErrInvalidShareName},
+		{name: "wildcard share name => err", input: "$share/+/a", wantErr: ErrInvalidShareName},
+		{name: "wildcard in share name => err", input: "$share/g#/a", wantErr: ErrInvalidShareName},
+		{name: "missing shared filter => err", input: "$share/group", wantErr: ErrSharedFilterMissing},
+		{name: "empty shared filter => err", input: "$share/group/", wantErr: ErrSharedFilterMissing},
+		{name: "invalid shared filter => err", input: "$share/group/#/a", wantErr: ErrMultiLevelWildcardNotLast},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFilter(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseFilter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				var invalid *InvalidFilterError
+				if !errors.As(err, &invalid) {
+					t.Errorf("ParseFilter() error = %T, want *InvalidFilterError", err)
+				}
+				return
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestFilterString(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{name: "filter", filter: Filter{Levels: []string{"a", "+", "#"}}, want: "a/+/#"},
+		{name: "shared subscription", filter: Filter{ShareName: "group", Levels: []string{"a", "#"}}, want: "$share/group/a/#"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("Filter.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package topic
+
+import (
+	"strings"
+	"sync"
+)
+
+//Subscription is an entry of a Tree.
+//Subscriber identifies the subscriber, e.g. a client identifier or a session, and must be comparable.
+//Options holds the subscription options, usually a packet.SubscriptionFilter.
+type Subscription struct {
+	Subscriber interface{}
+	Filter     Filter
+	Options    interface{}
+}
+
+//Tree is a subscription index keyed on the levels of topic filters.
+//Looking up the subscriptions matching a topic scales with the number of levels rather than the number of subscriptions.
+//It is safe for concurrent use.
+type Tree struct {
+	mu   sync.RWMutex
+	root *node
+}
+
+type node struct {
+	children map[string]*node
+	subs     map[interface{}]Subscription
+	// shares holds the members of the share groups by share name in the order they subscribed,
+	// which gives callers a stable order to choose a member from (4.8.2)
+	shares map[string][]Subscription
+}
+
+//NewTree is the constructor of the Tree type.
+func NewTree() *Tree {
+	return &Tree{root: newNode()}
+}
+
+func newNode() *node {
+	return &node{
+		children: make(map[string]*node),
+		subs:     make(map[interface{}]Subscription),
+		shares:   make(map[string][]Subscription),
+	}
+}
+
+//Insert adds a subscription of subscriber to filter.
+//An existing subscription of subscriber to the same filter is replaced (3.8.4).
+func (t *Tree) Insert(filter Filter, subscriber interface{}, options interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := t.root
+	for _, level := range filter.Levels {
+		child, ok := n.children[level]
+		if !ok {
+			child = newNode()
+			n.children[level] = child
+		}
+		n = child
+	}
+
+	sub := Subscription{
+		Subscriber: subscriber,
+		Filter:     filter,
+		Options:    options,
+	}
+	if !filter.IsShared() {
+		n.subs[subscriber] = sub
+		return
+	}
+	members := n.shares[filter.ShareName]
+	for i, member := range members {
+		if member.Subscriber == subscriber {
+			members[i] = sub
+			return
+		}
+	}
+	n.shares[filter.ShareName] = append(members, sub)
+}
+
+//Remove removes the subscription of subscriber to filter.
+//It reports whether such a subscription existed.
+func (t *Tree) Remove(filter Filter, subscriber interface{}) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return remove(t.root, filter.Levels, filter.ShareName, subscriber)
+}
+
+func remove(n *node, levels []string, shareName string, subscriber interface{}) bool {
+	if len(levels) == 0 {
+		return n.removeSub(shareName, subscriber)
+	}
+
+	child, ok := n.children[levels[0]]
+	if !ok {
+		return false
+	}
+	removed := remove(child, levels[1:], shareName, subscriber)
+	if len(child.subs) == 0 && len(child.shares) == 0 && len(child.children) == 0 {
+		delete(n.children, levels[0])
+	}
+	return removed
+}
+
+//Match returns all subscriptions whose filter matches topic.
+//A subscriber with several matching subscriptions is returned once per subscription.
+//Shared subscriptions are returned for every member of the share group, choosing one is up to the caller;
+//the members of a share group are returned in the order they subscribed.
+func (t *Tree) Match(topic Topic) []Subscription {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var subs []Subscription
+	if len(topic.Levels) == 0 {
+		return subs
+	}
+
+	// 4.7.2 wildcards at the first level don't match topics starting with '$'
+	dollar := strings.HasPrefix(topic.Levels[0], "$")
+	return match(t.root, topic.Levels, !dollar, subs)
+}
+
+func match(n *node, levels []string, wildcards bool, subs []Subscription) []Subscription {
+	if wildcards {
+		// '#' also matches the parent level
+		if child, ok := n.children[MultiLevelWildcard]; ok {
+			subs = appendSubs(subs, child)
+		}
+	}
+
+	if len(levels) == 0 {
+		return appendSubs(subs, n)
+	}
+
+	if child, ok := n.children[levels[0]]; ok {
+		subs = match(child, levels[1:], true, subs)
+	}
+	if wildcards {
+		if child, ok := n.children[SingleLevelWildcard]; ok {
+			subs = match(child, levels[1:], true, subs)
+		}
+	}
+	return subs
+}
+
+func appendSubs(subs []Subscription, n *node) []Subscription {
+	for _, sub := range n.subs {
+		subs = append(subs, sub)
+	}
+	for _, members := range n.shares {
+		subs = append(subs, members...)
+	}
+	return subs
+}
+
+//removeSub removes the subscription of subscriber in the share group shareName, if any, from n.
+func (n *node) removeSub(shareName string, subscriber interface{}) bool {
+	if shareName == "" {
+		if _, ok := n.subs[subscriber]; !ok {
+			return false
+		}
+		delete(n.subs, subscriber)
+		return true
+	}
+
+	members := n.shares[shareName]
+	for i, member := range members {
+		if member.Subscriber != subscriber {
+			continue
+		}
+		if len(members) == 1 {
+			delete(n.shares, shareName)
+			return true
+		}
+		n.shares[shareName] = append(members[:i:i], members[i+1:]...)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package topic
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func mustParseFilter(t *testing.T, input string) Filter {
+	t.Helper()
+	filter, err := ParseFilter(input)
+	if err != nil {
+		t.Fatalf("ParseFilter() error = %v", err)
+	}
+	return filter
+}
+
+func mustParseTopic(t *testing.T, input string) Topic {
+	t.Helper()
+	topic, err := ParseTopic(input)
+	if err != nil {
+		t.Fatalf("ParseTopic() error = %v", err)
+	}
+	return topic
+}
+
+func matchedFilters(subs []Subscription) []string {
+	got := make([]string, len(subs))
+	for i, sub := range subs {
+		got[i] = fmt.Sprintf("%v:%s", sub.Subscriber, sub.Filter)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestTreeMatch(t *testing.T) {
+	subscriptions := []struct {
+		filter     string
+		subscriber string
+	}{
+		{filter: "a/b/c", subscriber: "1"},
+		{filter: "a/+/c", subscriber: "1"},
+		{filter: "a/#", subscriber: "2"},
+		{filter: "#", subscriber: "3"},
+		{filter: "+/+", subscriber: "3"},
+		{filter: "$SYS/#", subscriber: "4"},
+		{filter: "$share/g/a/b/c", subscriber: "5"},
+		{filter: "$share/g/a/b/c", subscriber: "6"},
+		{filter: "$share/h/a/b/c", subscriber: "6"},
+	}
+
+	tests := []struct {
+		topic string
+		want  []string
+	}{
+		{topic: "a/b/c", want: []string{
+			"1:a/+/c", "1:a/b/c", "2:a/#", "3:#",
+			"5:$share/g/a/b/c", "6:$share/g/a/b/c", "6:$share/h/a/b/c",
+		}},
+		{topic: "a", want: []string{"2:a/#", "3:#"}},
+		{topic: "a/b", want: []string{"2:a/#", "3:#", "3:+/+"}},
+		{topic: "b", want: []string{"3:#"}},
+		{topic: "/b", want: []string{"3:#", "3:+/+"}},
+		{topic: "$SYS/broker", want: []string{"4:$SYS/#"}},
+		{topic: "$SYS", want: []string{"4:$SYS/#"}},
+		{topic: "$other/a", want: []string{}},
+	}
+
+	tree := NewTree()
+	for _, sub := range subscriptions {
+		tree.Insert(mustParseFilter(t, sub.filter), sub.subscriber, nil)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			got := matchedFilters(tree.Match(mustParseTopic(t, tt.topic)))
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestTreeInsertReplaces(t *testing.T) {
+	tree := NewTree()
+	filter := mustParseFilter(t, "a/+")
+	tree.Insert(filter, "1", 1)
+	tree.Insert(filter, "1", 2)
+
+	got := tree.Match(mustParseTopic(t, "a/b"))
+	want := []Subscription{{Subscriber: "1", Filter: filter, Options: 2}}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestTreeRemove(t *testing.T) {
+	tree := NewTree()
+	tree.Insert(mustParseFilter(t, "a/b/c"), "1", nil)
+	tree.Insert(mustParseFilter(t, "a/#"), "1", nil)
+	tree.Insert(mustParseFilter(t, "a/#"), "2", nil)
+	tree.Insert(mustParseFilter(t, "$share/g/a/#"), "2", nil)
+
+	if tree.Remove(mustParseFilter(t, "a/b"), "1") {
+		t.Error("Remove() of unknown filter = true, want false")
+	}
+	if tree.Remove(mustParseFilter(t, "a/#"), "3") {
+		t.Error("Remove() of unknown subscriber = true, want false")
+	}
+	if !tree.Remove(mustParseFilter(t, "a/#"), "2") {
+		t.Error("Remove() = false, want true")
+	}
+	if !tree.Remove(mustParseFilter(t, "a/b/c"), "1") {
+		t.Error("Remove() = false, want true")
+	}
+
+	got := matchedFilters(tree.Match(mustParseTopic(t, "a/b/c")))
+	want := []string{"1:a/#", "2:$share/g/a/#"}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+
+	tree.Remove(mustParseFilter(t, "a/#"), "1")
+	tree.Remove(mustParseFilter(t, "$share/g/a/#"), "2")
+	if len(tree.root.children) != 0 {
+		t.Errorf("Remove() left %d empty nodes", len(tree.root.children))
+	}
+}
+
+func TestTreeShareGroupOrder(t *testing.T) {
+	tree := NewTree()
+	filter := mustParseFilter(t, "$share/g/a")
+	for _, subscriber := range []string{"c", "a", "d", "b"} {
+		tree.Insert(filter, subscriber, nil)
+	}
+	tree.Insert(filter, "a", 1)
+	tree.Remove(filter, "d")
+
+	for i := 0; i < 10; i++ {
+		var got []interface{}
+		for _, sub := range tree.Match(mustParseTopic(t, "a")) {
+			got = append(got, sub.Subscriber)
+		}
+		if diff := deep.Equal(got, []interface{}{"c", "a", "b"}); diff != nil {
+			t.Fatal(diff)
+		}
+	}
+}
+
+func TestTreeConcurrentAccess(t *testing.T) {
+	tree := NewTree()
+	topic := mustParseTopic(t, "a/b")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			filter := Filter{Levels: []string{"a", "+"}}
+			for j := 0; j < 100; j++ {
+				tree.Insert(filter, i, nil)
+				tree.Match(topic)
+				tree.Remove(filter, i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := tree.Match(topic); len(got) != 0 {
+		t.Errorf("Match() = %v, want none", got)
+	}
+}
@@ -0,0 +1,122 @@
+package topic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+//Reasons a topic name or topic filter can be rejected for.
+//They are wrapped by InvalidTopicError and InvalidFilterError and can be compared with errors.Is.
+var (
+	ErrEmpty                     = errors.New("must be at least one character long")
+	ErrTooLong                   = fmt.Errorf("must not be longer than %d bytes", maxLength)
+	ErrInvalidUTF8               = errors.New("must be valid UTF-8")
+	ErrNullCharacter             = errors.New("must not contain the null character U+0000")
+	ErrWildcardInTopic           = errors.New("topic names must not contain wildcard characters")
+	ErrWildcardNotWholeLevel     = errors.New("wildcard characters must occupy an entire level")
+	ErrMultiLevelWildcardNotLast = errors.New("the multi-level wildcard must be the last level")
+	ErrInvalidShareName          = errors.New("share names must be at least one character long and must not contain '/', '+' or '#'")
+	ErrSharedFilterMissing       = errors.New("shared subscriptions must contain a topic filter after the share name")
+)
+
+//InvalidTopicError is returned if a string is not a valid topic name.
+type InvalidTopicError struct {
+	Topic string
+	Err   error
+}
+
+//InvalidFilterError is returned if a string is not a valid topic filter.
+type InvalidFilterError struct {
+	Filter string
+	Err    error
+}
+
+//Error implements the error interface.
+func (e *InvalidTopicError) Error() string {
+	return fmt.Sprintf("invalid topic name '%s': %v", e.Topic, e.Err)
+}
+
+//Unwrap returns the reason the topic name was rejected for.
+func (e *InvalidTopicError) Unwrap() error {
+	return e.Err
+}
+
+//Error implements the error interface.
+func (e *InvalidFilterError) Error() string {
+	return fmt.Sprintf("invalid topic filter '%s': %v", e.Filter, e.Err)
+}
+
+//Unwrap returns the reason the topic filter was rejected for.
+func (e *InvalidFilterError) Unwrap() error {
+	return e.Err
+}
+
+//ValidateTopic checks that input is a valid topic name.
+//Topic names must not contain wildcards (4.7.1).
+func ValidateTopic(input string) error {
+	if err := validateCommon(input); err != nil {
+		return &InvalidTopicError{Topic: input, Err: err}
+	}
+
+	if strings.ContainsAny(input, SingleLevelWildcard+MultiLevelWildcard) {
+		return &InvalidTopicError{Topic: input, Err: ErrWildcardInTopic}
+	}
+
+	return nil
+}
+
+//ValidateFilter checks that input is a valid topic filter.
+//Wildcards must occupy an entire level and '#' may only be used as the last level (4.7.1).
+//Shared subscriptions need a valid share name followed by a topic filter (4.8.2).
+func ValidateFilter(input string) error {
+	if err := validateCommon(input); err != nil {
+		return &InvalidFilterError{Filter: input, Err: err}
+	}
+
+	shareName, filter, shared := cutShare(input)
+	if shared {
+		if shareName == "" || strings.ContainsAny(shareName, SingleLevelWildcard+MultiLevelWildcard) {
+			return &InvalidFilterError{Filter: input, Err: ErrInvalidShareName}
+		}
+		if filter == "" {
+			return &InvalidFilterError{Filter: input, Err: ErrSharedFilterMissing}
+		}
+	}
+
+	levels := strings.Split(filter, Separator)
+	for i, level := range levels {
+		if level == MultiLevelWildcard {
+			if i != len(levels)-1 {
+				return &InvalidFilterError{Filter: input, Err: ErrMultiLevelWildcardNotLast}
+			}
+			continue
+		}
+		if level == SingleLevelWildcard {
+			continue
+		}
+		if strings.ContainsAny(level, SingleLevelWildcard+MultiLevelWildcard) {
+			return &InvalidFilterError{Filter: input, Err: ErrWildcardNotWholeLevel}
+		}
+	}
+
+	return nil
+}
+
+// 4.7.3 Topic semantic and usage
+func validateCommon(input string) error {
+	if len(input) == 0 {
+		return ErrEmpty
+	}
+	if len(input) > maxLength {
+		return ErrTooLong
+	}
+	if !utf8.ValidString(input) {
+		return ErrInvalidUTF8
+	}
+	if strings.IndexByte(input, 0) >= 0 {
+		return ErrNullCharacter
+	}
+	return nil
+}
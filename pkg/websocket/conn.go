@@ -0,0 +1,304 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+//Errors returned by Conn.
+var (
+	ErrClosed         = errors.New("websocket: connection is closed")
+	ErrTextFrame      = errors.New("websocket: text frames are not allowed")
+	ErrInvalidFrame   = errors.New("websocket: invalid frame")
+	ErrUnexpectedMask = errors.New("websocket: frame masking violates the direction")
+	ErrInvalidControl = errors.New("websocket: control frame is fragmented or exceeds 125 bytes")
+)
+
+// 5.2 opcodes
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// 7.4.1 status codes
+const (
+	closeNormal          = 1000
+	closeProtocolError   = 1002
+	closeUnsupportedData = 1003
+)
+
+// 5.2 frame header
+const (
+	finBit                   = 0x80
+	maskBit                  = 0x80
+	reservedBits             = 0x70
+	twoBytePayloadLength     = 126
+	eightBytePayloadLength   = 127
+	maxSevenBitPayloadLength = 125
+	maxTwoBytePayloadLength  = 1<<16 - 1
+	maxControlPayload        = 125
+)
+
+//Conn is a WebSocket connection that implements net.Conn.
+//Read returns the payload of binary frames as a stream, Write sends a single binary frame.
+//Read must only be called by a single reader, Write and Close are safe for concurrent use.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	client bool
+
+	// state of the frame currently being read
+	remaining uint64
+	masked    bool
+	maskKey   [4]byte
+	maskPos   int
+
+	mu        sync.Mutex
+	closeSent bool
+}
+
+func newConn(conn net.Conn, reader *bufio.Reader, client bool) *Conn {
+	return &Conn{
+		conn:   conn,
+		reader: reader,
+		client: client,
+	}
+}
+
+//Read reads the payload of binary frames.
+//Ping frames are answered and a close frame is answered and reported as io.EOF.
+func (c *Conn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	if uint64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.reader.Read(p)
+	if c.masked {
+		c.unmask(p[:n])
+	}
+	c.remaining -= uint64(n)
+	return n, err
+}
+
+//nextFrame reads frame headers until a data frame starts, handling control frames in between.
+func (c *Conn) nextFrame() error {
+	opcode, length, err := c.readHeader()
+	if err != nil {
+		return err
+	}
+
+	switch opcode {
+	case opBinary, opContinuation:
+		c.remaining = length
+		return nil
+
+	case opText:
+		// 6.0 mqtt control packets must be sent in binary frames
+		c.closeWith(closeUnsupportedData)
+		return ErrTextFrame
+
+	case opPing:
+		payload, err := c.readControlPayload(length)
+		if err != nil {
+			return err
+		}
+		return c.writeFrame(opPong, payload)
+
+	case opPong:
+		_, err := c.readControlPayload(length)
+		return err
+
+	case opClose:
+		payload, err := c.readControlPayload(length)
+		if err != nil {
+			return err
+		}
+		code := uint16(closeNormal)
+		if len(payload) >= 2 {
+			code = binary.BigEndian.Uint16(payload)
+		}
+		c.closeWith(code)
+		return io.EOF
+	}
+
+	c.closeWith(closeProtocolError)
+	return fmt.Errorf("%w: reserved opcode %d", ErrInvalidFrame, opcode)
+}
+
+// 5.2 base framing protocol
+func (c *Conn) readHeader() (opcode byte, length uint64, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return 0, 0, err
+	}
+
+	fin := header[0]&finBit != 0
+	opcode = header[0] & 0x0F
+	if header[0]&reservedBits != 0 {
+		c.closeWith(closeProtocolError)
+		return 0, 0, fmt.Errorf("%w: reserved bits are set", ErrInvalidFrame)
+	}
+
+	// 5.1 clients mask all frames, servers none
+	c.masked = header[1]&maskBit != 0
+	if c.masked == c.client {
+		c.closeWith(closeProtocolError)
+		return 0, 0, ErrUnexpectedMask
+	}
+
+	length = uint64(header[1] &^ maskBit)
+	switch length {
+	case twoBytePayloadLength:
+		var buf [2]byte
+		if _, err := io.ReadFull(c.reader, buf[:]); err != nil {
+			return 0, 0, err
+		}
+		length = uint64(binary.BigEndian.Uint16(buf[:]))
+	case eightBytePayloadLength:
+		var buf [8]byte
+		if _, err := io.ReadFull(c.reader, buf[:]); err != nil {
+			return 0, 0, err
+		}
+		length = binary.BigEndian.Uint64(buf[:])
+	}
+
+	// 5.5 control frames are not fragmented and carry at most 125 bytes
+	if opcode >= opClose && (!fin || length > maxControlPayload) {
+		c.closeWith(closeProtocolError)
+		return 0, 0, ErrInvalidControl
+	}
+
+	if c.masked {
+		if _, err := io.ReadFull(c.reader, c.maskKey[:]); err != nil {
+			return 0, 0, err
+		}
+		c.maskPos = 0
+	}
+	return opcode, length, nil
+}
+
+func (c *Conn) readControlPayload(length uint64) ([]byte, error) {
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return nil, err
+	}
+	if c.masked {
+		c.unmask(payload)
+	}
+	return payload, nil
+}
+
+func (c *Conn) unmask(p []byte) {
+	for i := range p {
+		p[i] ^= c.maskKey[c.maskPos&3]
+		c.maskPos++
+	}
+}
+
+//Write sends p as a single binary frame.
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(opBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, finBit|opcode)
+
+	var maskFlag byte
+	if c.client {
+		maskFlag = maskBit
+	}
+	switch {
+	case len(payload) <= maxSevenBitPayloadLength:
+		frame = append(frame, maskFlag|byte(len(payload)))
+	case len(payload) <= maxTwoBytePayloadLength:
+		frame = append(frame, maskFlag|twoBytePayloadLength, 0, 0)
+		binary.BigEndian.PutUint16(frame[len(frame)-2:], uint16(len(payload)))
+	default:
+		frame = append(frame, maskFlag|eightBytePayloadLength, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[len(frame)-8:], uint64(len(payload)))
+	}
+
+	if !c.client {
+		frame = append(frame, payload...)
+	} else {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return fmt.Errorf("websocket: failed to generate masking key: %v", err)
+		}
+		frame = append(frame, key[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^key[i&3])
+		}
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+//closeWith sends a close frame with code unless one was sent already.
+func (c *Conn) closeWith(code uint16) {
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], code)
+	_ = c.writeFrame(opClose, payload[:])
+}
+
+//Close sends a close frame and closes the underlying network connection.
+func (c *Conn) Close() error {
+	c.closeWith(closeNormal)
+	return c.conn.Close()
+}
+
+//LocalAddr returns the local address of the underlying network connection.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+//RemoteAddr returns the remote address of the underlying network connection.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+//SetDeadline sets the read and write deadlines of the underlying network connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+//SetReadDeadline sets the read deadline of the underlying network connection.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+//SetWriteDeadline sets the write deadline of the underlying network connection.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
@@ -0,0 +1,10 @@
+package websocket
+
+/*
+Package websocket transports mqtt control packets over WebSocket with the "mqtt" subprotocol (6.0).
+It implements the subset of RFC 6455 needed for it: Upgrade and Handler accept connections in a net/http server,
+Dial opens connections as a client.
+The returned net.Conn carries the bytes of binary frames as a stream,
+so a control packet may span several frames and a frame may contain several control packets.
+Use it with packet.NewConn like any other network connection.
+*/
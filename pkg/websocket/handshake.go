@@ -0,0 +1,234 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//Subprotocol is the WebSocket subprotocol of mqtt (6.0).
+const Subprotocol = "mqtt"
+
+// 1.3 the accept key is derived from the client's key and this GUID
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+//headerContains reports whether the comma separated values of the header contain token, ignoring case.
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//Upgrade performs the server side of the opening handshake (4.2) and returns the WebSocket connection.
+//The request must offer the "mqtt" subprotocol.
+//If the handshake fails, an HTTP error has been written to w and an error is returned.
+func Upgrade(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
+	fail := func(status int, format string, args ...interface{}) (net.Conn, error) {
+		err := fmt.Errorf("websocket: failed to upgrade: "+format, args...)
+		http.Error(w, err.Error(), status)
+		return nil, err
+	}
+
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "method %s is not GET", r.Method)
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported version %q", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return fail(http.StatusBadRequest, "missing Sec-WebSocket-Key")
+	}
+	if !headerContains(r.Header, "Sec-WebSocket-Protocol", Subprotocol) {
+		return fail(http.StatusBadRequest, "subprotocol %q not offered", Subprotocol)
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return fail(http.StatusInternalServerError, "connection can't be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, "%v", err)
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n" +
+		"Sec-WebSocket-Protocol: " + Subprotocol + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: failed to upgrade: failed to write response: %v", err)
+	}
+
+	return newConn(conn, rw.Reader, false), nil
+}
+
+//Handler is an http.Handler that upgrades requests to WebSocket connections and calls itself with them.
+//The connection is closed once the function returns.
+type Handler func(conn net.Conn)
+
+//ServeHTTP implements the http.Handler interface.
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	h(conn)
+}
+
+//Dial performs the client side of the opening handshake (4.1) with the server at rawURL
+//and returns the WebSocket connection.
+//The scheme of rawURL is either ws or wss.
+func Dial(ctx context.Context, rawURL string) (net.Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("websocket: failed to dial: %v", err)
+	}
+
+	var secure bool
+	switch u.Scheme {
+	case "ws":
+	case "wss":
+		secure = true
+	default:
+		return nil, fmt.Errorf("websocket: failed to dial: unsupported scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if secure {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("websocket: failed to dial: %v", err)
+	}
+	// the deadline and cancellation of ctx apply to the TLS and the WebSocket handshake
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := interruptOnDone(ctx, conn)
+	wsConn, err := dialHandshake(conn, u, secure)
+	stop()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = conn.SetDeadline(time.Time{})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: failed to dial: %v", err)
+	}
+	return wsConn, nil
+}
+
+//dialHandshake runs the TLS handshake if secure is set and the opening handshake of the client on conn.
+func dialHandshake(conn net.Conn, u *url.URL, secure bool) (*Conn, error) {
+	if secure {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	return handshake(conn, u)
+}
+
+//interruptOnDone interrupts blocking reads and writes of conn once ctx is done.
+//The returned function stops watching ctx; once it returned, conn isn't touched anymore.
+func interruptOnDone(ctx context.Context, conn net.Conn) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			// a deadline in the past makes pending and future I/O fail immediately
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func handshake(conn net.Conn, u *url.URL) (*Conn, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Upgrade":                {"websocket"},
+			"Connection":             {"Upgrade"},
+			"Sec-Websocket-Key":      {key},
+			"Sec-Websocket-Version":  {"13"},
+			"Sec-Websocket-Protocol": {Subprotocol},
+		},
+		Host: u.Host,
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("failed to write request: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("unexpected response status %q", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, fmt.Errorf("invalid Sec-WebSocket-Accept")
+	}
+	if resp.Header.Get("Sec-WebSocket-Protocol") != Subprotocol {
+		return nil, fmt.Errorf("server didn't select subprotocol %q", Subprotocol)
+	}
+
+	return newConn(conn, reader, true), nil
+}
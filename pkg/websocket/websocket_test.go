@@ -0,0 +1,249 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/squ94wk/mqtt-common/pkg/packet"
+)
+
+//echoServer echoes all control packets received over WebSocket.
+func echoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(Handler(func(conn net.Conn) {
+		pktConn := packet.NewConn(conn)
+		for {
+			pkt, err := pktConn.ReadPacket()
+			if err != nil {
+				return
+			}
+			if err := pktConn.WritePacket(pkt); err != nil {
+				t.Errorf("WritePacket() error = %v", err)
+				return
+			}
+		}
+	}))
+}
+
+func dial(t *testing.T, server *httptest.Server) net.Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	conn, err := Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/mqtt")
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	return conn
+}
+
+func TestEcho(t *testing.T) {
+	server := echoServer(t)
+	defer server.Close()
+	conn := dial(t, server)
+	defer conn.Close()
+
+	pktConn := packet.NewConn(conn)
+	payload := bytes.Repeat([]byte("x"), 70000)
+	pkts := []packet.Packet{
+		&packet.Pingreq{},
+		&packet.Puback{PacketID: 1, Reason: packet.PubackSuccess, Props: packet.NewProperties()},
+		&packet.Publish{Qos: packet.Qos0, Props: packet.NewProperties(packet.NewProperty(packet.TopicAlias, packet.Int16PropPayload(1))), Payload: payload},
+		&packet.Disconnect{Reason: packet.DisconnectServerShuttingDown, Props: packet.NewProperties()},
+	}
+	for _, pkt := range pkts {
+		if err := pktConn.WritePacket(pkt); err != nil {
+			t.Fatalf("WritePacket() error = %v", err)
+		}
+		got, err := pktConn.ReadPacket()
+		if err != nil {
+			t.Fatalf("ReadPacket() error = %v", err)
+		}
+		if diff := deep.Equal(got, pkt); diff != nil {
+			t.Error(diff)
+		}
+	}
+}
+
+func TestFraming(t *testing.T) {
+	server := echoServer(t)
+	defer server.Close()
+	conn := dial(t, server)
+	defer conn.Close()
+
+	var buf bytes.Buffer
+	first := &packet.Puback{PacketID: 1, Reason: packet.PubackSuccess, Props: packet.NewProperties()}
+	second := &packet.Puback{PacketID: 2, Reason: packet.PubackSuccess, Props: packet.NewProperties()}
+	if _, err := first.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	bin := buf.Bytes()
+
+	// the first control packet spans two frames, the second frame contains the start of the second control packet
+	for _, frame := range [][]byte{bin[:2], bin[2:6], bin[6:]} {
+		if _, err := conn.Write(frame); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	pktConn := packet.NewConn(conn)
+	for _, want := range []packet.Packet{first, second} {
+		got, err := pktConn.ReadPacket()
+		if err != nil {
+			t.Fatalf("ReadPacket() error = %v", err)
+		}
+		if diff := deep.Equal(got, want); diff != nil {
+			t.Error(diff)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	server := echoServer(t)
+	defer server.Close()
+	conn := dial(t, server)
+
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := conn.Write([]byte{0}); err != ErrClosed {
+		t.Errorf("Write() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	server := httptest.NewServer(Handler(func(conn net.Conn) {}))
+	defer server.Close()
+	conn := dial(t, server)
+	defer conn.Close()
+
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read() error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestUpgradeRejected(t *testing.T) {
+	server := echoServer(t)
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{
+			name:   "no upgrade",
+			header: http.Header{},
+			want:   http.StatusBadRequest,
+		},
+		{
+			name: "no mqtt subprotocol",
+			header: http.Header{
+				"Connection":             {"Upgrade"},
+				"Upgrade":                {"websocket"},
+				"Sec-Websocket-Version":  {"13"},
+				"Sec-Websocket-Key":      {"dGhlIHNhbXBsZSBub25jZQ=="},
+				"Sec-Websocket-Protocol": {"chat"},
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "unsupported version",
+			header: http.Header{
+				"Connection":             {"Upgrade"},
+				"Upgrade":                {"websocket"},
+				"Sec-Websocket-Version":  {"8"},
+				"Sec-Websocket-Key":      {"dGhlIHNhbXBsZSBub25jZQ=="},
+				"Sec-Websocket-Protocol": {"mqtt"},
+			},
+			want: http.StatusUpgradeRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header = tt.header
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialStalledHandshake(t *testing.T) {
+	// the listener accepts connections but never answers the TLS handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+	}{
+		{name: "deadline", ctx: func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 50*time.Millisecond)
+		}},
+		{name: "cancel", ctx: func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			return ctx, cancel
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			dialed := make(chan error, 1)
+			go func() {
+				_, err := Dial(ctx, "wss://"+l.Addr().String()+"/mqtt")
+				dialed <- err
+			}()
+			select {
+			case err := <-dialed:
+				if err == nil {
+					t.Error("Dial() succeeded without a TLS handshake")
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Dial() blocked in the TLS handshake after ctx was done")
+			}
+		})
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// example of RFC 6455 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey() = %s", got)
+	}
+}